@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/ratelimit"
+	"github.com/sdwolfe32/trumail/verifier"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrMissingEmails is thrown when a batch request has no emails
+var ErrMissingEmails = echo.NewHTTPError(http.StatusBadRequest,
+	"Missing emails")
+
+// BatchRequest is the JSON, XML or form encoded body accepted by
+// BatchHandler
+type BatchRequest struct {
+	XMLName xml.Name `json:"-" form:"-" xml:"batchRequest"`
+	Emails  []string `json:"emails" form:"emails" xml:"email"`
+	LookupOptions
+}
+
+// BatchLookup is a Lookup performed as part of a batch along with the
+// error it failed with, if any
+type BatchLookup struct {
+	Lookup
+	Error string `json:"error,omitempty" xml:"error,omitempty"`
+	Code  string `json:"code,omitempty" xml:"code,omitempty"`
+}
+
+// BatchLookups are the lookups of a batch in the order of its emails
+type BatchLookups []*BatchLookup
+
+// MarshalXML encodes the lookups within a single lookups element
+func (b BatchLookups) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "lookups"}
+	return e.EncodeElement(struct {
+		Lookups []*BatchLookup `xml:"lookup"`
+	}{b}, start)
+}
+
+// BatchHandler performs the verification of up to limit emails at once,
+// verifying at most workers domains concurrently, and returns their
+// lookups in the order requested. The ndjson and sse formats instead
+// stream each lookup as soon as it completes
+func BatchHandler(v *verifier.Verifier, limit, workers int) echo.HandlerFunc {
+	errTooManyEmails := echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("Too many emails, at most %d are allowed", limit))
+	return func(c echo.Context) error {
+		var req BatchRequest
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		if err := checkEmails(req.Emails, limit, errTooManyEmails); err != nil {
+			return err
+		}
+		opts, err := req.options()
+		if err != nil {
+			return err
+		}
+		if err := charge(c, len(req.Emails)-1); err != nil {
+			return err
+		}
+
+		ctx, span := tracer.Start(c.Request().Context(), "api.BatchHandler")
+		defer span.End()
+		span.SetAttributes(attribute.Int("trumail.batch_size", len(req.Emails)))
+
+		// Stream the lookups, cancelling any remaining if the client leaves
+		if enc := newStreamEncoder(c); enc != nil {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			return streamLookups(ctx, c, enc, v.VerifyBatch(ctx, req.Emails, opts, workers))
+		}
+
+		// Collect the lookups in the order requested
+		lookups := make(BatchLookups, len(req.Emails))
+		for r := range v.VerifyBatch(ctx, req.Emails, opts, workers) {
+			lookups[r.Index] = newBatchLookup(r.Lookup, r.Err)
+		}
+		return FormatEncoder(c, http.StatusOK, lookups)
+	}
+}
+
+// checkEmails asserts a batch has between one and limit emails, none of
+// which are blank
+func checkEmails(emails []string, limit int, errTooManyEmails error) error {
+	if len(emails) == 0 {
+		return ErrMissingEmails
+	}
+	if len(emails) > limit {
+		return errTooManyEmails
+	}
+	for _, email := range emails {
+		if strings.TrimSpace(email) == "" {
+			return ErrMissingEmail
+		}
+	}
+	return nil
+}
+
+// newBatchLookup converts a verifier.Lookup and the error it failed with to
+// its API representation
+func newBatchLookup(l *verifier.Lookup, err error) *BatchLookup {
+	b := &BatchLookup{Lookup: *newLookup(l)}
+	switch e := err.(type) {
+	case nil:
+	case *verifier.LookupError:
+		b.Error, b.Code = e.Message, e.Code()
+	default:
+		b.Error, b.Code = e.Error(), verifier.CodeUnknown
+	}
+	return b
+}
+
+// charge counts n more emails verified by the request, beyond the one the
+// rate limiting middleware counted, against its quotas
+func charge(c echo.Context, n int) error {
+	if fn, ok := c.Get(ratelimit.ChargeKey).(func(int) error); ok && n > 0 {
+		return fn(n)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/pb"
+	"github.com/sdwolfe32/trumail/verifier"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+// batch performs a batch request against a BatchHandler accepting up to
+// three emails
+func batch(format, contentType, body string) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	e.POST("/v1/batch/:format", BatchHandler(verifier.NewVerifier("localhost", "admin@localhost"), 3, 2))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/batch/"+format, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBatchHandlerJSON(t *testing.T) {
+	rec := batch(FormatJSON, echo.MIMEApplicationJSON, `{"emails":["one","two","three"]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var lookups []BatchLookup
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &lookups))
+	assert.Len(t, lookups, 3)
+	for i, email := range []string{"one", "two", "three"} {
+		assert.Equal(t, email, lookups[i].Address)
+		assert.False(t, lookups[i].ValidFormat)
+	}
+}
+
+func TestBatchHandlerForm(t *testing.T) {
+	form := url.Values{"emails": {"one", "two"}}
+	rec := batch(FormatXML, echo.MIMEApplicationForm, form.Encode())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<lookups><lookup><address>one</address>")
+}
+
+func TestBatchHandlerFormats(t *testing.T) {
+	body := `{"emails":["one","two"]}`
+
+	rec := batch(FormatCSV, echo.MIMEApplicationJSON, body)
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasSuffix(lines[0], ",error,code"))
+	assert.True(t, strings.HasPrefix(lines[2], "two,"))
+
+	rec = batch(FormatProtobuf, echo.MIMEApplicationJSON, body)
+	var lookups pb.BatchLookups
+	assert.Nil(t, proto.Unmarshal(rec.Body.Bytes(), &lookups))
+	assert.Len(t, lookups.Lookups, 2)
+	assert.Equal(t, "two", lookups.Lookups[1].Lookup.Address)
+}
+
+func TestBatchHandlerErrors(t *testing.T) {
+	for body, code := range map[string]int{
+		`{}`:                                  http.StatusBadRequest,
+		`{"emails":[]}`:                       http.StatusBadRequest,
+		`{"emails":["a@b.com",""]}`:           http.StatusBadRequest,
+		`{"emails":["a@b.com"],"retries":99}`: http.StatusBadRequest,
+		`{"emails":["a","b","c","d"]}`:        http.StatusRequestEntityTooLarge,
+	} {
+		rec := batch(FormatJSON, echo.MIMEApplicationJSON, body)
+		assert.Equal(t, code, rec.Code, body)
+	}
+}
+
+func TestNewBatchLookup(t *testing.T) {
+	l := &verifier.Lookup{Address: verifier.Address{Address: "a@b.com"}}
+	b := newBatchLookup(l, &verifier.LookupError{Message: verifier.ErrTimeout})
+	assert.Equal(t, "a@b.com", b.Address)
+	assert.Equal(t, verifier.ErrTimeout, b.Error)
+	assert.Equal(t, verifier.CodeTimeout, b.Code)
+
+	b = newBatchLookup(l, nil)
+	assert.Empty(t, b.Error)
+	assert.Empty(t, b.Code)
+}
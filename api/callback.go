@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/jobs"
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/webhook"
+)
+
+// WebhookSecretKey is the echo context key holding the global secret used
+// to sign the callbacks of jobs created by API keys without a webhook
+// secret of their own
+const WebhookSecretKey = "trumail.webhookSecret"
+
+// PublicURLKey is the echo context key holding the URL the API is reached
+// at, which the callbacks of jobs link to the results from
+const PublicURLKey = "trumail.publicURL"
+
+var (
+	// ErrInvalidCallbackURL is thrown when a callbackUrl isn't an absolute
+	// http or https URL
+	ErrInvalidCallbackURL = echo.NewHTTPError(http.StatusBadRequest,
+		"Invalid callbackUrl")
+	// ErrPrivateCallbackURL is thrown when a callbackUrl resolves to a
+	// loopback, private, link-local or unspecified address
+	ErrPrivateCallbackURL = echo.NewHTTPError(http.StatusBadRequest,
+		"callbackUrl must resolve to a public address")
+	// ErrMissingWebhookSecret is thrown when a callbackUrl is requested
+	// without a webhook secret to sign it with
+	ErrMissingWebhookSecret = echo.NewHTTPError(http.StatusBadRequest,
+		"Callbacks require a webhook secret")
+)
+
+// JobCallback reports the delivery of a jobs callback
+type JobCallback struct {
+	URL      string            `json:"url" xml:"url"`
+	Status   string            `json:"status" xml:"status"`
+	Attempts []webhook.Attempt `json:"attempts" xml:"attempt"`
+}
+
+// JobEvent is the JSON body POSTed to a jobs callbackUrl once it finishes
+type JobEvent struct {
+	Event   string `json:"event"`   // job.completed or job.cancelled
+	Job     *Job   `json:"job"`     // The finished job
+	Results string `json:"results"` // The URL of the jobs results, a path without a public URL
+}
+
+// AsyncLookupRequest is the JSON, XML or form encoded body accepted by
+// AsyncLookupHandler
+type AsyncLookupRequest struct {
+	XMLName     xml.Name `json:"-" form:"-" xml:"asyncLookupRequest"`
+	Email       string   `json:"email" form:"email" xml:"email"`
+	CallbackURL string   `json:"callbackUrl" form:"callbackUrl" xml:"callbackUrl"`
+	LookupOptions
+}
+
+// AsyncLookupHandler verifies the email address in the request body in
+// the background as a job of a single email, POSTing the result link to
+// the callbackUrl once complete
+func AsyncLookupHandler(m *jobs.Manager) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req AsyncLookupRequest
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		if strings.TrimSpace(req.Email) == "" {
+			return ErrMissingEmail
+		}
+		return createJob(c, m, jobs.Emails([]string{req.Email}), req.LookupOptions,
+			req.CallbackURL, 1, ErrMissingEmail)
+	}
+}
+
+// JobPayload builds the JobEvent POSTed to a jobs callbackUrl
+func JobPayload(j *jobs.Job) ([]byte, error) {
+	job := newJob(j)
+	job.Callback = nil // Still being delivered
+	return json.Marshal(&JobEvent{
+		Event:   "job." + j.Status,
+		Job:     job,
+		Results: fmt.Sprintf("%s/v1/jobs/%s/%s/results", j.Callback.BaseURL, FormatJSON, j.ID),
+	})
+}
+
+// newCallback validates the callbackUrl of a request, returning the
+// jobs.Callback signed with the webhook secret of the requests API key, or
+// the global one without, or nil if no callbackUrl was requested. The
+// callbackUrl must resolve to addresses the Manager delivers callbacks to
+func newCallback(c echo.Context, m *jobs.Manager, callbackURL string) (*jobs.Callback, error) {
+	if callbackURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidCallbackURL
+	}
+	switch err := m.CheckCallback(c.Request().Context(), u.String()); {
+	case err == webhook.ErrPrivateAddress:
+		return nil, ErrPrivateCallbackURL
+	case err != nil:
+		return nil, ErrInvalidCallbackURL
+	}
+	secret, _ := c.Get(WebhookSecretKey).(string)
+	if key := keys.FromContext(c.Request().Context()); key != nil && key.WebhookSecret != "" {
+		secret = key.WebhookSecret
+	}
+	if secret == "" {
+		return nil, ErrMissingWebhookSecret
+	}
+	publicURL, _ := c.Get(PublicURLKey).(string)
+	return &jobs.Callback{
+		URL:     u.String(),
+		Secret:  secret,
+		BaseURL: strings.TrimSuffix(publicURL, "/"),
+	}, nil
+}
+
+// newJobCallback converts a jobs.Callback to its API representation,
+// leaving out its secret
+func newJobCallback(cb *jobs.Callback) *JobCallback {
+	if cb == nil {
+		return nil
+	}
+	return &JobCallback{URL: cb.URL, Status: cb.Status, Attempts: cb.Attempts}
+}
@@ -1,10 +1,22 @@
 package api
 
 import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
 	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/labstack/echo"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -14,8 +26,35 @@ const (
 	FormatJSONP = "jsonp"
 	// FormatXML is the format constant for a XML output
 	FormatXML = "xml"
+	// FormatCSV is the format constant for a CSV output
+	FormatCSV = "csv"
+	// FormatTSV is the format constant for a TSV output
+	FormatTSV = "tsv"
+	// FormatYAML is the format constant for a YAML output
+	FormatYAML = "yaml"
+	// FormatMsgPack is the format constant for a MessagePack output
+	FormatMsgPack = "msgpack"
+	// FormatProtobuf is the format constant for a protocol buffer output
+	FormatProtobuf = "protobuf"
 )
 
+const (
+	// MIMETextCSV is the content type of a CSV output
+	MIMETextCSV = "text/csv"
+	// MIMETextTSV is the content type of a TSV output
+	MIMETextTSV = "text/tab-separated-values"
+	// MIMEApplicationYAML is the content type of a YAML output
+	MIMEApplicationYAML = "application/yaml"
+	// MIMEApplicationMsgPack is the content type of a MessagePack output
+	MIMEApplicationMsgPack = "application/msgpack"
+	// MIMEApplicationProtobuf is the content type of a protocol buffer output
+	MIMEApplicationProtobuf = "application/protobuf"
+)
+
+// DisableJSONPKey is the echo context key set to true when JSONP
+// responses are disabled
+const DisableJSONPKey = "trumail.disableJSONP"
+
 var (
 	// ErrMissingCallback is thrown when the request is missing the
 	// callback queryparam
@@ -25,28 +64,301 @@ var (
 	// defined an unsupported response format
 	ErrUnsupportedFormat = echo.NewHTTPError(http.StatusBadRequest,
 		"Unsupported format specified")
+	// ErrNotAcceptable is thrown when none of the content types in the
+	// requests Accept header can be produced
+	ErrNotAcceptable = echo.NewHTTPError(http.StatusNotAcceptable,
+		"None of the accepted content types are supported")
 )
 
+// formats lists every supported format along with its content type in
+// order of preference when negotiating a wildcard
+var formats = []struct{ format, mediaType string }{
+	{FormatJSON, echo.MIMEApplicationJSON},
+	{FormatXML, echo.MIMEApplicationXML},
+	{FormatYAML, MIMEApplicationYAML},
+	{FormatCSV, MIMETextCSV},
+	{FormatTSV, MIMETextTSV},
+	{FormatMsgPack, MIMEApplicationMsgPack},
+	{FormatProtobuf, MIMEApplicationProtobuf},
+	{FormatJSONP, mimeJavaScript},
+}
+
+// mediaTypeAliases maps additional content types seen in Accept headers
+// to their format
+var mediaTypeAliases = map[string]string{
+	"text/json":                       FormatJSON,
+	echo.MIMETextXML:                  FormatXML,
+	"application/x-yaml":              FormatYAML,
+	"text/yaml":                       FormatYAML,
+	"application/x-msgpack":           FormatMsgPack,
+	"application/x-protobuf":          FormatProtobuf,
+	"application/vnd.google.protobuf": FormatProtobuf,
+}
+
+// errorBody is the body of an error returned from a v1 route
+type errorBody struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Message string   `json:"message" xml:"message"`
+}
+
 // FormatEncoder is an encoder that reads the format from the
 // passed echo context and writes the status code and response
-// based on that format on the URL
+// based on that format on the URL, or the Accept header on routes
+// without a format. JSONP is unsupported when disabled by the
+// DisableJSONPKey
 func FormatEncoder(c echo.Context, code int, res interface{}) error {
 	// Add X-Powered-By header
-	c.Response().Header().Add("X-Powered-By", "Trumail")
+	c.Response().Header().Set("X-Powered-By", "Trumail")
+
+	// Determine the requested format
+	jsonp := c.Get(DisableJSONPKey) != true
+	format := strings.ToLower(c.Param("format"))
+	if format == "" {
+		var err error
+		c.Response().Header().Add(echo.HeaderVary, "Accept")
+		if format, err = negotiate(c.Request().Header.Get("Accept"), jsonp); err != nil {
+			return err
+		}
+	}
 
 	// Encode the in requested format
-	switch strings.ToLower(c.Param("format")) {
+	switch format {
 	case FormatXML:
 		return c.XML(code, res)
 	case FormatJSON:
 		return c.JSON(code, res)
 	case FormatJSONP:
+		if !jsonp {
+			return ErrUnsupportedFormat
+		}
 		callback := c.QueryParam("callback")
 		if callback == "" {
 			return ErrMissingCallback
 		}
 		return c.JSONP(code, callback, res)
+	case FormatCSV:
+		return encodeBlob(c, code, MIMETextCSV+"; charset=UTF-8", res, encodeCSV)
+	case FormatTSV:
+		return encodeBlob(c, code, MIMETextTSV+"; charset=UTF-8", res, encodeTSV)
+	case FormatYAML:
+		return encodeBlob(c, code, MIMEApplicationYAML, res, encodeYAML)
+	case FormatMsgPack:
+		return encodeBlob(c, code, MIMEApplicationMsgPack, res, encodeMsgPack)
+	case FormatProtobuf:
+		return encodeBlob(c, code, MIMEApplicationProtobuf, res, encodeProtobuf)
 	default:
 		return ErrUnsupportedFormat
 	}
 }
+
+// ErrorHandler is an echo.HTTPErrorHandler that writes errors in the
+// requested format, falling back to JSON when that format can't be
+// produced
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	// Build the error body
+	code := http.StatusInternalServerError
+	body := &errorBody{Message: http.StatusText(code)}
+	if he, ok := err.(*echo.HTTPError); ok {
+		code, body.Message = he.Code, fmt.Sprint(he.Message)
+	}
+
+	// Write the error body
+	if c.Request().Method == http.MethodHead {
+		c.NoContent(code)
+		return
+	}
+	if err := FormatEncoder(c, code, body); err != nil {
+		c.JSON(code, body)
+	}
+}
+
+// negotiate returns the format preferred by the passed Accept header,
+// only considering JSONP if allowed. JSON is returned when there is no
+// Accept header
+func negotiate(accept string, jsonp bool) (string, error) {
+	if strings.TrimSpace(accept) == "" {
+		return FormatJSON, nil
+	}
+
+	// Parse each media range and its quality
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		r := mediaRange{strings.ToLower(strings.TrimSpace(params[0])), 1}
+		for _, param := range params[1:] {
+			if kv := strings.SplitN(strings.TrimSpace(param), "=", 2); len(kv) == 2 && kv[0] == "q" {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					r.q = q
+				}
+			}
+		}
+		if r.q > 0 {
+			ranges = append(ranges, r)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	// Return the format of the first media range we can produce
+	for _, r := range ranges {
+		if format, ok := mediaTypeAliases[r.mediaType]; ok {
+			return format, nil
+		}
+		for _, f := range formats {
+			if f.format == FormatJSONP && !jsonp {
+				continue
+			}
+			if matchMediaType(r.mediaType, f.mediaType) {
+				return f.format, nil
+			}
+		}
+	}
+	return "", ErrNotAcceptable
+}
+
+// matchMediaType reports whether the passed media range, which may be
+// a wildcard, matches the passed media type
+func matchMediaType(mediaRange, mediaType string) bool {
+	switch {
+	case mediaRange == "*/*" || mediaRange == mediaType:
+		return true
+	case strings.HasSuffix(mediaRange, "/*"):
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*"))
+	default:
+		return false
+	}
+}
+
+// encodeBlob encodes the response with the passed encoder and writes
+// it with the passed content type
+func encodeBlob(c echo.Context, code int, contentType string, res interface{},
+	encode func(interface{}) ([]byte, error)) error {
+	b, err := encode(res)
+	if err != nil {
+		return err
+	}
+	return c.Blob(code, contentType, b)
+}
+
+// encodeCSV encodes the passed value as a header row followed by a row of
+// values, or a row per element when passed a slice. Nested structs are
+// flattened into dot separated column names
+func encodeCSV(res interface{}) ([]byte, error) {
+	return encodeTable(res, ',')
+}
+
+// encodeTSV encodes the passed value as encodeCSV does, separating the
+// values with tabs
+func encodeTSV(res interface{}) ([]byte, error) {
+	return encodeTable(res, '\t')
+}
+
+// encodeTable encodes the passed value as a table using the passed
+// separator
+func encodeTable(res interface{}, comma rune) ([]byte, error) {
+	v := reflect.Indirect(reflect.ValueOf(res))
+	elems := []reflect.Value{v}
+	if v.Kind() == reflect.Slice {
+		elems = elems[:0]
+		for i := 0; i < v.Len(); i++ {
+			elems = append(elems, reflect.Indirect(v.Index(i)))
+		}
+	}
+
+	// Write the header followed by each row
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = comma
+	for i, elem := range elems {
+		var header, row []string
+		flatten(elem, "", &header, &row)
+		if i == 0 {
+			w.Write(header)
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// flatten appends the column names and values of the passed value to
+// header and row
+func flatten(v reflect.Value, prefix string, header, row *[]string) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			*header, *row = append(*header, strings.TrimSuffix(prefix, ".")), append(*row, "")
+			return
+		}
+		v = v.Elem()
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, _ := m.MarshalText()
+		*header, *row = append(*header, strings.TrimSuffix(prefix, ".")), append(*row, string(b))
+		return
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range jsonFields(v.Type()) {
+			flatten(v.FieldByIndex(f.Index), prefix+f.name+".", header, row)
+		}
+		return
+	case reflect.Map, reflect.Slice, reflect.Array:
+		b, _ := json.Marshal(v.Interface())
+		*row = append(*row, string(b))
+	default:
+		*row = append(*row, fmt.Sprint(v.Interface()))
+	}
+	*header = append(*header, strings.TrimSuffix(prefix, "."))
+}
+
+// encodeYAML encodes the passed value as YAML using the names and order
+// of its JSON encoding
+func encodeYAML(res interface{}) ([]byte, error) {
+	b, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return nil, err
+	}
+	plainStyle(&node)
+	return yaml.Marshal(&node)
+}
+
+// plainStyle removes the JSON styling from a decoded YAML node tree
+func plainStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, n := range node.Content {
+		plainStyle(n)
+	}
+}
+
+// encodeMsgPack encodes the passed value as MessagePack using the names
+// of its JSON encoding
+func encodeMsgPack(res interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(res); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeProtobuf encodes the passed value as its protocol buffer message.
+// ErrNotAcceptable is returned for values without a message
+func encodeProtobuf(res interface{}) ([]byte, error) {
+	m, ok := toProto(res)
+	if !ok {
+		return nil, ErrNotAcceptable
+	}
+	return proto.Marshal(m)
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// encode runs FormatEncoder against a request for the passed path with
+// the passed Accept header
+func encode(path, accept string, res interface{}) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	handler := func(c echo.Context) error { return FormatEncoder(c, http.StatusOK, res) }
+	e.GET("/v1/health", handler)
+	e.GET("/v1/:format/:email", handler)
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNegotiate(t *testing.T) {
+	for accept, format := range map[string]string{
+		"":    FormatJSON,
+		"*/*": FormatJSON,
+		"text/html,application/xml;q=0.9,*/*;q=0.8":    FormatXML,
+		"application/x-yaml":                           FormatYAML,
+		"text/*":                                       FormatCSV,
+		"application/json;q=0.1, application/protobuf": FormatProtobuf,
+	} {
+		f, err := negotiate(accept, true)
+		assert.Nil(t, err, accept)
+		assert.Equal(t, format, f, accept)
+	}
+	_, err := negotiate("image/png, application/json;q=0", true)
+	assert.Equal(t, ErrNotAcceptable, err)
+
+	// JSONP is only negotiated when allowed
+	f, err := negotiate("application/javascript", true)
+	assert.Nil(t, err)
+	assert.Equal(t, FormatJSONP, f)
+	_, err = negotiate("application/javascript", false)
+	assert.Equal(t, ErrNotAcceptable, err)
+}
+
+func TestFormatEncoderNotAcceptable(t *testing.T) {
+	rec := encode("/v1/health", "image/png", &Health{Status: "OK"})
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	assert.Equal(t, "{\"message\":\"None of the accepted content types are supported\"}\n", rec.Body.String())
+}
+
+func TestFormatEncoderCSV(t *testing.T) {
+	rec := encode("/v1/csv/test", "", &LookupV2{Lookup: Lookup{Address: "a@b.com", Domain: "b.com"},
+		Status: "deliverable", Timings: TimingsV2{Total: 5}})
+	assert.Equal(t, MIMETextCSV+"; charset=UTF-8", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "address,username,domain,md5Hash,validFormat,deliverable,fullInbox,"+
+		"hostExists,catchAll,status,reason,score,timings.total,timings.parse,timings.mx,"+
+		"timings.dial,timings.hello,timings.mail,timings.catchAll,timings.rcpt,timings.quit\n"+
+		"a@b.com,,b.com,,false,false,false,false,false,deliverable,,0,5,0,0,0,0,0,0,0,0\n",
+		rec.Body.String())
+}
+
+func TestFormatEncoderYAML(t *testing.T) {
+	rec := encode("/v1/health", "application/yaml", &Health{Status: "true"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "status: \"true\"\n", rec.Body.String())
+	assert.Equal(t, "Accept", rec.Header().Get(echo.HeaderVary))
+}
+
+func TestFormatEncoderMsgPack(t *testing.T) {
+	rec := encode("/v1/msgpack/test", "", &Lookup{Address: "a@b.com", Deliverable: true})
+	var res map[string]interface{}
+	assert.Nil(t, msgpack.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "a@b.com", res["address"])
+	assert.Equal(t, true, res["deliverable"])
+}
+
+func TestFormatEncoderProtobuf(t *testing.T) {
+	rec := encode("/v1/protobuf/test", "", &Lookup{Address: "a@b.com", CatchAll: true})
+	var res pb.Lookup
+	assert.Nil(t, proto.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "a@b.com", res.Address)
+	assert.True(t, res.CatchAll)
+}
+
+func TestFormatEncoderErrors(t *testing.T) {
+	rec := encode("/v1/xml/test", "", &struct{}{})
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = encode("/v1/protobuf/test", "", &struct{}{})
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+
+	rec = encode("/v1/jsonp/test", "", &Health{Status: "OK"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "{\"message\":\"Missing callback queryparam\"}\n", rec.Body.String())
+
+	// v1 errors share the root element of v2 errors in XML
+	rec = encode("/v1/xml/test", "", &errorBody{Message: "Too Many Requests"})
+	assert.Contains(t, rec.Body.String(), "<error><message>Too Many Requests</message></error>")
+}
+
+func TestFormatEncoderJSONPDisabled(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	e.GET("/v1/:format/:email", func(c echo.Context) error {
+		c.Set(DisableJSONPKey, true)
+		return FormatEncoder(c, http.StatusOK, &Health{Status: "OK"})
+	})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/jsonp/test?callback=cb", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "{\"message\":\"Unsupported format specified\"}\n", rec.Body.String())
+}
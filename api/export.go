@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/jobs"
+	"github.com/sdwolfe32/trumail/verifier"
+)
+
+// flushRows is the number of exported rows written between flushes
+const flushRows = 1000
+
+// exportColumns are the columns appended to each row of an exported job
+var exportColumns = []string{"status", "reason", "deliverable", "catchAll",
+	"fullInbox", "hostExists", "score"}
+
+// exportJob streams the rows of the job as a CSV, or TSV when comma is a
+// tab, with the exportColumns appended. Rows of an uploaded table are
+// exported as uploaded and other rows as a single email column. The
+// appended columns are left blank on rows not yet verified
+func exportJob(c echo.Context, m *jobs.Manager, job *jobs.Job, contentType string, comma rune) error {
+	header := job.Header
+	if header == nil {
+		header = []string{"email"}
+	}
+
+	// Write the header and then each row as it's read
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, contentType+"; charset=UTF-8")
+	res.Header().Set("X-Powered-By", "Trumail")
+	res.WriteHeader(http.StatusOK)
+	w := csv.NewWriter(res)
+	w.Comma = comma
+	w.Write(append(header[:len(header):len(header)], exportColumns...))
+	var n int
+	err := m.Rows(job.ID, func(row *jobs.Row) error {
+		record := row.Record
+		if record == nil {
+			record = []string{row.Email}
+		}
+		if err := w.Write(append(record, exportValues(row)...)); err != nil {
+			return err
+		}
+		if n++; n%flushRows == 0 {
+			w.Flush()
+			res.Flush()
+		}
+		return w.Error()
+	})
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	return err
+}
+
+// exportValues returns the values of the exportColumns for the row
+func exportValues(row *jobs.Row) []string {
+	if !row.Done() {
+		return make([]string, len(exportColumns))
+	}
+	l, err := row.Lookup, row.Err()
+	status, reason := verifier.Outcome(l, err)
+	return []string{status, reason, strconv.FormatBool(l.Deliverable),
+		strconv.FormatBool(l.CatchAll), strconv.FormatBool(l.FullInbox),
+		strconv.FormatBool(l.HostExists), strconv.Itoa(verifier.Score(l, err))}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+)
+
+// xmlNameType is the type of the XMLName field on XML encoded types
+var xmlNameType = reflect.TypeOf(xml.Name{})
+
+// jsonField is an exported struct field along with the name given to it
+// by its json tag
+type jsonField struct {
+	reflect.StructField
+	name      string
+	omitEmpty bool
+}
+
+// jsonFields returns the fields of the passed struct type as they're
+// named when encoded as JSON, flattening any embedded structs. The
+// XMLName field is never returned
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		switch {
+		case f.Type == xmlNameType:
+			continue
+		case f.Anonymous && f.Type.Kind() == reflect.Struct:
+			for _, inner := range jsonFields(f.Type) {
+				inner.Index = append([]int{i}, inner.Index...)
+				fields = append(fields, inner)
+			}
+			continue
+		case f.PkgPath != "":
+			continue // Unexported
+		}
+
+		// Name the field after its json tag
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{f, name,
+			len(tag) > 1 && tag[1] == "omitempty"})
+	}
+	return fields
+}
+
+// xmlName returns the element name given to the passed struct type by
+// the tag on its XMLName field, if any
+func xmlName(t reflect.Type) string {
+	if f, ok := t.FieldByName("XMLName"); ok && f.Type == xmlNameType {
+		return strings.Split(f.Tag.Get("xml"), ",")[0]
+	}
+	return ""
+}
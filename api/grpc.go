@@ -0,0 +1,309 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/health"
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/pb"
+	"github.com/sdwolfe32/trumail/ratelimit"
+	"github.com/sdwolfe32/trumail/verifier"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// MetadataAuthToken is the gRPC metadata key holding the auth token
+const MetadataAuthToken = "x-auth-token"
+
+// grpcServer implements the Trumail gRPC service
+type grpcServer struct {
+	pb.UnimplementedTrumailServer
+	v                *verifier.Verifier
+	limiter          grpcLimit
+	limit, workers   int
+	errTooManyEmails error
+}
+
+// NewGRPCServer generates a new gRPC server serving the Trumail service
+// along with the gRPC health protocol, which reports the readiness of the
+// passed Monitor or always serving without one. Batches accept up to limit
+// emails, verifying at most workers domains concurrently. Every call but
+// health checks must carry the token of a key granted the scope of the
+// method in its metadata, and is counted against the limits of the passed
+// Limiter, if any, along with every email of a batch
+func NewGRPCServer(v *verifier.Verifier, a keys.Authenticator, l *ratelimit.Limiter,
+	limit, workers int, m *health.Monitor, opts ...grpc.ServerOption) *grpc.Server {
+	auth, limiter := grpcAuth{a}, grpcLimit{l}
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(auth.unary, limiter.unary),
+		grpc.ChainStreamInterceptor(auth.stream, limiter.stream))
+	s := grpc.NewServer(opts...)
+	pb.RegisterTrumailServer(s, &grpcServer{
+		v:       v,
+		limiter: limiter,
+		limit:   limit,
+		workers: workers,
+		errTooManyEmails: echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Too many emails, at most %d are allowed", limit)),
+	})
+
+	// Report every service as serving while the Monitor is ready
+	h := grpchealth.NewServer()
+	setServing := func(ready bool) {
+		status := healthpb.HealthCheckResponse_SERVING
+		if !ready {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		h.SetServingStatus("", status)
+		h.SetServingStatus(pb.Trumail_ServiceDesc.ServiceName, status)
+	}
+	if m != nil {
+		m.Notify(setServing)
+	} else {
+		setServing(true)
+	}
+	healthpb.RegisterHealthServer(s, h)
+	return s
+}
+
+// Verify performs a single email verification and returns a fully
+// populated lookup or the LookupError it failed with
+func (s *grpcServer) Verify(ctx context.Context, req *pb.VerifyRequest) (*pb.Lookup, error) {
+	if strings.TrimSpace(req.Email) == "" {
+		return nil, grpcError(ErrMissingEmail)
+	}
+	opts, err := newLookupOptions(req.Options).options()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	ctx, span := tracer.Start(ctx, "api.Verify")
+	defer span.End()
+	lookup, err := s.v.VerifyOptions(ctx, req.Email, opts)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return newLookup(lookup).Proto(), nil
+}
+
+// VerifyBatch verifies every email in the request, streaming each lookup as
+// soon as it completes. The remaining work is cancelled if the client
+// disconnects
+func (s *grpcServer) VerifyBatch(req *pb.VerifyBatchRequest,
+	stream grpc.ServerStreamingServer[pb.StreamLookup]) error {
+	if err := checkEmails(req.Emails, s.limit, s.errTooManyEmails); err != nil {
+		return grpcError(err)
+	}
+	opts, err := newLookupOptions(req.Options).options()
+	if err != nil {
+		return grpcError(err)
+	}
+	if err := s.limiter.charge(stream.Context(), len(req.Emails)-1); err != nil {
+		return err
+	}
+
+	ctx, span := tracer.Start(stream.Context(), "api.VerifyBatch")
+	defer span.End()
+	span.SetAttributes(attribute.Int("trumail.batch_size", len(req.Emails)))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for r := range s.v.VerifyBatch(ctx, req.Emails, opts, s.workers) {
+		l := newBatchLookup(r.Lookup, r.Err)
+		if err := stream.Send(&pb.StreamLookup{
+			Index:  int32(r.Index),
+			Lookup: &pb.BatchLookup{Lookup: l.Lookup.Proto(), Error: l.Error, Code: l.Code},
+		}); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// newLookupOptions converts the optional protocol buffer options of a
+// request to LookupOptions
+func newLookupOptions(o *pb.LookupOptions) *LookupOptions {
+	if o == nil {
+		return &LookupOptions{}
+	}
+	return &LookupOptions{
+		Timeout:      int(o.Timeout),
+		Retries:      int(o.Retries),
+		SkipCatchAll: o.SkipCatchAll,
+	}
+}
+
+// grpcError converts an API or lookup error to its gRPC status. Failed
+// lookups are INTERNAL, matching the 500 of the v1 API, and carry the
+// LookupError as a detail
+func grpcError(err error) error {
+	switch e := err.(type) {
+	case *verifier.LookupError:
+		st := status.New(codes.Internal, e.Message)
+		if ds, derr := st.WithDetails(&pb.LookupError{Message: e.Message,
+			Details: e.Details}); derr == nil {
+			st = ds
+		}
+		return st.Err()
+	case *echo.HTTPError:
+		code := codes.Internal
+		switch e.Code {
+		case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+			code = codes.InvalidArgument
+		case http.StatusUnauthorized:
+			code = codes.Unauthenticated
+		case http.StatusForbidden:
+			code = codes.PermissionDenied
+		}
+		return status.Error(code, fmt.Sprint(e.Message))
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// grpcScopes maps each gRPC method to the scope required to call it
+var grpcScopes = map[string]string{
+	pb.Trumail_Verify_FullMethodName:      keys.ScopeLookup,
+	pb.Trumail_VerifyBatch_FullMethodName: keys.ScopeBatch,
+}
+
+// grpcAuth authorizes gRPC calls with an Authenticator
+type grpcAuth struct{ keys.Authenticator }
+
+// unary is a unary interceptor asserting the auth token of each call
+func (a grpcAuth) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// stream is a stream interceptor asserting the auth token of each call
+func (a grpcAuth) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	ctx, err := a.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &serverStream{ss, ctx})
+}
+
+// authorize asserts the auth token, or Bearer authorization, in the
+// metadata of a call to the passed method belongs to a key granted its
+// scope, returning a context carrying the key. Methods without a scope,
+// such as health checks, are always allowed
+func (a grpcAuth) authorize(ctx context.Context, method string) (context.Context, error) {
+	scope, ok := grpcScopes[method]
+	if !ok {
+		return ctx, nil
+	}
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(MetadataAuthToken); len(values) > 0 {
+			token = values[0]
+		} else if values := md.Get("authorization"); len(values) > 0 {
+			token = keys.BearerToken(values[0])
+		}
+	}
+	key, err := keys.Authorize(a, token, scope)
+	if err != nil {
+		return ctx, grpcError(err)
+	}
+	if key != nil {
+		ctx = keys.WithKey(ctx, key)
+	}
+	return ctx, nil
+}
+
+// grpcLimit counts gRPC calls against the limits of a Limiter
+type grpcLimit struct{ *ratelimit.Limiter }
+
+// unary is a unary interceptor counting each call against the limits
+func (l grpcLimit) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	if err := l.allow(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// stream is a stream interceptor counting each call against the limits
+func (l grpcLimit) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	if err := l.allow(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// allow counts a call to the passed method against the limits of the key
+// carried by its context and of its peer IP. Methods without a scope,
+// such as health checks, aren't limited
+func (l grpcLimit) allow(ctx context.Context, method string) error {
+	if _, ok := grpcScopes[method]; !ok || l.Limiter == nil {
+		return nil
+	}
+	res, err := l.Allow(keys.FromContext(ctx), peerIP(ctx))
+	return limitError(res, err)
+}
+
+// charge counts n more emails verified by a call against its quotas
+func (l grpcLimit) charge(ctx context.Context, n int) error {
+	if n < 1 || l.Limiter == nil {
+		return nil
+	}
+	res, err := l.Charge(keys.FromContext(ctx), peerIP(ctx), n)
+	return limitError(res, err)
+}
+
+// limitError returns RESOURCE_EXHAUSTED if the Result was refused, carrying
+// the delay until the call may be retried as a RetryInfo detail
+func limitError(res *ratelimit.Result, err error) error {
+	if err != nil {
+		return grpcError(err)
+	}
+	if res.Allowed {
+		return nil
+	}
+	st := status.New(codes.ResourceExhausted, res.Message())
+	if ds, derr := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(res.RetryAfter)}); derr == nil {
+		st = ds
+	}
+	return st.Err()
+}
+
+// peerIP returns the IP address of the client making a call
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	ip, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return ip
+}
+
+// serverStream is a grpc.ServerStream with a replaced context
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
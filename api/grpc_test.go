@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sdwolfe32/trumail/health"
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/pb"
+	"github.com/sdwolfe32/trumail/ratelimit"
+	"github.com/sdwolfe32/trumail/verifier"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// grpcClient serves a gRPC server accepting up to three emails per batch
+// and the token "secret", reporting the readiness of the passed Monitor,
+// returning a connection to it
+func grpcClient(t *testing.T, m *health.Monitor) *grpc.ClientConn {
+	return limitedGRPCClient(t, m, nil)
+}
+
+// limitedGRPCClient is grpcClient counting calls against the limits of
+// the passed Limiter
+func limitedGRPCClient(t *testing.T, m *health.Monitor, l *ratelimit.Limiter) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	s := NewGRPCServer(verifier.NewVerifier("localhost", "admin@localhost"),
+		keys.Static("secret"), l, 3, 2, m)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// authorized returns a context carrying the auth token
+func authorized() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), MetadataAuthToken, "secret")
+}
+
+func TestGRPCVerify(t *testing.T) {
+	client := pb.NewTrumailClient(grpcClient(t, nil))
+
+	lookup, err := client.Verify(authorized(), &pb.VerifyRequest{Email: "not-an-address"})
+	assert.Nil(t, err)
+	assert.Equal(t, "not-an-address", lookup.Address)
+	assert.False(t, lookup.ValidFormat)
+
+	for _, req := range []*pb.VerifyRequest{
+		{},
+		{Email: "a@b.com", Options: &pb.LookupOptions{Retries: 99}},
+	} {
+		_, err = client.Verify(authorized(), req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
+
+func TestGRPCVerifyBatch(t *testing.T) {
+	client := pb.NewTrumailClient(grpcClient(t, nil))
+
+	stream, err := client.VerifyBatch(authorized(),
+		&pb.VerifyBatchRequest{Emails: []string{"one", "two", "three"}})
+	assert.Nil(t, err)
+	seen := make(map[int32]string)
+	for {
+		l, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		assert.False(t, l.Lookup.Lookup.ValidFormat)
+		seen[l.Index] = l.Lookup.Lookup.Address
+	}
+	assert.Equal(t, map[int32]string{0: "one", 1: "two", 2: "three"}, seen)
+
+	// Rejects too many emails
+	stream, err = client.VerifyBatch(authorized(),
+		&pb.VerifyBatchRequest{Emails: []string{"a", "b", "c", "d"}})
+	assert.Nil(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCAuth(t *testing.T) {
+	conn := grpcClient(t, nil)
+
+	_, err := pb.NewTrumailClient(conn).Verify(context.Background(),
+		&pb.VerifyRequest{Email: "not-an-address"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Health checks don't require a token
+	res, err := healthpb.NewHealthClient(conn).Check(context.Background(),
+		&healthpb.HealthCheckRequest{Service: pb.Trumail_ServiceDesc.ServiceName})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	conn := limitedGRPCClient(t, nil, ratelimit.New(ratelimit.Config{Daily: 3}, ratelimit.NewMemory()))
+	client := pb.NewTrumailClient(conn)
+	verify := func() error {
+		_, err := client.Verify(authorized(), &pb.VerifyRequest{Email: "not-an-address"})
+		return err
+	}
+	exhausted := func(err error) {
+		st := status.Convert(err)
+		assert.Equal(t, codes.ResourceExhausted, st.Code())
+		assert.Contains(t, st.Message(), "Daily quota exceeded")
+		assert.Len(t, st.Details(), 1)
+		info, ok := st.Details()[0].(*errdetails.RetryInfo)
+		assert.True(t, ok)
+		assert.True(t, info.RetryDelay.AsDuration() > 0)
+	}
+	assert.Nil(t, verify())
+
+	// Every email of a batch is counted against the quotas
+	stream, err := client.VerifyBatch(authorized(),
+		&pb.VerifyBatchRequest{Emails: []string{"one", "two", "three"}})
+	assert.Nil(t, err)
+	_, err = stream.Recv()
+	exhausted(err)
+
+	assert.Nil(t, verify())
+	exhausted(verify())
+
+	// Health checks aren't limited
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(),
+		&healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+}
+
+func TestGRPCHealth(t *testing.T) {
+	m := health.NewMonitor(time.Minute, time.Second)
+	failing := errors.New("unreachable")
+	m.Add("dns", func(context.Context) error { return failing })
+	conn := grpcClient(t, m)
+	check := func() healthpb.HealthCheckResponse_ServingStatus {
+		res, err := healthpb.NewHealthClient(conn).Check(context.Background(),
+			&healthpb.HealthCheckRequest{Service: pb.Trumail_ServiceDesc.ServiceName})
+		assert.Nil(t, err)
+		return res.Status
+	}
+
+	// The service only serves once every component is healthy
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check())
+	m.CheckAll(context.Background())
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check())
+	failing = nil
+	m.CheckAll(context.Background())
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, check())
+}
+
+func TestGRPCLookupError(t *testing.T) {
+	st := status.Convert(grpcError(&verifier.LookupError{Message: verifier.ErrBlocked,
+		Details: "550 blocked"}))
+	assert.Equal(t, codes.Internal, st.Code())
+	assert.Equal(t, verifier.ErrBlocked, st.Message())
+	assert.Len(t, st.Details(), 1)
+	le, ok := st.Details()[0].(*pb.LookupError)
+	assert.True(t, ok)
+	assert.Equal(t, "550 blocked", le.Details)
+}
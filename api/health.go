@@ -1,20 +1,56 @@
 package api
 
 import (
+	"encoding/xml"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/health"
+	"github.com/sdwolfe32/trumail/pb"
 )
 
-// Health is a healthcheck response body
+// Health is a healthcheck response body. Readiness checks also report
+// the health of each component
 type Health struct {
-	Status string `json:"status"`
+	XMLName    xml.Name            `json:"-" xml:"health"`
+	Status     string              `json:"status" xml:"status"`
+	Components []*health.Component `json:"components,omitempty" xml:"components>component,omitempty"`
 }
 
-// HealthHandler returns a HealthResponse indicating the
-// current health state of the service
-func HealthHandler() echo.HandlerFunc {
+// Proto converts the Health to its protocol buffer message
+func (h *Health) Proto() *pb.Health {
+	components := make([]*pb.HealthComponent, len(h.Components))
+	for i, c := range h.Components {
+		components[i] = &pb.HealthComponent{Name: c.Name, Status: c.Status, Detail: c.Detail}
+		if !c.Checked.IsZero() {
+			components[i].Checked = c.Checked.Format(time.RFC3339)
+		}
+	}
+	return &pb.Health{Status: h.Status, Components: components}
+}
+
+// HealthHandler returns a Health indicating whether the service is ready
+// to serve traffic along with the health of each component checked by
+// the Monitor, responding 503 when it isn't. Without a Monitor the
+// service is always reported healthy
+func HealthHandler(m *health.Monitor) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if m == nil {
+			return FormatEncoder(c, http.StatusOK, &Health{Status: health.StatusOK})
+		}
+		res, code := &Health{Status: health.StatusOK, Components: m.Components()}, http.StatusOK
+		if !m.Ready() {
+			res.Status, code = health.StatusDown, http.StatusServiceUnavailable
+		}
+		return FormatEncoder(c, code, res)
+	}
+}
+
+// LivenessHandler returns a Health indicating the service is running,
+// without checking any of its dependencies
+func LivenessHandler() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		return c.JSON(http.StatusOK, &Health{"OK"})
+		return FormatEncoder(c, http.StatusOK, &Health{Status: health.StatusOK})
 	}
 }
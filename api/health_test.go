@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthHandler(t *testing.T) {
+	m := health.NewMonitor(time.Minute, time.Second)
+	var storageErr error
+	m.Add("dns", func(context.Context) error { return nil })
+	m.Add("storage.jobs", func(context.Context) error { return storageErr })
+	e := echo.New()
+	e.GET("/v1/health", HealthHandler(m))
+	e.GET("/v1/health/live", LivenessHandler())
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	// The server isn't ready until its components have been checked
+	rec := get("/v1/health")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"status":"DOWN"`)
+	assert.Contains(t, rec.Body.String(), `{"name":"dns","status":"UNKNOWN"`)
+
+	m.CheckAll(context.Background())
+	rec = get("/v1/health")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `{"status":"OK","components":[{"name":"dns","status":"OK"`)
+
+	storageErr = errors.New("timeout")
+	m.CheckAll(context.Background())
+	rec = get("/v1/health")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), `{"name":"storage.jobs","status":"DOWN","detail":"timeout"`)
+
+	// Liveness doesn't depend on any component
+	rec = get("/v1/health/live")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"OK"}`, rec.Body.String())
+}
+
+func TestHealthHandlerNoMonitor(t *testing.T) {
+	e := echo.New()
+	e.GET("/v1/health", HealthHandler(nil))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/health", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"OK"}`, rec.Body.String())
+}
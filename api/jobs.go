@@ -0,0 +1,268 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/jobs"
+	"github.com/sdwolfe32/trumail/keys"
+)
+
+var (
+	// ErrJobNotFound is thrown when a requested job doesn't exist
+	ErrJobNotFound = echo.NewHTTPError(http.StatusNotFound, "Job not found")
+	// ErrMissingColumn is thrown when the email column of an uploaded
+	// table can't be found
+	ErrMissingColumn = echo.NewHTTPError(http.StatusBadRequest,
+		"Missing email column")
+)
+
+// Job reports the progress of a bulk verification job
+type Job struct {
+	XMLName       xml.Name  `json:"-" xml:"job"`
+	ID            string    `json:"id" xml:"id"`
+	Status        string    `json:"status" xml:"status"`
+	Total         int       `json:"total" xml:"total"`
+	Processed     int       `json:"processed" xml:"processed"`
+	Deliverable   int       `json:"deliverable" xml:"deliverable"`
+	Undeliverable int       `json:"undeliverable" xml:"undeliverable"`
+	Unknown       int       `json:"unknown" xml:"unknown"`
+	Created       time.Time `json:"created" xml:"created"`
+	Updated       time.Time `json:"updated" xml:"updated"`
+
+	// Callback reports the delivery of the jobs callback, if any
+	Callback *JobCallback `json:"callback,omitempty" xml:"callback,omitempty"`
+}
+
+// JobRequest is the JSON, XML or form encoded body accepted by
+// CreateJobHandler
+type JobRequest struct {
+	XMLName     xml.Name `json:"-" form:"-" xml:"jobRequest"`
+	Emails      []string `json:"emails" form:"emails" xml:"email"`
+	CallbackURL string   `json:"callbackUrl" form:"callbackUrl" xml:"callbackUrl"`
+	LookupOptions
+}
+
+// CreateJobHandler creates a job verifying up to limit emails in the
+// background from a JSON, XML or form encoded JobRequest, a plain text
+// body of one email per line or a CSV or TSV table. Options and the
+// callbackUrl for plain text and tables are read from the queryparams,
+// along with the column holding the emails and whether the table has a
+// header
+func CreateJobHandler(m *jobs.Manager, limit int) echo.HandlerFunc {
+	errTooManyEmails := echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("Too many emails, at most %d are allowed", limit))
+	return func(c echo.Context) error {
+		src, options, callbackURL, err := jobSource(c)
+		if err != nil {
+			return err
+		}
+		return createJob(c, m, src, options, callbackURL, limit, errTooManyEmails)
+	}
+}
+
+// createJob creates a job verifying every email read from the Source,
+// responding with the queued job, or deleting it if the quotas of the
+// request can't cover every email
+func createJob(c echo.Context, m *jobs.Manager, src jobs.Source, options LookupOptions,
+	callbackURL string, limit int, errTooManyEmails error) error {
+	opts, err := options.options()
+	if err != nil {
+		return err
+	}
+	cb, err := newCallback(c, m, callbackURL)
+	if err != nil {
+		return err
+	}
+
+	// Create the job, storing every email before responding
+	job, err := m.Create(c.Request().Context(), opts, src, limit, cb)
+	switch err {
+	case nil:
+	case jobs.ErrNoRows:
+		return ErrMissingEmails
+	case jobs.ErrTooManyRows:
+		return errTooManyEmails
+	default:
+		return err
+	}
+
+	// Queue the job once the quotas cover every email, deleting it if not
+	if err := charge(c, job.Total-1); err != nil {
+		if derr := m.Delete(job.ID); derr != nil {
+			return derr
+		}
+		return err
+	}
+	m.Queue(job.ID)
+	return FormatEncoder(c, http.StatusAccepted, newJob(job))
+}
+
+// jobSource returns the Source of a new jobs rows, its options and its
+// callback URL based on the content type of the request
+func jobSource(c echo.Context) (jobs.Source, LookupOptions, string, error) {
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+	callbackURL := c.QueryParam("callbackUrl")
+	comma := ','
+	switch {
+	case strings.HasPrefix(contentType, echo.MIMETextPlain):
+		options, err := queryOptions(c)
+		return jobs.Lines(c.Request().Body), options, callbackURL, err
+	case strings.HasPrefix(contentType, MIMETextTSV):
+		comma = '\t'
+		fallthrough
+	case strings.HasPrefix(contentType, MIMETextCSV):
+		options, err := queryOptions(c)
+		if err != nil {
+			return nil, options, "", err
+		}
+		header := true
+		if s := c.QueryParam("header"); s != "" {
+			if header, err = strconv.ParseBool(s); err != nil {
+				return nil, options, "", ErrInvalidOptions
+			}
+		}
+		src, err := jobs.Table(c.Request().Body, comma, c.QueryParam("column"), header)
+		switch err {
+		case nil:
+			return src, options, callbackURL, nil
+		case jobs.ErrNoRows:
+			return nil, options, "", ErrMissingEmails
+		case jobs.ErrMissingColumn:
+			return nil, options, "", ErrMissingColumn
+		default:
+			return nil, options, "", echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	default:
+		var req JobRequest
+		if err := c.Bind(&req); err != nil {
+			return nil, req.LookupOptions, "", err
+		}
+		return jobs.Emails(req.Emails), req.LookupOptions, req.CallbackURL, nil
+	}
+}
+
+// JobHandler returns the progress of the job in the path
+func JobHandler(m *jobs.Manager) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		job, err := ownJob(c, m)
+		if err != nil {
+			return err
+		}
+		return FormatEncoder(c, http.StatusOK, newJob(job))
+	}
+}
+
+// CancelJobHandler cancels the job in the path, keeping the lookups of any
+// emails already verified
+func CancelJobHandler(m *jobs.Manager) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		job, err := ownJob(c, m)
+		if err != nil {
+			return err
+		}
+		if job, err = m.Cancel(job.ID); err != nil {
+			return jobError(err)
+		}
+		return FormatEncoder(c, http.StatusOK, newJob(job))
+	}
+}
+
+// JobResultsHandler returns the lookups of every email verified by the job
+// in the path so far, in the order the emails were uploaded. CSV and TSV
+// results are streamed as every uploaded row with the outcome of its
+// lookup appended
+func JobResultsHandler(m *jobs.Manager) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		job, err := ownJob(c, m)
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(c.Param("format")) {
+		case FormatCSV:
+			return exportJob(c, m, job, MIMETextCSV, ',')
+		case FormatTSV:
+			return exportJob(c, m, job, MIMETextTSV, '\t')
+		}
+		lookups := BatchLookups{}
+		err = m.Rows(job.ID, func(row *jobs.Row) error {
+			if row.Done() {
+				lookups = append(lookups, newBatchLookup(row.Lookup, row.Err()))
+			}
+			return nil
+		})
+		if err != nil {
+			return jobError(err)
+		}
+		return FormatEncoder(c, http.StatusOK, lookups)
+	}
+}
+
+// queryOptions reads the LookupOptions from the requests queryparams
+func queryOptions(c echo.Context) (LookupOptions, error) {
+	var o LookupOptions
+	var err error
+	for name, v := range map[string]*int{"timeout": &o.Timeout, "retries": &o.Retries} {
+		if s := c.QueryParam(name); s != "" {
+			if *v, err = strconv.Atoi(s); err != nil {
+				return o, ErrInvalidOptions
+			}
+		}
+	}
+	if s := c.QueryParam("skipCatchAll"); s != "" {
+		if o.SkipCatchAll, err = strconv.ParseBool(s); err != nil {
+			return o, ErrInvalidOptions
+		}
+	}
+	return o, nil
+}
+
+// jobError converts an error from the jobs Manager to its API error
+func jobError(err error) error {
+	if err == jobs.ErrNotFound {
+		return ErrJobNotFound
+	}
+	return err
+}
+
+// ownJob returns the job in the path when it was created with the API key
+// of the request, or anonymously by an anonymous request. Jobs of other
+// keys are reported as not found unless the key is an admin
+func ownJob(c echo.Context, m *jobs.Manager) (*jobs.Job, error) {
+	job, err := m.Job(c.Param("id"))
+	if err != nil {
+		return nil, jobError(err)
+	}
+	var keyID string
+	if key := keys.FromContext(c.Request().Context()); key != nil {
+		if key.HasScope(keys.ScopeAdmin) {
+			return job, nil
+		}
+		keyID = key.ID
+	}
+	if job.KeyID != keyID {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// newJob converts a jobs.Job to its API representation
+func newJob(j *jobs.Job) *Job {
+	return &Job{
+		ID:            j.ID,
+		Status:        j.Status,
+		Total:         j.Total,
+		Processed:     j.Processed,
+		Deliverable:   j.Deliverable,
+		Undeliverable: j.Undeliverable,
+		Unknown:       j.Unknown,
+		Created:       j.Created,
+		Updated:       j.Updated,
+		Callback:      newJobCallback(j.Callback),
+	}
+}
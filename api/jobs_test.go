@@ -0,0 +1,351 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/jobs"
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/ratelimit"
+	"github.com/sdwolfe32/trumail/verifier"
+	"github.com/sdwolfe32/trumail/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+// jobsServer generates a router serving the job routes from a started
+// Manager accepting up to three emails per job, delivering callbacks to
+// the local test servers
+func jobsServer(t *testing.T) *echo.Echo {
+	return newJobsServer(t, true)
+}
+
+// newJobsServer generates a router serving the job routes from a started
+// Manager accepting up to three emails per job, delivering callbacks to
+// private addresses if allowed
+func newJobsServer(t *testing.T, allowPrivate bool) *echo.Echo {
+	store, err := jobs.Open(filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := jobs.NewManager(store, verifier.NewVerifier("localhost", "admin@localhost"),
+		slog.New(slog.NewTextHandler(io.Discard, nil)), 2)
+	client := webhook.NewClient()
+	client.Backoff, client.AllowPrivate = time.Millisecond, allowPrivate
+	m.SetWebhooks(client, JobPayload)
+	assert.Nil(t, m.Start(1))
+	t.Cleanup(func() {
+		m.Close()
+		store.Close()
+	})
+
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if secret := c.Request().Header.Get("X-Secret"); secret != "" {
+				c.Set(WebhookSecretKey, secret)
+			}
+			if publicURL := c.Request().Header.Get("X-Public-URL"); publicURL != "" {
+				c.Set(PublicURLKey, publicURL)
+			}
+			if quota := c.Request().Header.Get("X-Quota"); quota != "" {
+				remaining, _ := strconv.Atoi(quota)
+				c.Set(ratelimit.ChargeKey, func(n int) error {
+					if n > remaining {
+						return echo.NewHTTPError(http.StatusTooManyRequests, "Daily quota exceeded")
+					}
+					remaining -= n
+					return nil
+				})
+			}
+			if id := c.Request().Header.Get("X-Key"); id != "" {
+				key := &keys.Key{ID: id, Scopes: []string{keys.ScopeBatch},
+					WebhookSecret: c.Request().Header.Get("X-Key-Secret")}
+				if id == "admin" {
+					key.Scopes = []string{keys.ScopeAdmin}
+				}
+				c.SetRequest(c.Request().WithContext(keys.WithKey(c.Request().Context(), key)))
+			}
+			return next(c)
+		}
+	})
+	e.POST("/v1/jobs/:format", CreateJobHandler(m, 3))
+	e.POST("/v1/async/:format", AsyncLookupHandler(m))
+	e.GET("/v1/jobs/:format/:id", JobHandler(m))
+	e.DELETE("/v1/jobs/:format/:id", CancelJobHandler(m))
+	e.GET("/v1/jobs/:format/:id/results", JobResultsHandler(m))
+	return e
+}
+
+// serve performs a request against the router
+func serve(e *echo.Echo, method, path, contentType, body string) *httptest.ResponseRecorder {
+	return serveSecret(e, method, path, contentType, body, "")
+}
+
+// serveKey performs a request against the router authenticated as the key
+// with the passed ID, granted the batch scope or admin when named admin
+func serveKey(e *echo.Echo, method, path, contentType, body, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-Key", id)
+	if contentType != "" {
+		req.Header.Set(echo.HeaderContentType, contentType)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// serveSecret performs a request against the router using the passed
+// webhook secret
+func serveSecret(e *echo.Echo, method, path, contentType, body, secret string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-Secret", secret)
+	if contentType != "" {
+		req.Header.Set(echo.HeaderContentType, contentType)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestJobHandlers(t *testing.T) {
+	e := jobsServer(t)
+
+	rec := serve(e, http.MethodPost, "/v1/jobs/json?retries=1", echo.MIMETextPlain, "one\ntwo\n")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	var job Job
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	assert.Equal(t, 2, job.Total)
+
+	// Wait for the job to complete
+	for i := 0; i < 100 && job.Status != jobs.StatusCompleted; i++ {
+		time.Sleep(10 * time.Millisecond)
+		rec = serve(e, http.MethodGet, "/v1/jobs/json/"+job.ID, "", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	}
+	assert.Equal(t, jobs.StatusCompleted, job.Status)
+	assert.Equal(t, 2, job.Processed)
+
+	rec = serve(e, http.MethodGet, "/v1/jobs/json/"+job.ID+"/results", "", "")
+	var lookups []BatchLookup
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &lookups))
+	assert.Len(t, lookups, 2)
+	assert.Equal(t, "one", lookups[0].Address)
+	assert.Equal(t, "two", lookups[1].Address)
+
+	rec = serve(e, http.MethodDelete, "/v1/jobs/json/"+job.ID, "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"status":"completed"`)
+
+	rec = serve(e, http.MethodGet, "/v1/jobs/csv/"+job.ID, "", "")
+	assert.Contains(t, rec.Body.String(), "id,status,total,processed,deliverable,"+
+		"undeliverable,unknown,created,updated,callback\n"+job.ID+",completed,2,2,0,2,0,")
+}
+
+func TestJobQuota(t *testing.T) {
+	e := jobsServer(t)
+	create := func(quota string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/jobs/json", strings.NewReader("one\ntwo\nthree\n"))
+		req.Header.Set(echo.HeaderContentType, echo.MIMETextPlain)
+		req.Header.Set("X-Quota", quota)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Every email beyond the first is charged against the quotas
+	assert.Equal(t, http.StatusAccepted, create("2").Code)
+	rec := create("1")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Daily quota exceeded")
+}
+
+func TestJobHandlerErrors(t *testing.T) {
+	e := jobsServer(t)
+	for _, r := range []struct {
+		method, path, contentType, body string
+		code                            int
+	}{
+		{http.MethodPost, "/v1/jobs/json", echo.MIMEApplicationJSON, `{"emails":[]}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/jobs/json", echo.MIMETextPlain, "\n", http.StatusBadRequest},
+		{http.MethodPost, "/v1/jobs/json?retries=x", echo.MIMETextPlain, "a", http.StatusBadRequest},
+		{http.MethodPost, "/v1/jobs/json", echo.MIMETextPlain, "a\nb\nc\nd", http.StatusRequestEntityTooLarge},
+		{http.MethodGet, "/v1/jobs/json/missing", "", "", http.StatusNotFound},
+		{http.MethodDelete, "/v1/jobs/json/missing", "", "", http.StatusNotFound},
+		{http.MethodGet, "/v1/jobs/json/missing/results", "", "", http.StatusNotFound},
+	} {
+		rec := serve(e, r.method, r.path, r.contentType, r.body)
+		assert.Equal(t, r.code, rec.Code, r.path)
+	}
+}
+
+func TestJobOwnership(t *testing.T) {
+	e := jobsServer(t)
+	rec := serveKey(e, http.MethodPost, "/v1/jobs/json", echo.MIMETextPlain, "one\n", "a")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	var job Job
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &job))
+
+	// Other keys and anonymous requests can't tell the job exists
+	for _, path := range []string{"/v1/jobs/json/" + job.ID, "/v1/jobs/json/" + job.ID + "/results",
+		"/v1/jobs/csv/" + job.ID + "/results"} {
+		assert.Equal(t, http.StatusNotFound, serveKey(e, http.MethodGet, path, "", "", "b").Code, path)
+		assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, path, "", "").Code, path)
+	}
+	rec = serveKey(e, http.MethodDelete, "/v1/jobs/json/"+job.ID, "", "", "b")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, `{"message":"Job not found"}`+"\n", rec.Body.String())
+
+	// The key that created it and admins can
+	for _, id := range []string{"a", "admin"} {
+		rec = serveKey(e, http.MethodGet, "/v1/jobs/json/"+job.ID, "", "", id)
+		assert.Equal(t, http.StatusOK, rec.Code, id)
+		rec = serveKey(e, http.MethodGet, "/v1/jobs/csv/"+job.ID+"/results", "", "", id)
+		assert.Equal(t, http.StatusOK, rec.Code, id)
+	}
+	rec = serveKey(e, http.MethodDelete, "/v1/jobs/json/"+job.ID, "", "", "a")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestJobTable(t *testing.T) {
+	e := jobsServer(t)
+
+	rec := serve(e, http.MethodPost, "/v1/jobs/json", MIMETextCSV,
+		"name,email\nJo,one\nAl,two\n")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	var job Job
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	assert.Equal(t, 2, job.Total)
+
+	for i := 0; i < 100 && job.Status != jobs.StatusCompleted; i++ {
+		time.Sleep(10 * time.Millisecond)
+		rec = serve(e, http.MethodGet, "/v1/jobs/json/"+job.ID, "", "")
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	}
+
+	rec = serve(e, http.MethodGet, "/v1/jobs/csv/"+job.ID+"/results", "", "")
+	assert.Equal(t, MIMETextCSV+"; charset=UTF-8", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "name,email,status,reason,deliverable,catchAll,fullInbox,hostExists,score\n"+
+		"Jo,one,undeliverable,invalid_format,false,false,false,false,0\n"+
+		"Al,two,undeliverable,invalid_format,false,false,false,false,0\n", rec.Body.String())
+
+	rec = serve(e, http.MethodGet, "/v1/jobs/tsv/"+job.ID+"/results", "", "")
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "name\temail\tstatus\t"))
+
+	rec = serve(e, http.MethodPost, "/v1/jobs/json", MIMETextTSV, "name\tphone\nJo\t1\n")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Missing email column")
+}
+
+func TestJobCallback(t *testing.T) {
+	events := make(chan JobEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Nil(t, webhook.Verify("secret", r.Header, body, time.Minute))
+		var event JobEvent
+		assert.Nil(t, json.Unmarshal(body, &event))
+		events <- event
+	}))
+	defer srv.Close()
+	e := jobsServer(t)
+
+	rec := serveSecret(e, http.MethodPost, "/v1/async/json", echo.MIMEApplicationJSON,
+		`{"email":"one","callbackUrl":"`+srv.URL+`"}`, "secret")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	var job Job
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	assert.Equal(t, 1, job.Total)
+	assert.Equal(t, jobs.CallbackPending, job.Callback.Status)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, "job.completed", event.Event)
+		assert.Equal(t, job.ID, event.Job.ID)
+		assert.Equal(t, 1, event.Job.Processed)
+		assert.Equal(t, "/v1/jobs/json/"+job.ID+"/results", event.Results)
+	case <-time.After(time.Second):
+		t.Fatal("callback wasn't delivered")
+	}
+
+	// The delivery is logged on the job
+	for i := 0; i < 100 && job.Callback.Status == jobs.CallbackPending; i++ {
+		time.Sleep(10 * time.Millisecond)
+		rec = serve(e, http.MethodGet, "/v1/jobs/json/"+job.ID, "", "")
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	}
+	assert.Equal(t, jobs.CallbackDelivered, job.Callback.Status)
+	assert.Len(t, job.Callback.Attempts, 1)
+	assert.Equal(t, http.StatusOK, job.Callback.Attempts[0].Status)
+	assert.NotContains(t, rec.Body.String(), "secret")
+}
+
+func TestJobCallbackKeySecret(t *testing.T) {
+	events := make(chan JobEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, webhook.ErrInvalidSignature,
+			webhook.Verify("global", r.Header, body, time.Minute))
+		assert.Nil(t, webhook.Verify("whsec_a", r.Header, body, time.Minute))
+		var event JobEvent
+		assert.Nil(t, json.Unmarshal(body, &event))
+		events <- event
+	}))
+	defer srv.Close()
+	e := jobsServer(t)
+
+	// Keys with a webhook secret of their own sign with it over the global
+	// one, linking to the results on the public URL rather than the Host
+	req := httptest.NewRequest(http.MethodPost, "/v1/async/json", strings.NewReader(
+		`{"email":"one","callbackUrl":"`+srv.URL+`"}`))
+	req.Host = "attacker.example.com"
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-Secret", "global")
+	req.Header.Set("X-Public-URL", "https://trumail.example.com/")
+	req.Header.Set("X-Key", "a")
+	req.Header.Set("X-Key-Secret", "whsec_a")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	var job Job
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	select {
+	case event := <-events:
+		assert.Equal(t, "https://trumail.example.com/v1/jobs/json/"+job.ID+"/results", event.Results)
+	case <-time.After(time.Second):
+		t.Fatal("callback wasn't delivered")
+	}
+}
+
+func TestJobCallbackErrors(t *testing.T) {
+	e := jobsServer(t)
+	for path, secret := range map[string]string{
+		"/v1/jobs/json?callbackUrl=ftp://example.com":     "secret",
+		"/v1/jobs/json?callbackUrl=example.com":           "secret",
+		"/v1/jobs/json?callbackUrl=http://93.184.216.34/": "",
+	} {
+		rec := serveSecret(e, http.MethodPost, path, echo.MIMETextPlain, "one", secret)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, path)
+	}
+
+	// Callbacks can't be sent to private addresses
+	e = newJobsServer(t, false)
+	for _, callbackURL := range []string{"http://127.0.0.1:8080/hook", "http://localhost/hook",
+		"http://10.0.0.1/hook", "http://169.254.169.254/latest/meta-data/", "http://[::1]/hook",
+		"http://0.0.0.0/hook"} {
+		rec := serveSecret(e, http.MethodPost, "/v1/jobs/json?callbackUrl="+url.QueryEscape(callbackURL),
+			echo.MIMETextPlain, "one", "secret")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, callbackURL)
+		assert.Contains(t, rec.Body.String(), "public address", callbackURL)
+	}
+}
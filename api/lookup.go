@@ -3,11 +3,34 @@ package api
 import (
 	"encoding/xml"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo"
 	"github.com/sdwolfe32/trumail/verifier"
+	"go.opentelemetry.io/otel"
 )
 
+const (
+	// MaxTimeout is the longest timeout a LookupRequest may set
+	MaxTimeout = 2 * time.Minute
+	// MaxRetries is the most retries a LookupRequest may set
+	MaxRetries = 5
+)
+
+var (
+	// ErrMissingEmail is thrown when a lookup request has no email
+	ErrMissingEmail = echo.NewHTTPError(http.StatusBadRequest,
+		"Missing email")
+	// ErrInvalidOptions is thrown when a lookup request sets options
+	// outside of their allowed ranges
+	ErrInvalidOptions = echo.NewHTTPError(http.StatusBadRequest,
+		"Invalid lookup options specified")
+)
+
+// tracer creates the spans covering each API handler
+var tracer = otel.Tracer("github.com/sdwolfe32/trumail/api")
+
 // Lookup contains all output data for an email verification Lookup
 type Lookup struct {
 	XMLName     xml.Name `json:"-" xml:"lookup"`
@@ -22,25 +45,93 @@ type Lookup struct {
 	CatchAll    bool     `json:"catchAll" xml:"catchAll"`
 }
 
+// LookupRequest is the JSON, XML or form encoded body accepted by
+// LookupPostHandler
+type LookupRequest struct {
+	XMLName xml.Name `json:"-" form:"-" xml:"lookupRequest"`
+	Email   string   `json:"email" form:"email" xml:"email"`
+	LookupOptions
+}
+
+// LookupOptions are the options accepted on lookup request bodies. Zero
+// valued options use the verifiers defaults
+type LookupOptions struct {
+	Timeout      int  `json:"timeout" form:"timeout" xml:"timeout"` // Seconds
+	Retries      int  `json:"retries" form:"retries" xml:"retries"`
+	SkipCatchAll bool `json:"skipCatchAll" form:"skipCatchAll" xml:"skipCatchAll"`
+}
+
+// options validates the options and returns them as verifier.Options
+func (r *LookupOptions) options() (verifier.Options, error) {
+	opts := verifier.DefaultOptions
+	timeout := time.Duration(r.Timeout) * time.Second
+	if timeout < 0 || timeout > MaxTimeout || r.Retries < 0 || r.Retries > MaxRetries {
+		return opts, ErrInvalidOptions
+	}
+	if timeout > 0 {
+		opts.Timeout = timeout
+	}
+	if r.Retries > 0 {
+		opts.Retries = r.Retries
+	}
+	opts.SkipCatchAll = r.SkipCatchAll
+	opts.Literal = true
+	return opts, nil
+}
+
 // LookupHandler performs a single email verification and returns
 // a fully populated lookup or an error
 func LookupHandler(v *verifier.Verifier) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		// Perform the unlimited verification
-		lookup, err := v.Verify(c.Param("email"))
+		return lookup(c, v, c.Param("email"), verifier.DefaultOptions)
+	}
+}
+
+// LookupPostHandler performs a single email verification on the address
+// in the request body, keeping it out of URLs and access logs, and returns
+// a fully populated lookup or an error
+func LookupPostHandler(v *verifier.Verifier) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req LookupRequest
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		if strings.TrimSpace(req.Email) == "" {
+			return ErrMissingEmail
+		}
+		opts, err := req.options()
 		if err != nil {
-			return FormatEncoder(c, http.StatusInternalServerError, err)
+			return err
 		}
-		return FormatEncoder(c, http.StatusOK, &Lookup{
-			Address:     lookup.Address.Address,
-			Username:    lookup.Username,
-			Domain:      lookup.Domain,
-			MD5Hash:     lookup.MD5Hash,
-			ValidFormat: lookup.ValidFormat,
-			Deliverable: lookup.Deliverable,
-			FullInbox:   lookup.FullInbox,
-			HostExists:  lookup.HostExists,
-			CatchAll:    lookup.CatchAll,
-		})
+		return lookup(c, v, req.Email, opts)
+	}
+}
+
+// lookup performs the verification for the lookup handlers and encodes
+// the result in the requested format
+func lookup(c echo.Context, v *verifier.Verifier, email string, opts verifier.Options) error {
+	ctx, span := tracer.Start(c.Request().Context(), "api.LookupHandler")
+	defer span.End()
+
+	// Perform the unlimited verification
+	lookup, err := v.VerifyOptions(ctx, email, opts)
+	if err != nil {
+		return FormatEncoder(c, http.StatusInternalServerError, err)
+	}
+	return FormatEncoder(c, http.StatusOK, newLookup(lookup))
+}
+
+// newLookup converts a verifier.Lookup to its API representation
+func newLookup(l *verifier.Lookup) *Lookup {
+	return &Lookup{
+		Address:     l.Address.Address,
+		Username:    l.Username,
+		Domain:      l.Domain,
+		MD5Hash:     l.MD5Hash,
+		ValidFormat: l.ValidFormat,
+		Deliverable: l.Deliverable,
+		FullInbox:   l.FullInbox,
+		HostExists:  l.HostExists,
+		CatchAll:    l.CatchAll,
 	}
 }
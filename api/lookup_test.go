@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/verifier"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupPostHandlerJSON(t *testing.T) {
+	e := echo.New()
+	e.POST("/v1/:format", LookupPostHandler(verifier.NewVerifier("localhost", "admin@localhost")))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/json",
+		strings.NewReader(`{"email":"not-an-address"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"validFormat":false`)
+}
+
+func TestLookupPostHandlerForm(t *testing.T) {
+	e := echo.New()
+	e.POST("/v1/:format", LookupPostHandler(verifier.NewVerifier("localhost", "admin@localhost")))
+
+	form := url.Values{"email": {"not-an-address"}}
+	req := httptest.NewRequest(http.MethodPost, "/v1/xml", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<validFormat>false</validFormat>")
+}
+
+func TestLookupPostHandlerErrors(t *testing.T) {
+	e := echo.New()
+	e.POST("/v1/:format", LookupPostHandler(verifier.NewVerifier("localhost", "admin@localhost")))
+
+	for body, code := range map[string]int{
+		`{}`:                               http.StatusBadRequest,
+		`{"email":"a@b.com","retries":99}`: http.StatusBadRequest,
+		`{"email":"a@b.com","timeout":-1}`: http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/json", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, code, rec.Code, body)
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"time"
+
+	"github.com/sdwolfe32/trumail/pb"
+	"github.com/sdwolfe32/trumail/verifier"
+	"google.golang.org/protobuf/proto"
+)
+
+// toProto converts an API response to its protocol buffer message,
+// reporting false for responses without a message
+func toProto(res interface{}) (proto.Message, bool) {
+	switch r := res.(type) {
+	case *Lookup:
+		return r.Proto(), true
+	case *LookupV2:
+		return r.Proto(), true
+	case BatchLookups:
+		return r.Proto(), true
+	case *Job:
+		return r.Proto(), true
+	case UsageRecords:
+		return r.Proto(), true
+	case *Health:
+		return r.Proto(), true
+	case *ErrorV2:
+		return r.Proto(), true
+	case *errorBody:
+		return &pb.Error{Message: r.Message}, true
+	case *verifier.LookupError:
+		return &pb.LookupError{Message: r.Message, Details: r.Details}, true
+	default:
+		return nil, false
+	}
+}
+
+// Proto converts the Lookup to its protocol buffer message
+func (l *Lookup) Proto() *pb.Lookup {
+	return &pb.Lookup{
+		Address:     l.Address,
+		Username:    l.Username,
+		Domain:      l.Domain,
+		Md5Hash:     l.MD5Hash,
+		ValidFormat: l.ValidFormat,
+		Deliverable: l.Deliverable,
+		FullInbox:   l.FullInbox,
+		HostExists:  l.HostExists,
+		CatchAll:    l.CatchAll,
+	}
+}
+
+// Proto converts the LookupV2 to its protocol buffer message
+func (l *LookupV2) Proto() *pb.LookupV2 {
+	return &pb.LookupV2{
+		Lookup: l.Lookup.Proto(),
+		Status: l.Status,
+		Reason: l.Reason,
+		Score:  int32(l.Score),
+		Timings: &pb.Timings{
+			Total:    l.Timings.Total,
+			Parse:    l.Timings.Parse,
+			Mx:       l.Timings.MX,
+			Dial:     l.Timings.Dial,
+			Hello:    l.Timings.Hello,
+			Mail:     l.Timings.Mail,
+			CatchAll: l.Timings.CatchAll,
+			Rcpt:     l.Timings.Rcpt,
+			Quit:     l.Timings.Quit,
+		},
+	}
+}
+
+// Proto converts the BatchLookups to their protocol buffer message
+func (b BatchLookups) Proto() *pb.BatchLookups {
+	m := &pb.BatchLookups{Lookups: make([]*pb.BatchLookup, len(b))}
+	for i, l := range b {
+		m.Lookups[i] = &pb.BatchLookup{Lookup: l.Lookup.Proto(), Error: l.Error, Code: l.Code}
+	}
+	return m
+}
+
+// Proto converts the Job to its protocol buffer message
+func (j *Job) Proto() *pb.Job {
+	return &pb.Job{
+		Id:            j.ID,
+		Status:        j.Status,
+		Total:         int32(j.Total),
+		Processed:     int32(j.Processed),
+		Deliverable:   int32(j.Deliverable),
+		Undeliverable: int32(j.Undeliverable),
+		Unknown:       int32(j.Unknown),
+		Created:       j.Created.Format(time.RFC3339),
+		Updated:       j.Updated.Format(time.RFC3339),
+		Callback:      j.Callback.Proto(),
+	}
+}
+
+// Proto converts the JobCallback to its protocol buffer message
+func (cb *JobCallback) Proto() *pb.JobCallback {
+	if cb == nil {
+		return nil
+	}
+	m := &pb.JobCallback{Url: cb.URL, Status: cb.Status}
+	for _, a := range cb.Attempts {
+		m.Attempts = append(m.Attempts, &pb.CallbackAttempt{
+			Attempt:  int32(a.Attempt),
+			Time:     a.Time.Format(time.RFC3339),
+			Status:   int32(a.Status),
+			Error:    a.Error,
+			Duration: int64(a.Duration),
+		})
+	}
+	return m
+}
+
+// Proto converts the ErrorV2 to its protocol buffer message
+func (e *ErrorV2) Proto() *pb.ErrorV2 {
+	return &pb.ErrorV2{
+		Version: e.Version,
+		Status:  int32(e.Status),
+		Code:    e.Code,
+		Message: e.Message,
+		Details: e.Details,
+	}
+}
@@ -0,0 +1,442 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/usage"
+	"github.com/sdwolfe32/trumail/verifier"
+)
+
+// mimeJavaScript is the content type of a JSONP response
+const mimeJavaScript = "application/javascript"
+
+var (
+	// spec is the OpenAPI document served by OpenAPIHandler
+	spec     *Document
+	specOnce sync.Once
+)
+
+// OpenAPI returns the OpenAPI 3 document describing every route served
+// by Trumail
+func OpenAPI() *Document {
+	specOnce.Do(func() { spec = newSpec() })
+	return spec
+}
+
+// OpenAPIHandler returns the OpenAPI 3 document describing the API
+func OpenAPIHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, OpenAPI())
+	}
+}
+
+// newSpec generates the OpenAPI document from the API types
+func newSpec() *Document {
+	d := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "Trumail",
+			Description: "Free and open source email validation/verification",
+			Version:     VersionV2,
+		},
+		Paths: make(map[string]PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]*SecurityScheme{
+				"authToken":      {Type: "apiKey", Name: "X-Auth-Token", In: "header"},
+				"authTokenQuery": {Type: "apiKey", Name: "token", In: "query"},
+				"bearerAuth":     {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+	c := &d.Components
+
+	// Schemas shared by the routes
+	lookup := c.addSchema("Lookup", Lookup{})
+	lookupV2 := c.addSchema("LookupV2", LookupV2{})
+	lookupRequest := c.addSchema("LookupRequest", LookupRequest{})
+	batchLookup := c.addSchema("BatchLookup", BatchLookup{})
+	batchRequest := c.addSchema("BatchRequest", BatchRequest{})
+	batchLookups := &Schema{Type: "array", Items: batchLookup, XML: &XML{Name: "lookups"}}
+	c.addSchema("StreamLookup", StreamLookup{})
+	c.addSchema("StreamDone", StreamDone{})
+	job := c.addSchema("Job", Job{})
+	jobRequest := c.addSchema("JobRequest", JobRequest{})
+	asyncLookupRequest := c.addSchema("AsyncLookupRequest", AsyncLookupRequest{})
+	c.addSchema("JobEvent", JobEvent{})
+	health := c.addSchema("Health", Health{})
+	usageRecord := c.addSchema("UsageRecord", usage.Record{})
+	usageRecords := &Schema{Type: "array", Items: usageRecord, XML: &XML{Name: "usage"}}
+	lookupError := c.addSchema("LookupError", verifier.LookupError{})
+	errorV1 := c.addSchema("Error", errorBody{})
+	errorV2 := c.addSchema("ErrorV2", ErrorV2{})
+
+	// Parameters shared by the routes
+	format := &Parameter{Name: "format", In: "path", Required: true,
+		Description: "The format of the response",
+		Schema:      &Schema{Type: "string", Enum: formatNames()}}
+	batchFormat := &Parameter{Name: "format", In: "path", Required: true,
+		Description: "The format of the response, or ndjson or sse to stream each lookup",
+		Schema: &Schema{Type: "string",
+			Enum: append(formatNames(), FormatNDJSON, FormatSSE)}}
+	jobID := &Parameter{Name: "id", In: "path", Required: true,
+		Description: "The ID of the job", Schema: &Schema{Type: "string"}}
+	callbackURL := &Parameter{Name: "callbackUrl", In: "query",
+		Description: "A URL the signed JobEvent is POSTed to once the job finishes",
+		Schema:      &Schema{Type: "string", Format: "uri"}}
+	callback := &Parameter{Name: "callback", In: "query",
+		Description: "The JSONP callback, required when the format is jsonp unless JSONP is disabled",
+		Schema:      &Schema{Type: "string"}}
+	v1Auth := []map[string][]string{{"authToken": {}}, {"bearerAuth": {}}}
+	v2Auth := []map[string][]string{{"authToken": {}}, {"authTokenQuery": {}}, {"bearerAuth": {}}}
+
+	// v1 routes
+	d.add(http.MethodGet, "/v1/{format}/{email}", &Operation{
+		OperationID: "lookup",
+		Summary:     "Verify the email address in the path",
+		Tags:        []string{"v1"},
+		Parameters: []*Parameter{format, callback, {Name: "email", In: "path",
+			Required: true, Description: "The URL escaped email address",
+			Schema: &Schema{Type: "string"}}},
+		Responses: map[string]*Response{
+			"200": formatResponse("The completed lookup", lookup),
+			"400": jsonResponse("An unsupported format or missing callback", errorV1),
+			"401": formatResponse("A missing or invalid auth token", errorV1),
+			"500": formatResponse("The lookup failed", lookupError),
+		},
+		Security: v1Auth,
+	})
+	d.add(http.MethodPost, "/v1/{format}", &Operation{
+		OperationID: "lookupPost",
+		Summary:     "Verify the email address in the body",
+		Tags:        []string{"v1"},
+		Parameters:  []*Parameter{format, callback},
+		RequestBody: &RequestBody{Required: true, Content: map[string]*MediaType{
+			echo.MIMEApplicationJSON: {lookupRequest},
+			echo.MIMEApplicationXML:  {lookupRequest},
+			echo.MIMEApplicationForm: {lookupRequest},
+		}},
+		Responses: map[string]*Response{
+			"200": formatResponse("The completed lookup", lookup),
+			"400": formatResponse("An invalid body, format or callback", errorV1),
+			"401": formatResponse("A missing or invalid auth token", errorV1),
+			"500": formatResponse("The lookup failed", lookupError),
+		},
+		Security: v1Auth,
+	})
+	d.add(http.MethodPost, "/v1/batch/{format}", &Operation{
+		OperationID: "batch",
+		Summary:     "Verify every email address in the body",
+		Description: "Lookups are returned in the order of the emails, each " +
+			"carrying the error it failed with, if any",
+		Tags:       []string{"v1"},
+		Parameters: []*Parameter{batchFormat, callback},
+		RequestBody: &RequestBody{Required: true, Content: map[string]*MediaType{
+			echo.MIMEApplicationJSON: {batchRequest},
+			echo.MIMEApplicationXML:  {batchRequest},
+			echo.MIMEApplicationForm: {batchRequest},
+		}},
+		Responses: map[string]*Response{
+			"200": batchResponse(batchLookups),
+			"400": formatResponse("An invalid body, format or callback", errorV1),
+			"401": formatResponse("A missing or invalid auth token", errorV1),
+			"413": formatResponse("Too many emails", errorV1),
+		},
+		Security: v1Auth,
+	})
+	d.add(http.MethodGet, "/v1/batch/{format}", &Operation{
+		OperationID: "batchQuery",
+		Summary:     "Verify every email address in the emails queryparams",
+		Description: "Intended for streaming to an EventSource, which can't send " +
+			"headers or a body, so the auth token is also accepted as a queryparam",
+		Tags: []string{"v1"},
+		Parameters: []*Parameter{batchFormat, callback,
+			{Name: "emails", In: "query", Required: true,
+				Schema: &Schema{Type: "array", Items: &Schema{Type: "string"}}},
+			{Name: "timeout", In: "query", Schema: &Schema{Type: "integer"}},
+			{Name: "retries", In: "query", Schema: &Schema{Type: "integer"}},
+			{Name: "skipCatchAll", In: "query", Schema: &Schema{Type: "boolean"}}},
+		Responses: map[string]*Response{
+			"200": batchResponse(batchLookups),
+			"400": formatResponse("Missing emails or an invalid format or callback", errorV1),
+			"401": formatResponse("A missing or invalid auth token", errorV1),
+			"413": formatResponse("Too many emails", errorV1),
+		},
+		Security: v2Auth,
+	})
+	d.add(http.MethodPost, "/v1/jobs/{format}", &Operation{
+		OperationID: "createJob",
+		Summary:     "Verify every email address in the body in the background",
+		Description: "The body may also be plain text with an email address on " +
+			"each line, or a CSV or TSV table, with the options in the queryparams",
+		Tags: []string{"v1"},
+		Parameters: []*Parameter{format, callback,
+			{Name: "timeout", In: "query", Schema: &Schema{Type: "integer", Format: "int32"},
+				Description: "Seconds allowed connecting to a mail server"},
+			{Name: "retries", In: "query", Schema: &Schema{Type: "integer", Format: "int32"},
+				Description: "Reconnections allowed after a transient error"},
+			{Name: "skipCatchAll", In: "query", Schema: &Schema{Type: "boolean"},
+				Description: "Skip checking for a catch-all address"},
+			{Name: "column", In: "query", Schema: &Schema{Type: "string"},
+				Description: "The name or zero based index of the email column of " +
+					"a table, by default the first column named like email"},
+			{Name: "header", In: "query", Schema: &Schema{Type: "boolean"},
+				Description: "Whether the first row of a table is a header, by default true"},
+			callbackURL,
+		},
+		RequestBody: &RequestBody{Required: true, Content: map[string]*MediaType{
+			echo.MIMEApplicationJSON: {jobRequest},
+			echo.MIMEApplicationXML:  {jobRequest},
+			echo.MIMEApplicationForm: {jobRequest},
+			echo.MIMETextPlain:       {&Schema{Type: "string"}},
+			MIMETextCSV:              {&Schema{Type: "string"}},
+			MIMETextTSV:              {&Schema{Type: "string"}},
+		}},
+		Responses: map[string]*Response{
+			"202": formatResponse("The queued job", job),
+			"400": formatResponse("An invalid body, format or callback", errorV1),
+			"401": formatResponse("A missing or invalid auth token", errorV1),
+			"413": formatResponse("Too many emails", errorV1),
+		},
+		Security: v1Auth,
+	})
+	d.add(http.MethodPost, "/v1/async/{format}", &Operation{
+		OperationID: "lookupAsync",
+		Summary:     "Verify the email address in the body in the background",
+		Description: "The lookup runs as a job of a single email address, " +
+			"POSTing a signed JobEvent to the callbackUrl once complete",
+		Tags:       []string{"v1"},
+		Parameters: []*Parameter{format, callback},
+		RequestBody: &RequestBody{Required: true, Content: map[string]*MediaType{
+			echo.MIMEApplicationJSON: {asyncLookupRequest},
+			echo.MIMEApplicationXML:  {asyncLookupRequest},
+			echo.MIMEApplicationForm: {asyncLookupRequest},
+		}},
+		Responses: map[string]*Response{
+			"202": formatResponse("The queued job", job),
+			"400": formatResponse("An invalid body, callbackUrl, format or callback", errorV1),
+			"401": formatResponse("A missing or invalid auth token", errorV1),
+		},
+		Security: v1Auth,
+	})
+	d.add(http.MethodGet, "/v1/jobs/{format}/{id}", &Operation{
+		OperationID: "job",
+		Summary:     "Report the progress of a job",
+		Tags:        []string{"v1"},
+		Parameters:  []*Parameter{format, callback, jobID},
+		Responses: map[string]*Response{
+			"200": formatResponse("The job", job),
+			"401": formatResponse("A missing or invalid auth token", errorV1),
+			"404": formatResponse("The job doesn't exist", errorV1),
+		},
+		Security: v1Auth,
+	})
+	d.add(http.MethodDelete, "/v1/jobs/{format}/{id}", &Operation{
+		OperationID: "cancelJob",
+		Summary:     "Cancel a job, keeping the lookups already completed",
+		Tags:        []string{"v1"},
+		Parameters:  []*Parameter{format, callback, jobID},
+		Responses: map[string]*Response{
+			"200": formatResponse("The cancelled job", job),
+			"401": formatResponse("A missing or invalid auth token", errorV1),
+			"404": formatResponse("The job doesn't exist", errorV1),
+		},
+		Security: v1Auth,
+	})
+	d.add(http.MethodGet, "/v1/jobs/{format}/{id}/results", &Operation{
+		OperationID: "jobResults",
+		Summary:     "Download the lookups completed by a job so far",
+		Description: "CSV and TSV downloads are streamed as every uploaded row " +
+			"with the status, reason, deliverable, catchAll, fullInbox, hostExists " +
+			"and score of its lookup appended, left blank until verified",
+		Tags:       []string{"v1"},
+		Parameters: []*Parameter{format, callback, jobID},
+		Responses: map[string]*Response{
+			"200": formatResponse("The completed lookups in the order uploaded", batchLookups),
+			"401": formatResponse("A missing or invalid auth token", errorV1),
+			"404": formatResponse("The job doesn't exist", errorV1),
+		},
+		Security: v1Auth,
+	})
+	d.add(http.MethodGet, "/v1/usage/{format}", &Operation{
+		OperationID: "usage",
+		Summary:     "Export the daily usage of each API key",
+		Description: "Requires the admin scope. Anonymous lookups are reported under an " +
+			"empty keyId, and cached counts the lookups answered without a live SMTP check",
+		Tags: []string{"v1"},
+		Parameters: []*Parameter{format, callback,
+			{Name: "from", In: "query", Schema: &Schema{Type: "string", Format: "date"},
+				Description: "The first day exported, by default the first of the month"},
+			{Name: "to", In: "query", Schema: &Schema{Type: "string", Format: "date"},
+				Description: "The last day exported, by default today"},
+			{Name: "key", In: "query", Schema: &Schema{Type: "string"},
+				Description: "The ID of the only API key exported"}},
+		Responses: map[string]*Response{
+			"200": formatResponse("The usage of each key per day", usageRecords),
+			"400": formatResponse("An invalid date range", errorV1),
+			"401": formatResponse("A missing or invalid auth token", errorV1),
+		},
+		Security: v1Auth,
+	})
+	d.add(http.MethodGet, "/v1/health", &Operation{
+		OperationID: "health",
+		Summary:     "Report whether the service is ready along with the health of each component",
+		Tags:        []string{"v1"},
+		Responses: map[string]*Response{
+			"200": formatResponse("The service is ready", health),
+			"401": formatResponse("A missing or invalid auth token", errorV1),
+			"406": jsonResponse("None of the accepted content types are supported", errorV1),
+			"503": formatResponse("A component is unhealthy", health),
+		},
+		Security: v1Auth,
+	})
+	d.add(http.MethodGet, "/v1/health/live", &Operation{
+		OperationID: "liveness",
+		Summary:     "Report the service is running, without checking its dependencies",
+		Tags:        []string{"v1"},
+		Responses: map[string]*Response{
+			"200": formatResponse("The service is running", health),
+			"406": jsonResponse("None of the accepted content types are supported", errorV1),
+		},
+	})
+	d.add(http.MethodGet, "/v1/health/ready", &Operation{
+		OperationID: "readiness",
+		Summary:     "Report whether the service is ready along with the health of each component",
+		Tags:        []string{"v1"},
+		Responses: map[string]*Response{
+			"200": formatResponse("The service is ready", health),
+			"406": jsonResponse("None of the accepted content types are supported", errorV1),
+			"503": formatResponse("A component is unhealthy", health),
+		},
+	})
+
+	// v2 routes
+	d.add(http.MethodGet, "/v2/lookups/{format}", &Operation{
+		OperationID: "lookupV2",
+		Summary:     "Verify the email address in the email queryparam",
+		Tags:        []string{"v2"},
+		Parameters: []*Parameter{format, callback, {Name: "email", In: "query",
+			Required: true, Schema: &Schema{Type: "string"}}},
+		Responses: map[string]*Response{
+			"200": formatResponse("The completed lookup", lookupV2),
+			"400": formatResponse("A missing email or invalid format", errorV2),
+			"401": formatResponse("A missing or invalid auth token", errorV2),
+			"500": formatResponse("The lookup failed", errorV2),
+		},
+		Security: v2Auth,
+	})
+	d.add(http.MethodGet, "/v2/health", &Operation{
+		OperationID: "healthV2",
+		Summary:     "Report whether the service is ready along with the health of each component",
+		Tags:        []string{"v2"},
+		Responses: map[string]*Response{
+			"200": formatResponse("The service is ready", health),
+			"401": formatResponse("A missing or invalid auth token", errorV2),
+			"406": jsonResponse("None of the accepted content types are supported", errorV2),
+			"503": formatResponse("A component is unhealthy", health),
+		},
+		Security: v2Auth,
+	})
+
+	// Service routes
+	d.add(http.MethodGet, "/metrics", &Operation{
+		OperationID: "metrics",
+		Summary:     "Prometheus metrics",
+		Responses: map[string]*Response{"200": {Description: "Metrics in the Prometheus text format",
+			Content: map[string]*MediaType{echo.MIMETextPlain: {&Schema{Type: "string"}}}}},
+	})
+	d.add(http.MethodGet, "/openapi.json", &Operation{
+		OperationID: "openAPI",
+		Summary:     "This OpenAPI document",
+		Responses: map[string]*Response{"200": jsonResponse("The OpenAPI document",
+			&Schema{Type: "object"})},
+	})
+
+	// Every authenticated route but the healthchecks requires a scope and
+	// is rate limited
+	for path, item := range d.Paths {
+		for _, op := range item {
+			if op.Security == nil || strings.HasSuffix(path, "/health") {
+				continue
+			}
+			body := errorV1
+			if strings.HasPrefix(path, "/v2/") {
+				body = errorV2
+			}
+			op.Responses["403"] = formatResponse("An API key without the scope of the route", body)
+			op.Responses["429"] = rateLimitedResponse(body)
+		}
+	}
+	return d
+}
+
+// add adds an Operation to the document under the passed method and path
+func (d *Document) add(method, path string, op *Operation) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// formatNames returns the name of every supported format
+func formatNames() []string {
+	var names []string
+	for _, f := range formats {
+		names = append(names, f.format)
+	}
+	return names
+}
+
+// formatResponse describes a response encoded by FormatEncoder in each of
+// the supported formats
+func formatResponse(description string, schema *Schema) *Response {
+	binary := &Schema{Type: "string", Format: "binary"}
+	return &Response{Description: description, Content: map[string]*MediaType{
+		echo.MIMEApplicationJSON: {schema},
+		echo.MIMEApplicationXML:  {schema},
+		MIMEApplicationYAML:      {schema},
+		MIMETextCSV:              {&Schema{Type: "string"}},
+		MIMETextTSV:              {&Schema{Type: "string"}},
+		MIMEApplicationMsgPack:   {binary},
+		MIMEApplicationProtobuf:  {binary},
+		mimeJavaScript:           {&Schema{Type: "string"}},
+	}}
+}
+
+// rateLimitedResponse describes the response to a request exceeding the
+// rate limit or a quota of its API key or client IP
+func rateLimitedResponse(schema *Schema) *Response {
+	integer := func(description string) *Header {
+		return &Header{Description: description, Schema: &Schema{Type: "integer"}}
+	}
+	res := formatResponse("The rate limit or a quota of the API key or client IP is exceeded", schema)
+	res.Headers = map[string]*Header{
+		"X-RateLimit-Limit":     integer("The requests allowed by the exceeded limit"),
+		"X-RateLimit-Remaining": integer("The requests remaining, always 0"),
+		"X-RateLimit-Reset":     integer("The seconds until the limit is fully reset"),
+		"Retry-After":           integer("The seconds until a request may be retried"),
+	}
+	return res
+}
+
+// batchResponse describes the lookups of a batch in each of the supported
+// formats, along with the NDJSON and Server-Sent Events streams of them
+func batchResponse(schema *Schema) *Response {
+	res := formatResponse("The completed lookups, or a stream of each as it "+
+		"completes with periodic heartbeats", schema)
+	res.Content[MIMEApplicationNDJSON] = &MediaType{&Schema{Type: "string"}}
+	res.Content[MIMETextEventStream] = &MediaType{&Schema{Type: "string"}}
+	return res
+}
+
+// jsonResponse describes a response that is always encoded as JSON
+func jsonResponse(description string, schema *Schema) *Response {
+	return &Response{Description: description, Content: map[string]*MediaType{
+		echo.MIMEApplicationJSON: {schema},
+	}}
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPISchemas(t *testing.T) {
+	spec := OpenAPI()
+
+	lookup := spec.Components.Schemas["Lookup"]
+	assert.Equal(t, "lookup", lookup.XML.Name)
+	assert.Equal(t, "boolean", lookup.Properties["deliverable"].Type)
+	assert.Contains(t, lookup.Required, "md5Hash")
+
+	lookupV2 := spec.Components.Schemas["LookupV2"]
+	assert.Equal(t, "string", lookupV2.Properties["address"].Type)
+	assert.Equal(t, "integer", lookupV2.Properties["timings"].Properties["total"].Type)
+
+	errorV2 := spec.Components.Schemas["ErrorV2"]
+	assert.NotContains(t, errorV2.Required, "details")
+
+	op := spec.Paths["/v1/{format}/{email}"]["get"]
+	assert.Subset(t, op.Parameters[0].Schema.Enum, []string{FormatJSON, FormatJSONP, FormatXML})
+	assert.Equal(t, "callback", op.Parameters[1].Name)
+	assert.Contains(t, op.Responses["429"].Headers, "Retry-After")
+	assert.Nil(t, spec.Paths["/v1/health"]["get"].Responses["429"])
+	assert.NotNil(t, spec.Paths["/v1/health"]["get"].Responses["503"])
+	assert.Nil(t, spec.Paths["/v1/health/ready"]["get"].Security)
+	assert.Nil(t, spec.Paths["/v1/health/live"]["get"].Responses["503"])
+}
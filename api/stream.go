@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/verifier"
+)
+
+const (
+	// FormatNDJSON is the format constant for a newline delimited JSON
+	// stream
+	FormatNDJSON = "ndjson"
+	// FormatSSE is the format constant for a Server-Sent Events stream
+	FormatSSE = "sse"
+)
+
+const (
+	// MIMEApplicationNDJSON is the content type of a newline delimited
+	// JSON stream
+	MIMEApplicationNDJSON = "application/x-ndjson"
+	// MIMETextEventStream is the content type of a Server-Sent Events
+	// stream
+	MIMETextEventStream = "text/event-stream"
+)
+
+// HeartbeatInterval is the time between heartbeats sent on an idle
+// stream, keeping proxies from closing it
+var HeartbeatInterval = 15 * time.Second
+
+// StreamLookup is a BatchLookup streamed as soon as it completes, along
+// with the index of its email in the request
+type StreamLookup struct {
+	Index int `json:"index"`
+	BatchLookup
+}
+
+// StreamDone is the final event of a Server-Sent Events stream
+type StreamDone struct {
+	Count int `json:"count"` // The number of lookups streamed
+}
+
+// streamEncoder writes the events of a lookup stream
+type streamEncoder interface {
+	contentType() string
+	lookup(l *StreamLookup) error
+	heartbeat(t time.Time) error
+	done(count int) error
+}
+
+// newStreamEncoder returns the streamEncoder for the requested format, or
+// nil if the format isn't a stream
+func newStreamEncoder(c echo.Context) streamEncoder {
+	switch strings.ToLower(c.Param("format")) {
+	case FormatNDJSON:
+		return &ndjsonEncoder{c.Response()}
+	case FormatSSE:
+		return &sseEncoder{c.Response()}
+	default:
+		return nil
+	}
+}
+
+// streamLookups writes each result to the client as it's received, with a
+// heartbeat whenever none have been written for the HeartbeatInterval. It
+// returns once every result has been written or the client disconnects,
+// leaving the caller to cancel any remaining work
+func streamLookups(ctx context.Context, c echo.Context, enc streamEncoder,
+	results <-chan verifier.Result) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, enc.contentType())
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering
+	res.Header().Set("X-Powered-By", "Trumail")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+	var count int
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return nil // The client disconnected
+		case t := <-heartbeat.C:
+			err = enc.heartbeat(t)
+		case r, ok := <-results:
+			if !ok {
+				err = enc.done(count)
+				res.Flush()
+				return err
+			}
+			count++
+			err = enc.lookup(&StreamLookup{r.Index, *newBatchLookup(r.Lookup, r.Err)})
+			heartbeat.Reset(HeartbeatInterval)
+		}
+		if err != nil {
+			return nil // The client can no longer be written to
+		}
+		res.Flush()
+	}
+}
+
+// ndjsonEncoder writes a stream as a JSON object per line. Heartbeats are
+// objects holding only the unix time they were sent at
+type ndjsonEncoder struct{ w http.ResponseWriter }
+
+func (e *ndjsonEncoder) contentType() string { return MIMEApplicationNDJSON }
+
+func (e *ndjsonEncoder) lookup(l *StreamLookup) error {
+	return json.NewEncoder(e.w).Encode(l)
+}
+
+func (e *ndjsonEncoder) heartbeat(t time.Time) error {
+	_, err := fmt.Fprintf(e.w, "{\"heartbeat\":%d}\n", t.Unix())
+	return err
+}
+
+func (e *ndjsonEncoder) done(int) error { return nil }
+
+// sseEncoder writes a stream as lookup events identified by their index,
+// followed by a done event. Heartbeats are comments
+type sseEncoder struct{ w http.ResponseWriter }
+
+func (e *sseEncoder) contentType() string { return MIMETextEventStream }
+
+func (e *sseEncoder) lookup(l *StreamLookup) error {
+	return e.event("lookup", fmt.Sprint(l.Index), l)
+}
+
+func (e *sseEncoder) heartbeat(time.Time) error {
+	_, err := fmt.Fprint(e.w, ": heartbeat\n\n")
+	return err
+}
+
+func (e *sseEncoder) done(count int) error {
+	return e.event("done", "", &StreamDone{count})
+}
+
+// event writes a single event with the passed JSON encoded data
+func (e *sseEncoder) event(name, id string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		fmt.Fprintf(e.w, "id: %s\n", id)
+	}
+	_, err = fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", name, b)
+	return err
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/verifier"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchHandlerNDJSON(t *testing.T) {
+	rec := batch(FormatNDJSON, echo.MIMEApplicationJSON, `{"emails":["one","two","three"]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, MIMEApplicationNDJSON, rec.Header().Get(echo.HeaderContentType))
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	assert.Len(t, lines, 3)
+	seen := make(map[int]string)
+	for _, line := range lines {
+		var l StreamLookup
+		assert.Nil(t, json.Unmarshal([]byte(line), &l))
+		assert.False(t, l.ValidFormat)
+		seen[l.Index] = l.Address
+	}
+	assert.Equal(t, map[int]string{0: "one", 1: "two", 2: "three"}, seen)
+}
+
+func TestBatchHandlerSSE(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	e.GET("/v1/batch/:format", BatchHandler(verifier.NewVerifier("localhost", "admin@localhost"), 3, 2))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/batch/sse?emails=one&emails=two", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, MIMETextEventStream, rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+
+	events := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n\n"), "\n\n")
+	assert.Len(t, events, 3)
+	for _, event := range events[:2] {
+		assert.True(t, strings.HasPrefix(event, "id: "), event)
+		assert.Contains(t, event, "\nevent: lookup\ndata: {")
+	}
+	assert.Equal(t, "event: done\ndata: {\"count\":2}", events[2])
+}
+
+func TestStreamLookupsHeartbeat(t *testing.T) {
+	defer func(d time.Duration) { HeartbeatInterval = d }(HeartbeatInterval)
+	HeartbeatInterval = 10 * time.Millisecond
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/batch/ndjson", nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	// Deliver a single result once a heartbeat has been sent
+	results := make(chan verifier.Result)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		results <- verifier.Result{Index: 0, Lookup: &verifier.Lookup{Address: verifier.Address{Address: "one"}}}
+		close(results)
+	}()
+	assert.Nil(t, streamLookups(context.Background(), c, &ndjsonEncoder{rec}, results))
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	assert.True(t, len(lines) > 1)
+	assert.True(t, strings.HasPrefix(lines[0], `{"heartbeat":`))
+	assert.True(t, strings.HasPrefix(lines[len(lines)-1], `{"index":0,"address":"one"`))
+}
+
+func TestStreamLookupsDisconnect(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/batch/sse", nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	// Returns without waiting on results once the client is gone
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan error)
+	go func() { done <- streamLookups(ctx, c, &sseEncoder{rec}, make(chan verifier.Result)) }()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("streamLookups didn't return after the client disconnected")
+	}
+	assert.NotContains(t, rec.Body.String(), "event: done")
+}
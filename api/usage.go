@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/pb"
+	"github.com/sdwolfe32/trumail/usage"
+)
+
+// ErrInvalidRange is thrown when usage is requested for an invalid range
+// of dates
+var ErrInvalidRange = echo.NewHTTPError(http.StatusBadRequest, usage.ErrInvalidRange.Error())
+
+// UsageRecords are the daily usage records of each API key in order of
+// date
+type UsageRecords []*usage.Record
+
+// MarshalXML encodes the records within a single usage element
+func (u UsageRecords) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "usage"}
+	return e.EncodeElement(struct {
+		Records []*usage.Record `xml:"record"`
+	}{u}, start)
+}
+
+// Proto converts the UsageRecords to their protocol buffer message
+func (u UsageRecords) Proto() *pb.UsageRecords {
+	records := make([]*pb.UsageRecord, len(u))
+	for i, r := range u {
+		records[i] = &pb.UsageRecord{
+			Date:          r.Date,
+			KeyId:         r.KeyID,
+			KeyName:       r.KeyName,
+			Lookups:       int32(r.Lookups),
+			Deliverable:   int32(r.Deliverable),
+			Undeliverable: int32(r.Undeliverable),
+			Unknown:       int32(r.Unknown),
+			Live:          int32(r.Live),
+			Cached:        int32(r.Cached),
+			BatchRows:     int32(r.BatchRows),
+		}
+	}
+	return &pb.UsageRecords{Records: records}
+}
+
+// UsageHandler returns the daily usage of every API key, or the key in the
+// key queryparam, between the dates in the from and to queryparams. The
+// current month to date is returned by default
+func UsageHandler(s *usage.Store) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		from, to, err := usage.ParseRange(c.QueryParam("from"), c.QueryParam("to"), time.Now())
+		if err != nil {
+			return ErrInvalidRange
+		}
+		records, err := s.Usage(from, to, c.QueryParam("key"))
+		if err != nil {
+			return err
+		}
+		return FormatEncoder(c, http.StatusOK, UsageRecords(records))
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/usage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageHandler(t *testing.T) {
+	s, err := usage.Open(filepath.Join(t.TempDir(), "usage.db"))
+	assert.Nil(t, err)
+	assert.Nil(t, s.Add([]*usage.Record{
+		{Date: "2024-01-01", KeyID: "a", KeyName: "reporting", Lookups: 2, Deliverable: 2},
+		{Date: "2024-01-02", KeyID: "b", KeyName: "billing", Lookups: 1, Unknown: 1},
+	}))
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	e.GET("/v1/usage/:format", UsageHandler(s))
+
+	for target, expected := range map[string]string{
+		"/v1/usage/json?from=2024-01-01&to=2024-01-31&key=b": `"keyName":"billing"`,
+		"/v1/usage/csv?from=2024-01-01&to=2024-01-01":        "2024-01-01,a,reporting,2,2,0,0",
+		"/v1/usage/xml?from=2024-01-01&to=2024-01-31":        "<usage><record><date>2024-01-01</date>",
+		"/v1/usage/json?from=2024-01-31&to=2024-01-01":       usage.ErrInvalidRange.Error(),
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Contains(t, rec.Body.String(), expected, target)
+	}
+
+	// The records of other keys are filtered out
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		"/v1/usage/json?from=2024-01-01&to=2024-01-31&key=b", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "reporting")
+	assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+}
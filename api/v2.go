@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/verifier"
+)
+
+// VersionV2 is the API version reported on v2 error bodies
+const VersionV2 = "2"
+
+// LookupV2 is a Lookup extended with the outcome and timings of the
+// lookup, returned by the v2 lookup route
+type LookupV2 struct {
+	Lookup
+	Status  string    `json:"status" xml:"status"`
+	Reason  string    `json:"reason" xml:"reason"`
+	Score   int       `json:"score" xml:"score"`
+	Timings TimingsV2 `json:"timings" xml:"timings"`
+}
+
+// TimingsV2 holds the milliseconds taken by a lookup and each of its phases
+type TimingsV2 struct {
+	Total    int64 `json:"total" xml:"total"`
+	Parse    int64 `json:"parse" xml:"parse"`
+	MX       int64 `json:"mx" xml:"mx"`
+	Dial     int64 `json:"dial" xml:"dial"`
+	Hello    int64 `json:"hello" xml:"hello"`
+	Mail     int64 `json:"mail" xml:"mail"`
+	CatchAll int64 `json:"catchAll" xml:"catchAll"`
+	Rcpt     int64 `json:"rcpt" xml:"rcpt"`
+	Quit     int64 `json:"quit" xml:"quit"`
+}
+
+// ErrorV2 is the body of every error returned from a v2 route
+type ErrorV2 struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Version string   `json:"version" xml:"version"`
+	Status  int      `json:"status" xml:"status"`
+	Code    string   `json:"code" xml:"code"`
+	Message string   `json:"message" xml:"message"`
+	Details string   `json:"details,omitempty" xml:"details,omitempty"`
+}
+
+// errorCodes maps the API errors to the code reported on an ErrorV2
+var errorCodes = map[*echo.HTTPError]string{
+	ErrMissingCallback:   "missing_callback",
+	ErrUnsupportedFormat: "unsupported_format",
+	ErrMissingEmail:      "missing_email",
+	ErrInvalidOptions:    "invalid_options",
+	ErrInvalidRange:      "invalid_range",
+}
+
+// statusCodes maps HTTP status codes to the code reported on an ErrorV2
+// for errors without a more specific code
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusNotAcceptable:         "not_acceptable",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusServiceUnavailable:    "unavailable",
+}
+
+// NewErrorV2 converts an error returned while handling a request to an
+// ErrorV2
+func NewErrorV2(err error) *ErrorV2 {
+	switch e := err.(type) {
+	case *verifier.LookupError:
+		return &ErrorV2{Version: VersionV2, Status: http.StatusInternalServerError,
+			Code: e.Code(), Message: e.Message, Details: e.Details}
+	case *echo.HTTPError:
+		code, ok := errorCodes[e]
+		if !ok {
+			if code, ok = statusCodes[e.Code]; !ok {
+				code = "internal_error"
+			}
+		}
+		return &ErrorV2{Version: VersionV2, Status: e.Code, Code: code,
+			Message: fmt.Sprint(e.Message)}
+	default:
+		return &ErrorV2{Version: VersionV2, Status: http.StatusInternalServerError,
+			Code: "internal_error", Message: http.StatusText(http.StatusInternalServerError)}
+	}
+}
+
+// ErrorMiddlewareV2 encodes any error returned by the v2 handlers as an
+// ErrorV2 in the requested format, falling back to JSON if that format
+// is unsupported
+func ErrorMiddlewareV2(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		err := next(c)
+		if err == nil || c.Response().Committed {
+			return err
+		}
+		body := NewErrorV2(err)
+		if err := FormatEncoder(c, body.Status, body); err != nil {
+			body = NewErrorV2(err)
+			return c.JSON(body.Status, body)
+		}
+		return nil
+	}
+}
+
+// LookupV2Handler performs a single email verification on the address in
+// the email queryparam and returns a fully populated LookupV2 or an error
+func LookupV2Handler(v *verifier.Verifier) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx, span := tracer.Start(c.Request().Context(), "api.LookupV2Handler")
+		defer span.End()
+
+		email := c.QueryParam("email")
+		if email == "" {
+			return ErrMissingEmail
+		}
+
+		// Perform the verification, the queryparam has already been unescaped
+		opts := verifier.DefaultOptions
+		opts.Literal = true
+		lookup, err := v.VerifyOptions(ctx, email, opts)
+		if err != nil {
+			return err
+		}
+		return FormatEncoder(c, http.StatusOK, newLookupV2(lookup))
+	}
+}
+
+// newLookupV2 converts a verifier.Lookup to its v2 API representation
+func newLookupV2(l *verifier.Lookup) *LookupV2 {
+	status, reason := verifier.Outcome(l, nil)
+	return &LookupV2{
+		Lookup:  *newLookup(l),
+		Status:  status,
+		Reason:  reason,
+		Score:   verifier.Score(l, nil),
+		Timings: newTimingsV2(l),
+	}
+}
+
+// newTimingsV2 converts the timings of a verifier.Lookup to milliseconds
+func newTimingsV2(l *verifier.Lookup) TimingsV2 {
+	ms := func(d time.Duration) int64 { return int64(d / time.Millisecond) }
+	return TimingsV2{
+		Total:    ms(l.Took),
+		Parse:    ms(l.Timings[verifier.PhaseParse]),
+		MX:       ms(l.Timings[verifier.PhaseMX]),
+		Dial:     ms(l.Timings[verifier.PhaseDial]),
+		Hello:    ms(l.Timings[verifier.PhaseHello]),
+		Mail:     ms(l.Timings[verifier.PhaseMail]),
+		CatchAll: ms(l.Timings[verifier.PhaseCatchAll]),
+		Rcpt:     ms(l.Timings[verifier.PhaseRcpt]),
+		Quit:     ms(l.Timings[verifier.PhaseQuit]),
+	}
+}
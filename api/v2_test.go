@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/verifier"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupV2Handler(t *testing.T) {
+	e := echo.New()
+	g := e.Group("/v2", ErrorMiddlewareV2)
+	g.GET("/lookups/:format", LookupV2Handler(verifier.NewVerifier("localhost", "admin@localhost")))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v2/lookups/json?email=not-an-address", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"status":"undeliverable","reason":"invalid_format","score":0`)
+}
+
+func TestLookupV2HandlerErrors(t *testing.T) {
+	e := echo.New()
+	g := e.Group("/v2", ErrorMiddlewareV2)
+	g.GET("/lookups/:format", LookupV2Handler(verifier.NewVerifier("localhost", "admin@localhost")))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v2/lookups/xml", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, xml.Header+`<error><version>2</version><status>400</status><code>missing_email</code>`+
+		`<message>Missing email</message></error>`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v2/lookups/yml?email=a", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"unsupported_format"`)
+}
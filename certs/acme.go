@@ -0,0 +1,61 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ErrNoHosts is thrown when ACME is configured without any hosts to
+// obtain certificates for
+var ErrNoHosts = errors.New("ACME requires at least one host")
+
+// ACMEConfig configures the certificates obtained from an ACME CA
+type ACMEConfig struct {
+	Hosts        []string // The only hosts certificates are obtained for
+	Email        string   // The contact registered with the CA, if any
+	DirectoryURL string   // The directory of the CA, Let's Encrypt if empty
+	CacheDir     string   // Where accounts and certificates are cached
+	CAFile       string   // Roots trusted when calling a test CA such as Pebble
+}
+
+// NewACME generates an autocert.Manager obtaining and renewing the
+// certificates of the configured hosts with the TLS-ALPN-01 challenge, or
+// the HTTP-01 challenge when its HTTPHandler is served on port 80
+func NewACME(config ACMEConfig) (*autocert.Manager, error) {
+	if len(config.Hosts) == 0 {
+		return nil, ErrNoHosts
+	}
+	client := &acme.Client{DirectoryURL: config.DirectoryURL}
+	if client.DirectoryURL == "" {
+		client.DirectoryURL = autocert.DefaultACMEDirectory
+	}
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in " + config.CAFile)
+		}
+		client.HTTPClient = &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots},
+		}}
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(config.Hosts...),
+		Email:      config.Email,
+		Client:     client,
+	}
+	if config.CacheDir != "" {
+		m.Cache = autocert.DirCache(config.CacheDir)
+	}
+	return m, nil
+}
@@ -0,0 +1,113 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCert writes a self-signed certificate for the passed name and its
+// key to the passed files, modified at the passed time
+func writeCert(t *testing.T, certFile, keyFile, name string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE",
+		Bytes: der}), 0600))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY",
+		Bytes: keyDER}), 0600))
+	assert.Nil(t, os.Chtimes(certFile, modTime, modTime))
+	assert.Nil(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+// commonName returns the common name of the certificate served by r
+func commonName(t *testing.T, r *Reloader) string {
+	r.mu.Lock()
+	r.checked = time.Time{}
+	r.mu.Unlock()
+	cert, err := r.GetCertificate(nil)
+	assert.Nil(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.Nil(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	_, err := NewReloader(certFile, keyFile)
+	assert.NotNil(t, err)
+
+	now := time.Now()
+	writeCert(t, certFile, keyFile, "first.example.com", now.Add(-time.Hour))
+	r, err := NewReloader(certFile, keyFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "first.example.com", commonName(t, r))
+
+	// Changed files are reloaded
+	writeCert(t, certFile, keyFile, "second.example.com", now)
+	assert.Equal(t, "second.example.com", commonName(t, r))
+
+	// The previous certificate is kept while the files don't match
+	assert.Nil(t, os.WriteFile(keyFile, []byte("partial"), 0600))
+	assert.Nil(t, os.Chtimes(keyFile, now.Add(time.Hour), now.Add(time.Hour)))
+	assert.Equal(t, "second.example.com", commonName(t, r))
+}
+
+func TestNewACME(t *testing.T) {
+	_, err := NewACME(ACMEConfig{})
+	assert.Equal(t, ErrNoHosts, err)
+
+	m, err := NewACME(ACMEConfig{Hosts: []string{"example.com"}, CacheDir: t.TempDir()})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://acme-v02.api.letsencrypt.org/directory", m.Client.DirectoryURL)
+	assert.NotNil(t, m.HostPolicy(nil, "other.com"))
+	assert.Nil(t, m.HostPolicy(nil, "example.com"))
+
+	// Test CAs are trusted with their roots
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "key.pem")
+	writeCert(t, certFile, keyFile, "pebble", time.Now())
+	m, err = NewACME(ACMEConfig{Hosts: []string{"example.com"},
+		DirectoryURL: "https://localhost:14000/dir", CAFile: certFile})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://localhost:14000/dir", m.Client.DirectoryURL)
+	assert.NotNil(t, m.Client.HTTPClient)
+	_, err = NewACME(ACMEConfig{Hosts: []string{"example.com"}, CAFile: keyFile})
+	assert.NotNil(t, err)
+}
+
+func TestRedirectHandler(t *testing.T) {
+	for port, expected := range map[string]string{
+		"443":  "https://example.com/v1/json/a@b.c?x=1",
+		"8443": "https://example.com:8443/v1/json/a@b.c?x=1",
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "http://example.com:8080/v1/json/a@b.c?x=1", nil)
+		RedirectHandler(port).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+		assert.Equal(t, expected, rec.Header().Get("Location"))
+	}
+}
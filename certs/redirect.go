@@ -0,0 +1,21 @@
+package certs
+
+import (
+	"net"
+	"net/http"
+)
+
+// RedirectHandler returns an http.Handler permanently redirecting every
+// request to the same URL over HTTPS on the passed port
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
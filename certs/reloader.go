@@ -0,0 +1,96 @@
+// Package certs provides the certificates the API is served over HTTPS
+// with, either loaded from files or obtained from an ACME CA
+package certs
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// reloadInterval is the longest a Reloader serves its certificate before
+// checking the files for changes
+const reloadInterval = time.Second
+
+// Reloader serves the certificate and key loaded from a pair of files,
+// reloading them whenever either changes so renewed certificates are
+// picked up without a restart
+type Reloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time // The latest modification time of the loaded files
+	checked time.Time // When the files were last checked for changes
+}
+
+// NewReloader generates a new Reloader, failing if the certificate and key
+// can't be loaded
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTime); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, reloading it if the
+// files have changed. The previous certificate is kept if the changed
+// files can't be loaded, such as while only one of them has been replaced
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) < reloadInterval {
+		return r.cert, nil
+	}
+	r.checked = time.Now()
+	modTime, err := r.latestModTime()
+	if err == nil && !modTime.Equal(r.modTime) {
+		err = r.load(modTime)
+	}
+	if err != nil {
+		slog.Warn("failed to reload TLS certificate", "cert", r.certFile, "error", err)
+	}
+	return r.cert, nil
+}
+
+// TLSConfig returns a tls.Config serving the Reloaders certificate
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: r.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+}
+
+// load loads the certificate and key, recording the passed modification
+// time once they're loaded
+func (r *Reloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert, r.modTime = &cert, modTime
+	return nil
+}
+
+// latestModTime returns the latest modification time of the two files
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sdwolfe32/trumail/config"
+	"github.com/sdwolfe32/trumail/doctor"
+	"github.com/sdwolfe32/trumail/identity"
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/usage"
+)
+
+// helpText describes every command of the CLI
+const helpText = `Usage: trumail [command]
+
+Commands:
+  serve [-config FILE] [-port 8080 ...]
+                                 Run the API servers (the default)
+  config print [-config FILE] [-port 8080 ...]
+                                 Print the effective config with secrets masked
+  doctor [-mx HOST:PORT] [-dnsbl ZONES] [-config FILE ...]
+                                 Check port 25 egress, PTR/FCrDNS, DNSBLs, SPF, DMARC
+                                 and DNS, failing if any check fails
+  keys create -name NAME -scopes lookup,batch,admin [-expires 720h]
+                                 Create an API key, printing its secret and webhook
+                                 secret once
+  keys list                      List every API key
+  keys revoke ID                 Revoke an API key
+  keys rotate [-webhook] ID      Replace the secret, or webhook secret, of an API key,
+                                 printing it once
+  keys quota [-daily N] [-monthly N] ID
+                                 Set the requests an API key may make, 0 for the default
+  keys cors [-origins URLS] [-methods GET,POST] [-headers NAMES] ID
+                                 Set the origins browsers may use an API key from, none
+                                 for the global cors_origins
+  usage [-from 2024-01-01] [-to 2024-01-31] [-key ID] [-format csv]
+                                 Export the daily usage of each API key as CSV or JSON,
+                                 the current month to date by default
+
+Settings are loaded from the YAML or TOML file named by -config or CONFIG_FILE, then
+the environment, then flags. Run trumail serve -h to list them. The keys commands
+operate on keys_db and usage on usage_db unless passed -db PATH.
+`
+
+// errUsage is thrown when the CLI is invoked incorrectly
+var errUsage = errors.New("invalid usage, run trumail help")
+
+// run runs the command named by the passed arguments, writing its output
+// to w. The server is run when no command is named
+func run(args []string, w io.Writer) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && !isHelp(args[0]) {
+		args = append([]string{"serve"}, args...)
+	}
+	switch args[0] {
+	case "serve":
+		c, err := loadConfig(newFlagSet("serve", w), args[1:])
+		if err == flag.ErrHelp {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		serve(c, args[1:])
+		return nil
+	case "config":
+		return configCommand(args[1:], w)
+	case "doctor":
+		return doctorCommand(args[1:], w)
+	case "keys":
+		return keysCommand(args[1:], w)
+	case "usage":
+		return usageCommand(args[1:], w)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(w, helpText)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q, run trumail help", args[0])
+	}
+}
+
+// isHelp returns whether the passed argument asks for help
+func isHelp(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+// newFlagSet generates a new FlagSet for the named command, writing its
+// errors and help to w
+func newFlagSet(name string, w io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(w)
+	return fs
+}
+
+// loadConfig loads and validates the config of a command from the config
+// file, the environment and the flags in args, which are parsed by the
+// passed FlagSet along with any it already defines
+func loadConfig(fs *flag.FlagSet, args []string) (*config.Config, error) {
+	c, err := config.Load(fs, args, os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 0 {
+		return nil, errUsage
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config\n%w", err)
+	}
+	return c, nil
+}
+
+// configCommand prints the effective config
+func configCommand(args []string, w io.Writer) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errUsage
+	}
+	c, err := loadConfig(newFlagSet("config print", w), args[1:])
+	if err == flag.ErrHelp {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return c.Print(w)
+}
+
+// doctorCommand checks the deployment for the most common reasons
+// verifications fail, failing if any check does
+func doctorCommand(args []string, w io.Writer) error {
+	fs := newFlagSet("doctor", w)
+	mx := fs.String("mx", doctor.DefaultTestMX, "The mail server port 25 egress is tested against")
+	dnsbls := fs.String("dnsbl", strings.Join(doctor.DefaultDNSBLs, ","),
+		"The comma separated DNSBL zones the public IP is looked up in")
+	c, err := loadConfig(fs, args)
+	if err == flag.ErrHelp {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	d, _ := identity.NewDiscoverer(c.IPDiscovery, c.IPDiscoveryURL,
+		c.IPDiscoveryInterface) // Already validated
+	doc := doctor.New(c.HELOHostname, d, c.SourceAddr)
+	doc.TestMX = *mx
+	doc.DNSBLs = nil
+	for _, zone := range strings.Split(*dnsbls, ",") {
+		if zone = strings.TrimSpace(zone); zone != "" {
+			doc.DNSBLs = append(doc.DNSBLs, zone)
+		}
+	}
+	report := doc.Run(context.Background())
+	if err := report.Write(w); err != nil {
+		return err
+	}
+	if failed := report.Failed(); failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(report))
+	}
+	return nil
+}
+
+// defaults returns the config loaded from the config file and the
+// environment, whose paths the keys and usage commands default to
+func defaults() (*config.Config, error) {
+	return config.Load(flag.NewFlagSet("", flag.ContinueOnError), nil, os.LookupEnv)
+}
+
+// keysCommand runs one of the keys subcommands
+func keysCommand(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	c, err := defaults()
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("keys "+args[0], flag.ContinueOnError)
+	fs.SetOutput(w)
+	db := fs.String("db", c.KeysDB, "The path of the API key database")
+	var name, scopes, expires, origins, methods, headers string
+	var quota keys.Quota
+	var webhook bool
+	switch args[0] {
+	case "create":
+		fs.StringVar(&name, "name", "", "The name of the key")
+		fs.StringVar(&scopes, "scopes", keys.ScopeLookup,
+			"The comma separated scopes granted ("+strings.Join(keys.Scopes, ", ")+")")
+		fs.StringVar(&expires, "expires", "",
+			"When the key expires, as a duration from now or an RFC 3339 time")
+	case "quota":
+		fs.IntVar(&quota.Daily, "daily", 0, "The requests allowed per UTC day, 0 for the default")
+		fs.IntVar(&quota.Monthly, "monthly", 0,
+			"The requests allowed per UTC month, 0 for the default")
+	case "rotate":
+		fs.BoolVar(&webhook, "webhook", false,
+			"Replace the secret signing the callbacks of jobs rather than the API secret")
+	case "cors":
+		fs.StringVar(&origins, "origins", "",
+			"The comma separated origins allowed, * for any or none for the global ones")
+		fs.StringVar(&methods, "methods", "",
+			"The comma separated methods allowed, none for the global ones")
+		fs.StringVar(&headers, "headers", "",
+			"The comma separated request headers allowed, none for the global ones")
+	}
+	if err := fs.Parse(args[1:]); err == flag.ErrHelp {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Every subcommand but list and create takes the ID of a key
+	var id string
+	switch args[0] {
+	case "create", "list":
+		if fs.NArg() != 0 {
+			return errUsage
+		}
+	case "revoke", "rotate", "quota", "cors":
+		if fs.NArg() != 1 {
+			return errUsage
+		}
+		id = fs.Arg(0)
+	default:
+		return fmt.Errorf("unknown keys command %q, run trumail help", args[0])
+	}
+
+	s, err := keys.Open(*db)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "create":
+		if strings.TrimSpace(name) == "" {
+			return errors.New("a key name is required")
+		}
+		expiry, err := parseExpiry(expires, time.Now())
+		if err != nil {
+			return err
+		}
+		key, secret, err := s.Create(name, strings.Split(scopes, ","), expiry)
+		if err != nil {
+			return err
+		}
+		return printSecret(w, key, secret, key.WebhookSecret)
+	case "list":
+		list, err := s.Keys()
+		if err != nil {
+			return err
+		}
+		return printKeys(w, list...)
+	case "revoke":
+		key, err := s.Revoke(id)
+		if err != nil {
+			return err
+		}
+		return printKeys(w, key)
+	case "quota":
+		if quota.Daily < 0 || quota.Monthly < 0 {
+			return errors.New("quotas can't be negative")
+		}
+		key, err := s.SetQuota(id, quota)
+		if err != nil {
+			return err
+		}
+		return printKeys(w, key)
+	case "cors":
+		key, err := s.SetCORS(id, keys.CORS{Origins: config.List(origins),
+			Methods: config.List(methods), Headers: config.List(headers)})
+		if err != nil {
+			return err
+		}
+		return printKeys(w, key)
+	default:
+		if webhook {
+			key, secret, err := s.RotateWebhookSecret(id)
+			if err != nil {
+				return err
+			}
+			return printSecret(w, key, "", secret)
+		}
+		key, secret, err := s.Rotate(id)
+		if err != nil {
+			return err
+		}
+		return printSecret(w, key, secret, "")
+	}
+}
+
+// usageCommand exports the daily usage of each API key
+func usageCommand(args []string, w io.Writer) error {
+	c, err := defaults()
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("usage", flag.ContinueOnError)
+	fs.SetOutput(w)
+	db := fs.String("db", c.UsageDB, "The path of the usage database")
+	from := fs.String("from", "", "The first day exported, by default the first of the month")
+	to := fs.String("to", "", "The last day exported, by default today")
+	key := fs.String("key", "", "The ID of the only API key exported")
+	format := fs.String("format", "csv", "The format exported (csv, json)")
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("unsupported format %q, must be csv or json", *format)
+	}
+
+	first, last, err := usage.ParseRange(*from, *to, time.Now())
+	if err != nil {
+		return err
+	}
+	s, err := usage.Open(*db)
+	if err != nil {
+		return err
+	}
+	records, err := s.Usage(first, last, *key)
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		return usage.WriteJSON(w, records)
+	}
+	return usage.WriteCSV(w, records)
+}
+
+// parseExpiry parses an expiry given as a duration from now or an RFC 3339
+// time. An empty expiry never expires
+func parseExpiry(expires string, now time.Time) (time.Time, error) {
+	if expires == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(expires); err == nil {
+		return now.Add(d).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, expires)
+	if err != nil {
+		return t, fmt.Errorf("invalid expiry %q, must be a duration or RFC 3339 time", expires)
+	}
+	return t.UTC(), nil
+}
+
+// printSecret prints a key along with its secret and webhook secret, when
+// not empty, which are never shown again
+func printSecret(w io.Writer, key *keys.Key, secret, webhookSecret string) error {
+	if err := printKeys(w, key); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	if secret != "" {
+		fmt.Fprintf(w, "Secret: %s\n", secret)
+	}
+	if webhookSecret != "" {
+		fmt.Fprintf(w, "Webhook secret: %s\n", webhookSecret)
+	}
+	_, err := fmt.Fprintln(w, "Store it now, it can't be shown again.")
+	return err
+}
+
+// printKeys prints a table of keys
+func printKeys(w io.Writer, list ...*keys.Key) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSCOPES\tSTATUS\tQUOTA\tORIGINS\tCREATED\tEXPIRES")
+	now := time.Now()
+	for _, key := range list {
+		status, expires := "enabled", "never"
+		switch {
+		case !key.Enabled:
+			status = "revoked"
+		case key.Expired(now):
+			status = "expired"
+		}
+		if !key.Expires.IsZero() {
+			expires = key.Expires.Format(time.RFC3339)
+		}
+		origins := "default"
+		if len(key.CORS.Origins) > 0 {
+			origins = strings.Join(key.CORS.Origins, ",")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name,
+			strings.Join(key.Scopes, ","), status, formatQuota(key.Quota), origins,
+			key.Created.Format(time.RFC3339), expires)
+	}
+	return tw.Flush()
+}
+
+// formatQuota formats the daily and monthly limits of a Quota
+func formatQuota(q keys.Quota) string {
+	limit := func(n int) string {
+		if n == 0 {
+			return "default"
+		}
+		return strconv.Itoa(n)
+	}
+	return limit(q.Daily) + "/day " + limit(q.Monthly) + "/month"
+}
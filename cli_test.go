@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/usage"
+	"github.com/stretchr/testify/assert"
+)
+
+// secretLine matches the secret printed by the keys commands
+var secretLine = regexp.MustCompile(`Secret: (\S+)`)
+
+// runKeys runs a keys command against the passed database, returning its
+// output
+func runKeys(t *testing.T, db string, args ...string) (string, error) {
+	var out bytes.Buffer
+	args = append([]string{"keys", args[0], "-db", db}, args[1:]...)
+	err := run(args, &out)
+	return out.String(), err
+}
+
+func TestKeysCommands(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "keys.db")
+
+	// Create prints the secret once
+	out, err := runKeys(t, db, "create", "-name", "reporting", "-scopes", "lookup,batch",
+		"-expires", "24h")
+	assert.Nil(t, err)
+	match := secretLine.FindStringSubmatch(out)
+	assert.Len(t, match, 2)
+	secret := match[1]
+
+	s, err := keys.Open(db)
+	assert.Nil(t, err)
+	key, err := s.Authenticate(secret)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{keys.ScopeLookup, keys.ScopeBatch}, key.Scopes)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), key.Expires, time.Minute)
+	assert.Contains(t, out, "Webhook secret: "+key.WebhookSecret)
+
+	// List never prints secrets
+	out, err = runKeys(t, db, "list")
+	assert.Nil(t, err)
+	assert.Contains(t, out, key.ID)
+	assert.Contains(t, out, "reporting")
+	assert.NotContains(t, out, secret)
+
+	// Rotate prints a new secret
+	out, err = runKeys(t, db, "rotate", key.ID)
+	assert.Nil(t, err)
+	match = secretLine.FindStringSubmatch(out)
+	assert.Len(t, match, 2)
+	assert.NotEqual(t, secret, match[1])
+	assert.NotContains(t, out, "Webhook secret:")
+
+	// Rotating the webhook secret only prints the new webhook secret
+	out, err = runKeys(t, db, "rotate", "-webhook", key.ID)
+	assert.Nil(t, err)
+	assert.False(t, secretLine.MatchString(out))
+	assert.Contains(t, out, "Webhook secret: whsec_")
+	assert.NotContains(t, out, key.WebhookSecret)
+
+	// Quota limits the requests of the key
+	out, err = runKeys(t, db, "quota", "-daily", "100", key.ID)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "100/day default/month")
+
+	// CORS allows browsers on the origins of the key
+	out, err = runKeys(t, db, "cors", "-origins", "https://app.example.com, https://example.com",
+		key.ID)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "https://app.example.com,https://example.com")
+
+	// Revoke disables the key
+	out, err = runKeys(t, db, "revoke", key.ID)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "revoked")
+	assert.False(t, strings.Contains(out, "Secret:"))
+}
+
+func TestKeysCommandErrors(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "keys.db")
+	for _, args := range [][]string{
+		{"create"},
+		{"create", "-name", "bad", "-scopes", "root"},
+		{"create", "-name", "bad", "-expires", "tomorrow"},
+		{"revoke"},
+		{"revoke", "missing"},
+		{"quota", "-daily", "10", "missing"},
+		{"cors", "-origins", "example.com", "missing"},
+		{"delete", "id"},
+	} {
+		_, err := runKeys(t, db, args...)
+		assert.NotNil(t, err, args)
+	}
+	assert.NotNil(t, run([]string{"unknown"}, &bytes.Buffer{}))
+}
+
+func TestUsageCommand(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "usage.db")
+	s, err := usage.Open(db)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Add([]*usage.Record{
+		{Date: "2024-01-01", KeyID: "a", KeyName: "reporting", Lookups: 2, Deliverable: 2},
+		{Date: "2024-02-01", KeyID: "a", KeyName: "reporting", Lookups: 1, Unknown: 1},
+	}))
+
+	var out bytes.Buffer
+	assert.Nil(t, run([]string{"usage", "-db", db, "-from", "2024-01-01", "-to", "2024-01-31"}, &out))
+	assert.Equal(t, "date,keyId,keyName,lookups,deliverable,undeliverable,unknown,live,cached,batchRows\n"+
+		"2024-01-01,a,reporting,2,2,0,0,0,0,0\n", out.String())
+
+	out.Reset()
+	assert.Nil(t, run([]string{"usage", "-db", db, "-from", "2024-01-01", "-to", "2024-02-29",
+		"-format", "json"}, &out))
+	var records []*usage.Record
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &records))
+	assert.Len(t, records, 2)
+
+	for _, args := range [][]string{
+		{"usage", "-db", db, "-from", "2024-02-01", "-to", "2024-01-01"},
+		{"usage", "-db", db, "-format", "xml"},
+		{"usage", "-db", db, "extra"},
+	} {
+		assert.NotNil(t, run(args, &bytes.Buffer{}), args)
+	}
+}
+
+func TestConfigPrint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trumail.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte("jobs_workers: 4\n"), 0600))
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("AUTH_TOKEN", "token")
+
+	var out bytes.Buffer
+	assert.Nil(t, run([]string{"config", "print", "-port", "9000"}, &out))
+	assert.Contains(t, out.String(), "port: \"9000\"\n")
+	assert.Contains(t, out.String(), "jobs_workers: 4\n")
+	assert.Contains(t, out.String(), "auth_token: '********'\n")
+
+	// Invalid settings are reported before anything runs
+	err := run([]string{"serve", "-jobs-workers", "0"}, &bytes.Buffer{})
+	assert.EqualError(t, err, "invalid config\njobs_workers: must be at least 1, got 0")
+	assert.Equal(t, errUsage, run([]string{"config"}, &bytes.Buffer{}))
+}
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for expires, expected := range map[string]time.Time{
+		"":                     {},
+		"48h":                  now.Add(48 * time.Hour),
+		"2025-06-01T00:00:00Z": time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		actual, err := parseExpiry(expires, now)
+		assert.Nil(t, err)
+		assert.True(t, expected.Equal(actual), expires)
+	}
+}
@@ -0,0 +1,403 @@
+// Package config defines the settings of the trumail server, loaded from
+// their defaults, a YAML or TOML file, the environment and command-line
+// flags, each overriding the last
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sdwolfe32/trumail/identity"
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/logging"
+	"github.com/sdwolfe32/trumail/tracing"
+	"github.com/sdwolfe32/trumail/verifier"
+	"golang.org/x/crypto/acme/autocert"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting of the server. Each field is named by the
+// key of its config tag in files, by the upper-cased key in the
+// environment and by the key with dashes as a flag. Secret settings are
+// masked when printed and reloadable settings are updated on SIGHUP
+type Config struct {
+	// Listeners
+	Port             string        `config:"port" help:"The port serving the HTTP API"`
+	GRPCPort         string        `config:"grpc_port" help:"The port serving the gRPC API, disabled if empty"`
+	PublicURL        string        `config:"public_url" reload:"true" help:"The URL the API is reached at, which the callbacks of jobs link to"`
+	TLSCert          string        `config:"tls_cert" help:"The certificate file served over HTTPS"`
+	TLSKey           string        `config:"tls_key" help:"The key file of the certificate served over HTTPS"`
+	ACMEHosts        string        `config:"acme_hosts" help:"The comma separated hosts served over HTTPS with ACME certificates"`
+	ACMEEmail        string        `config:"acme_email" help:"The contact registered with the ACME CA"`
+	ACMEDirectory    string        `config:"acme_directory" help:"The directory URL of the ACME CA"`
+	ACMECache        string        `config:"acme_cache" help:"The directory caching ACME accounts and certificates"`
+	ACMECA           string        `config:"acme_ca" help:"The roots trusted when calling a test ACME CA"`
+	HTTPRedirectPort string        `config:"http_redirect_port" help:"The port redirecting HTTP to HTTPS, disabled if empty"`
+	ShutdownTimeout  time.Duration `config:"shutdown_timeout" help:"How long in-flight lookups may take to complete on shutdown before being aborted"`
+
+	// Browsers
+	CORSOrigins string        `config:"cors_origins" reload:"true" help:"The comma separated origins browsers may call the API from, * for any"`
+	CORSMethods string        `config:"cors_methods" reload:"true" help:"The comma separated methods browsers may call the API with"`
+	CORSHeaders string        `config:"cors_headers" reload:"true" help:"The comma separated request headers browsers may send"`
+	CORSMaxAge  time.Duration `config:"cors_max_age" reload:"true" help:"How long browsers may cache preflight responses"`
+	JSONP       bool          `config:"jsonp" reload:"true" help:"Whether responses may be requested in the jsonp format"`
+
+	// Verification
+	SourceAddr           string `config:"source_addr" help:"The address verifications are sent from"`
+	HELOHostname         string `config:"helo_hostname" help:"The hostname sent in HELO, found from the PTR of the public IP if empty"`
+	IPDiscovery          string `config:"ip_discovery" help:"How the public IP is discovered (url, interface, none)"`
+	IPDiscoveryURL       string `config:"ip_discovery_url" help:"The URL answering with the public IP"`
+	IPDiscoveryInterface string `config:"ip_discovery_interface" help:"The interface holding the public IP, by default the one routing to the internet"`
+	BatchLimit           int    `config:"batch_limit" help:"The most emails accepted on a single batch request"`
+	BatchWorkers         int    `config:"batch_workers" help:"The most SMTP sessions verified concurrently per batch"`
+	BatchSessionRCPTs    int    `config:"batch_session_rcpts" help:"The most emails of a batch sharing a domain verified over a single SMTP session"`
+
+	// Bulk jobs
+	JobsDB              string `config:"jobs_db" help:"The database persisting bulk jobs"`
+	JobsLimit           int    `config:"jobs_limit" help:"The most emails accepted on a single bulk job"`
+	JobsWorkers         int    `config:"jobs_workers" help:"The most bulk jobs verified concurrently"`
+	WebhookSecret       string `config:"webhook_secret" secret:"true" reload:"true" help:"The secret signing the callbacks of jobs created by keys without a webhook secret of their own"`
+	WebhookAllowPrivate bool   `config:"webhook_allow_private" help:"Whether callbacks may be sent to loopback, private and link-local addresses"`
+
+	// Authentication
+	AuthToken     string `config:"auth_token" secret:"true" help:"A static token accepted with every scope"`
+	KeysDB        string `config:"keys_db" help:"The database storing API keys"`
+	JWTSecret     string `config:"jwt_secret" secret:"true" help:"The secret verifying HS256 bearer tokens"`
+	JWTJWKS       string `config:"jwt_jwks" help:"The JWKS file verifying RS256/ES256 bearer tokens"`
+	JWTAudience   string `config:"jwt_audience" help:"The audience required of bearer tokens, if any"`
+	JWTIssuer     string `config:"jwt_issuer" help:"The issuer required of bearer tokens, if any"`
+	JWTScopeClaim string `config:"jwt_scope_claim" help:"The claim granting bearer tokens their scopes"`
+	JWTQuotaClaim string `config:"jwt_quota_claim" help:"The claim holding the quota of bearer tokens"`
+
+	// Rate limits
+	RateLimit    float64 `config:"rate_limit" reload:"true" help:"The requests per second allowed per API key"`
+	RateBurst    int     `config:"rate_burst" reload:"true" help:"The requests an API key may make at once"`
+	IPRateLimit  float64 `config:"ip_rate_limit" reload:"true" help:"The requests per second allowed per client IP"`
+	IPRateBurst  int     `config:"ip_rate_burst" reload:"true" help:"The requests a client IP may make at once"`
+	DailyQuota   int     `config:"daily_quota" reload:"true" help:"The emails verified per key or anonymous IP per day"`
+	MonthlyQuota int     `config:"monthly_quota" reload:"true" help:"The emails verified per key or anonymous IP per month"`
+	RateLimitDB  string  `config:"rate_limit_db" help:"The database persisting quota counters, held in memory if empty"`
+	TrustProxy   bool    `config:"trust_proxy" help:"Whether client IPs are taken from X-Forwarded-For"`
+
+	// Usage
+	UsageDB string `config:"usage_db" help:"The database recording the usage of API keys"`
+
+	// Readiness
+	HealthInterval      time.Duration `config:"health_interval" help:"How often the readiness checks run"`
+	HealthEgressWindow  time.Duration `config:"health_egress_window" help:"How recently port 25 must have been reached before probing health_test_mx"`
+	HealthTestMX        string        `config:"health_test_mx" help:"The mail server probed when port 25 wasn't reached recently"`
+	HealthMaxQueuedJobs int           `config:"health_max_queued_jobs" help:"The queued jobs at which busy job workers are saturated, 0 to never be"`
+
+	// Observability
+	LogLevel      string `config:"log_level" reload:"true" help:"The minimum level logged (debug, info, warn, error)"`
+	LogRedaction  string `config:"log_redaction" help:"How addresses are redacted in logs (mask, hash, none)"`
+	TraceExporter string `config:"trace_exporter" help:"Where spans are exported (none, stdout, otlp)"`
+}
+
+// Default returns the Config used when nothing is configured
+func Default() *Config {
+	return &Config{
+		Port:                "8080",
+		GRPCPort:            "9090",
+		ACMEDirectory:       autocert.DefaultACMEDirectory,
+		ACMECache:           "trumail-acme",
+		ShutdownTimeout:     30 * time.Second,
+		CORSMethods:         "GET,POST,DELETE",
+		CORSHeaders:         "Accept,Authorization,Content-Type,X-Auth-Token",
+		CORSMaxAge:          10 * time.Minute,
+		JSONP:               true,
+		SourceAddr:          "admin@gmail.com",
+		IPDiscovery:         identity.DiscoverURL,
+		IPDiscoveryURL:      identity.DefaultURL,
+		BatchLimit:          100,
+		BatchWorkers:        10,
+		BatchSessionRCPTs:   verifier.DefaultSessionRCPTs,
+		JobsDB:              "trumail.db",
+		JobsLimit:           1000000,
+		JobsWorkers:         2,
+		KeysDB:              "trumail-keys.db",
+		JWTScopeClaim:       "scope",
+		JWTQuotaClaim:       "quota",
+		RateLimit:           10,
+		RateBurst:           20,
+		IPRateLimit:         10,
+		IPRateBurst:         20,
+		UsageDB:             "trumail-usage.db",
+		HealthInterval:      15 * time.Second,
+		HealthEgressWindow:  15 * time.Minute,
+		HealthTestMX:        "gmail-smtp-in.l.google.com:25",
+		HealthMaxQueuedJobs: 100,
+		LogLevel:            "info",
+		LogRedaction:        logging.RedactMask,
+		TraceExporter:       tracing.ExporterNone,
+	}
+}
+
+// Validate checks every setting, returning an error describing each
+// invalid one
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]interface{}{key}, args...)...))
+	}
+
+	// Listeners
+	for key, port := range map[string]string{"port": c.Port, "grpc_port": c.GRPCPort,
+		"http_redirect_port": c.HTTPRedirectPort} {
+		if port == "" && key != "port" {
+			continue // Optional listeners are disabled when empty
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			invalid(key, "%q is not a port between 1 and 65535", port)
+		}
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		invalid("tls_cert", "tls_cert and tls_key must be set together")
+	}
+	if c.TLSCert != "" && c.ACMEHosts != "" {
+		invalid("acme_hosts", "can't be set along with tls_cert")
+	}
+	if c.HTTPRedirectPort != "" && c.TLSCert == "" && c.ACMEHosts == "" {
+		invalid("http_redirect_port", "requires tls_cert or acme_hosts")
+	}
+	if c.HTTPRedirectPort != "" && c.HTTPRedirectPort == c.Port {
+		invalid("http_redirect_port", "can't be the same as port")
+	}
+	if c.ShutdownTimeout <= 0 {
+		invalid("shutdown_timeout", "must be positive, got %s", c.ShutdownTimeout)
+	}
+	if u, err := url.Parse(c.PublicURL); c.PublicURL != "" && (err != nil ||
+		(u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "") {
+		invalid("public_url", "%q is not an http or https URL like https://trumail.example.com", c.PublicURL)
+	}
+
+	// Browsers
+	for _, origin := range List(c.CORSOrigins) {
+		if !keys.ValidOrigin(origin) {
+			invalid("cors_origins", "%q is not * or an origin like https://example.com", origin)
+		}
+	}
+	if c.CORSMaxAge < 0 {
+		invalid("cors_max_age", "can't be negative, got %s", c.CORSMaxAge)
+	}
+
+	// Verification and bulk jobs
+	if addr, err := mail.ParseAddress(c.SourceAddr); err != nil || addr.Name != "" {
+		invalid("source_addr", "%q is not an email address", c.SourceAddr)
+	}
+	if strings.ContainsAny(c.HELOHostname, " \t[]@") {
+		invalid("helo_hostname", "%q is not a hostname", c.HELOHostname)
+	}
+	if _, err := identity.NewDiscoverer(c.IPDiscovery, c.IPDiscoveryURL,
+		c.IPDiscoveryInterface); err != nil {
+		invalid("ip_discovery", "%q must be one of url, interface or none", c.IPDiscovery)
+	}
+	for key, n := range map[string]int{"batch_limit": c.BatchLimit,
+		"batch_workers": c.BatchWorkers, "batch_session_rcpts": c.BatchSessionRCPTs,
+		"jobs_limit": c.JobsLimit, "jobs_workers": c.JobsWorkers} {
+		if n < 1 {
+			invalid(key, "must be at least 1, got %d", n)
+		}
+	}
+	for key, path := range map[string]string{"jobs_db": c.JobsDB, "keys_db": c.KeysDB,
+		"usage_db": c.UsageDB} {
+		if path == "" {
+			invalid(key, "is required")
+		}
+	}
+
+	// Readiness
+	if c.HealthInterval <= 0 {
+		invalid("health_interval", "must be positive, got %s", c.HealthInterval)
+	}
+	if c.HealthEgressWindow < c.HealthInterval {
+		invalid("health_egress_window", "must be at least health_interval, got %s", c.HealthEgressWindow)
+	}
+	if _, _, err := net.SplitHostPort(c.HealthTestMX); err != nil {
+		invalid("health_test_mx", "%q is not a host:port", c.HealthTestMX)
+	}
+
+	// Rate limits
+	for key, n := range map[string]float64{"rate_limit": c.RateLimit,
+		"ip_rate_limit": c.IPRateLimit, "rate_burst": float64(c.RateBurst),
+		"ip_rate_burst": float64(c.IPRateBurst), "daily_quota": float64(c.DailyQuota),
+		"monthly_quota":          float64(c.MonthlyQuota),
+		"health_max_queued_jobs": float64(c.HealthMaxQueuedJobs)} {
+		if n < 0 {
+			invalid(key, "can't be negative, got %v", n)
+		}
+	}
+
+	// Observability
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		invalid("log_level", "%q must be one of debug, info, warn or error", c.LogLevel)
+	}
+	if _, err := logging.NewRedactor(c.LogRedaction); err != nil {
+		invalid("log_redaction", "%q must be one of mask, hash or none", c.LogRedaction)
+	}
+	switch strings.ToLower(c.TraceExporter) {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		invalid("trace_exporter", "%q must be one of none, stdout or otlp", c.TraceExporter)
+	}
+
+	// Sort the errors so they're reported in a stable order
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
+}
+
+// Reload returns a copy of the Config taking the reloadable settings of
+// next, along with the keys of the reloadable settings that changed and
+// of those that changed but require a restart
+func (c *Config) Reload(next *Config) (reloaded *Config, changed, restart []string) {
+	cp := *c
+	current, updated := cp.settings(), next.settings()
+	for i, s := range current {
+		if s.raw() == updated[i].raw() {
+			continue
+		}
+		if !s.reload {
+			restart = append(restart, s.key)
+			continue
+		}
+		s.value.Set(updated[i].value)
+		changed = append(changed, s.key)
+	}
+	return &cp, changed, restart
+}
+
+// Print writes the Config as YAML, masking the value of every secret
+// setting that is set
+func (c *Config) Print(w io.Writer) error {
+	for _, s := range c.settings() {
+		var value interface{} = s.value.Interface()
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		} else if s.secret && !s.value.IsZero() {
+			value = mask
+		}
+		out, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s", s.key, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List splits a comma separated setting into its trimmed, non-empty values
+func List(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// mask replaces the value of secret settings when printed
+const mask = "********"
+
+// setting is a single field of a Config
+type setting struct {
+	key    string
+	help   string
+	secret bool
+	reload bool
+	value  reflect.Value
+}
+
+// settings returns every setting of the Config in order of declaration
+func (c *Config) settings() []*setting {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	settings := make([]*setting, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		settings = append(settings, &setting{
+			key:    f.Tag.Get("config"),
+			help:   f.Tag.Get("help"),
+			secret: f.Tag.Get("secret") == "true",
+			reload: f.Tag.Get("reload") == "true",
+			value:  v.Field(i),
+		})
+	}
+	return settings
+}
+
+// env returns the name of the environment variable holding a setting
+func (s *setting) env() string { return strings.ToUpper(s.key) }
+
+// flag returns the name of the flag holding a setting
+func (s *setting) flag() string { return strings.ReplaceAll(s.key, "_", "-") }
+
+// Set parses a raw value into the setting
+func (s *setting) Set(raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch s.value.Kind() {
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		s.value.SetInt(int64(n))
+	case reflect.Int64: // time.Duration
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", raw)
+		}
+		s.value.SetInt(int64(d))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		s.value.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		s.value.SetBool(b)
+	default:
+		s.value.SetString(raw)
+	}
+	return nil
+}
+
+// String formats the value of the setting, masking secrets. It's called
+// by the flag package to print defaults
+func (s *setting) String() string {
+	if !s.value.IsValid() {
+		return ""
+	}
+	if s.secret && !s.value.IsZero() {
+		return mask
+	}
+	return s.raw()
+}
+
+// raw formats the unmasked value of the setting
+func (s *setting) raw() string {
+	if s.value.Kind() == reflect.Float64 {
+		return strconv.FormatFloat(s.value.Float(), 'g', -1, 64)
+	}
+	return fmt.Sprint(s.value.Interface())
+}
+
+// IsBoolFlag allows boolean flags to be passed without a value
+func (s *setting) IsBoolFlag() bool {
+	return s.value.IsValid() && s.value.Kind() == reflect.Bool
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFile writes a config file with the passed name to a temporary
+// directory, returning its path
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+// env returns a lookup reading the passed variables
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := vars[key]
+		return value, ok
+	}
+}
+
+// load loads a Config with the passed flags and environment
+func load(args []string, vars map[string]string) (*Config, error) {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Load(fs, args, env(vars))
+}
+
+func TestLoad(t *testing.T) {
+	yamlFile := writeFile(t, "trumail.yaml", `
+port: "8081"
+rate_limit: 2.5
+trust_proxy: true
+acme_hosts: [a.example.com, b.example.com]
+log_level: debug
+`)
+
+	// Files override defaults
+	c, err := load([]string{"-config", yamlFile}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "8081", c.Port)
+	assert.Equal(t, 2.5, c.RateLimit)
+	assert.True(t, c.TrustProxy)
+	assert.Equal(t, "a.example.com,b.example.com", c.ACMEHosts)
+	assert.Equal(t, 20, c.RateBurst)
+
+	// The environment overrides files, and flags override the environment
+	c, err = load([]string{"-log-level", "warn", "-batch-limit=5"}, map[string]string{
+		FileEnv: yamlFile, "PORT": "8082", "LOG_LEVEL": "error", "BATCH_LIMIT": "7"})
+	assert.Nil(t, err)
+	assert.Equal(t, "8082", c.Port)
+	assert.Equal(t, "warn", c.LogLevel)
+	assert.Equal(t, 5, c.BatchLimit)
+	assert.Equal(t, 2.5, c.RateLimit)
+
+	// TOML files are loaded by extension
+	tomlFile := writeFile(t, "trumail.toml", `
+source_addr = "verify@example.com"
+jobs_workers = 4
+`)
+	c, err = load([]string{"-config=" + tomlFile}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "verify@example.com", c.SourceAddr)
+	assert.Equal(t, 4, c.JobsWorkers)
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := load([]string{"-config", writeFile(t, "trumail.yaml", "prot: 8081")}, nil)
+	assert.Contains(t, err.Error(), `unknown setting "prot"`)
+
+	_, err = load([]string{"-config", writeFile(t, "trumail.yaml", "rate_burst: lots")}, nil)
+	assert.Contains(t, err.Error(), `rate_burst: "lots" is not an integer`)
+
+	_, err = load([]string{"-config", writeFile(t, "trumail.json", "{}")}, nil)
+	assert.Contains(t, err.Error(), "unsupported config file")
+
+	_, err = load(nil, map[string]string{"TRUST_PROXY": "maybe"})
+	assert.EqualError(t, err, `TRUST_PROXY: "maybe" is not a boolean`)
+
+	_, err = load([]string{"-jobs-limit", "many"}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	assert.Nil(t, Default().Validate())
+
+	c := Default()
+	c.Port = "80800"
+	c.TLSCert = "cert.pem"
+	c.SourceAddr = "nobody"
+	c.BatchWorkers = 0
+	c.RateLimit = -1
+	c.LogLevel = "loud"
+	assert.EqualError(t, c.Validate(), `batch_workers: must be at least 1, got 0
+log_level: "loud" must be one of debug, info, warn or error
+port: "80800" is not a port between 1 and 65535
+rate_limit: can't be negative, got -1
+source_addr: "nobody" is not an email address
+tls_cert: tls_cert and tls_key must be set together`)
+
+	c = Default()
+	c.IPDiscovery = "magic"
+	assert.EqualError(t, c.Validate(), `ip_discovery: "magic" must be one of url, interface or none`)
+
+	c = Default()
+	c.PublicURL = "trumail.example.com"
+	assert.EqualError(t, c.Validate(),
+		`public_url: "trumail.example.com" is not an http or https URL like https://trumail.example.com`)
+
+	c = Default()
+	c.HTTPRedirectPort = "80"
+	assert.EqualError(t, c.Validate(), "http_redirect_port: requires tls_cert or acme_hosts")
+
+	c = Default()
+	c.HealthEgressWindow = time.Second
+	c.HealthTestMX = "mx.example.com"
+	c.ShutdownTimeout = 0
+	assert.EqualError(t, c.Validate(), `health_egress_window: must be at least health_interval, got 1s
+health_test_mx: "mx.example.com" is not a host:port
+shutdown_timeout: must be positive, got 0s`)
+
+	c = Default()
+	c.CORSOrigins = "https://example.com, example.com,https://example.com/app"
+	c.CORSMaxAge = -time.Second
+	assert.EqualError(t, c.Validate(), `cors_max_age: can't be negative, got -1s
+cors_origins: "example.com" is not * or an origin like https://example.com
+cors_origins: "https://example.com/app" is not * or an origin like https://example.com`)
+}
+
+func TestPrint(t *testing.T) {
+	c := Default()
+	c.AuthToken = "token"
+	c.RateLimit = 2.5
+
+	var out bytes.Buffer
+	assert.Nil(t, c.Print(&out))
+	assert.Contains(t, out.String(), "port: \"8080\"\n")
+	assert.Contains(t, out.String(), "rate_limit: 2.5\n")
+	assert.Contains(t, out.String(), "auth_token: '********'\n")
+	assert.Contains(t, out.String(), "jwt_secret: \"\"\n")
+	assert.NotContains(t, out.String(), "token\n")
+
+	// The printed config can be loaded back
+	path := writeFile(t, "trumail.yaml", out.String())
+	loaded, err := load([]string{"-config", path, "-auth-token", "token"}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, c, loaded)
+}
+
+func TestReload(t *testing.T) {
+	c := Default()
+	next := Default()
+	next.LogLevel = "debug"
+	next.DailyQuota = 100
+	next.Port = "8081"
+
+	reloaded, changed, restart := c.Reload(next)
+	assert.Equal(t, []string{"daily_quota", "log_level"}, changed)
+	assert.Equal(t, []string{"port"}, restart)
+	assert.Equal(t, "debug", reloaded.LogLevel)
+	assert.Equal(t, 100, reloaded.DailyQuota)
+	assert.Equal(t, "8080", reloaded.Port)
+	assert.Equal(t, "info", c.LogLevel)
+}
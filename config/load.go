@@ -0,0 +1,136 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv is the environment variable naming the config file loaded when
+// the -config flag isn't passed
+const FileEnv = "CONFIG_FILE"
+
+// Load loads a Config from its defaults, then the YAML or TOML file named
+// by the -config flag or CONFIG_FILE, then the environment read by
+// lookupEnv and finally the flags in args, which are registered on the
+// passed FlagSet. The Config isn't validated
+func Load(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	c := Default()
+
+	// Find the config file before any flag is parsed, ignoring errors
+	// reported by the full parse below
+	path, _ := lookupEnv(FileEnv)
+	pre := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	pre.SetOutput(io.Discard)
+	Default().register(pre)
+	pre.StringVar(&path, "config", path, "")
+	pre.Parse(args)
+
+	if path != "" {
+		if err := c.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.LoadEnv(lookupEnv); err != nil {
+		return nil, err
+	}
+	c.register(fs)
+	fs.String("config", path, "The YAML or TOML file settings are loaded from, defaults to $"+FileEnv)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadFile sets the settings found in the YAML (.yaml, .yml) or TOML
+// (.toml) file at the passed path. Unknown settings are refused
+func (c *Config) LoadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &values)
+	case ".toml":
+		err = toml.Unmarshal(b, &values)
+	default:
+		return fmt.Errorf("%s: unsupported config file, must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	settings := make(map[string]*setting)
+	for _, s := range c.settings() {
+		settings[s.key] = s
+	}
+	for key, value := range values {
+		s, ok := settings[key]
+		if !ok {
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+		raw, err := formatValue(value)
+		if err == nil {
+			err = s.Set(raw)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// LoadEnv sets the settings found in the environment read by lookupEnv
+func (c *Config) LoadEnv(lookupEnv func(string) (string, bool)) error {
+	for _, s := range c.settings() {
+		if raw, ok := lookupEnv(s.env()); ok {
+			if err := s.Set(raw); err != nil {
+				return fmt.Errorf("%s: %w", s.env(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// register registers a flag for each setting on the passed FlagSet,
+// defaulting to its current value
+func (c *Config) register(fs *flag.FlagSet) {
+	for _, s := range c.settings() {
+		fs.Var(s, s.flag(), s.help)
+	}
+}
+
+// formatValue formats a value decoded from a config file as it would be
+// set in the environment. Lists are joined by commas
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			raw, err := formatValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = raw
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		return "", errors.New("must be a single value or list")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
@@ -0,0 +1,114 @@
+// Package cors allows browsers on other origins to call the API, as
+// permitted globally or by the CORS of their API key
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/keys"
+)
+
+// ErrOriginNotAllowed is thrown when a preflight request is sent from an
+// origin that isn't allowed
+var ErrOriginNotAllowed = echo.NewHTTPError(http.StatusForbidden, "Origin not allowed")
+
+// exposedHeaders are the response headers browsers may read besides the
+// safelisted ones
+var exposedHeaders = strings.Join([]string{"X-RateLimit-Limit", "X-RateLimit-Remaining",
+	"X-RateLimit-Reset", "Retry-After"}, ", ")
+
+// Config is the global CORS of the server
+type Config struct {
+	keys.CORS
+	MaxAge time.Duration // How long browsers may cache a preflight response
+}
+
+// Middleware returns a middleware answering preflight requests and
+// allowing browsers to read the responses of cross-origin requests to
+// paths with any of the passed prefixes, or every path without any. The
+// current Config is retrieved on each request.
+//
+// Preflight requests are sent without a token, so they're allowed from
+// the origins of the Config along with those of any key of a
+// keys.CORSProvider. Other requests are only allowed from the origins of
+// their key when it has any, and those of the Config otherwise. Keys
+// without methods or headers of their own use those of the Config
+func Middleware(config func() Config, a keys.Authenticator, prefixes ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req, res := c.Request(), c.Response()
+			origin := req.Header.Get(echo.HeaderOrigin)
+			if origin == "" || !hasPrefix(req.URL.Path, prefixes) {
+				return next(c)
+			}
+			res.Header().Add(echo.HeaderVary, echo.HeaderOrigin)
+			cfg := config()
+			if req.Method == http.MethodOptions &&
+				req.Header.Get(echo.HeaderAccessControlRequestMethod) != "" {
+				return preflight(c, cfg, a, origin)
+			}
+
+			// Allow the response to be read once the key of the request is known
+			res.Before(func() {
+				allowed := cfg.CORS
+				if key := keys.FromContext(c.Request().Context()); key != nil &&
+					len(key.CORS.Origins) > 0 {
+					allowed = key.CORS
+				}
+				if allowed.AllowsOrigin(origin) {
+					res.Header().Set(echo.HeaderAccessControlAllowOrigin, origin)
+					res.Header().Set(echo.HeaderAccessControlExposeHeaders, exposedHeaders)
+				}
+			})
+			return next(c)
+		}
+	}
+}
+
+// preflight answers a preflight request from the passed origin with the
+// methods and headers allowed by the Config and the keys allowing it
+func preflight(c echo.Context, cfg Config, a keys.Authenticator, origin string) error {
+	var allowed keys.CORS
+	var ok bool
+	if cfg.AllowsOrigin(origin) {
+		allowed, ok = cfg.CORS, true
+	}
+	if p, isProvider := a.(keys.CORSProvider); isProvider {
+		if kc, keyAllowed := p.CORS(origin); keyAllowed {
+			allowed, ok = allowed.Merge(kc), true
+		}
+	}
+	if !ok {
+		return ErrOriginNotAllowed
+	}
+	if len(allowed.Methods) == 0 {
+		allowed.Methods = cfg.Methods
+	}
+	if len(allowed.Headers) == 0 {
+		allowed.Headers = cfg.Headers
+	}
+
+	h := c.Response().Header()
+	h.Set(echo.HeaderAccessControlAllowOrigin, origin)
+	h.Set(echo.HeaderAccessControlAllowMethods, strings.Join(allowed.Methods, ", "))
+	h.Set(echo.HeaderAccessControlAllowHeaders, strings.Join(allowed.Headers, ", "))
+	if cfg.MaxAge > 0 {
+		h.Set(echo.HeaderAccessControlMaxAge, strconv.Itoa(int(cfg.MaxAge.Seconds())))
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// hasPrefix reports whether the path has any of the passed prefixes, or
+// true when passed none
+func hasPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return len(prefixes) == 0
+}
@@ -0,0 +1,100 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/stretchr/testify/assert"
+)
+
+// testServer returns a server allowing the passed origins, and those of
+// its keys, on /v1/ along with the secret of a key allowed
+// https://app.example.com
+func testServer(t *testing.T, origins ...string) (*echo.Echo, string) {
+	s, err := keys.Open(filepath.Join(t.TempDir(), "keys.db"))
+	assert.Nil(t, err)
+	key, secret, err := s.Create("app", []string{keys.ScopeLookup}, time.Time{})
+	assert.Nil(t, err)
+	_, err = s.SetCORS(key.ID, keys.CORS{Origins: []string{"https://app.example.com"},
+		Methods: []string{"GET"}})
+	assert.Nil(t, err)
+
+	e := echo.New()
+	e.Use(Middleware(func() Config {
+		return Config{CORS: keys.CORS{Origins: origins, Methods: []string{"GET", "POST"},
+			Headers: []string{"X-Auth-Token"}}, MaxAge: 10 * time.Minute}
+	}, s, "/v1/"))
+	handler := func(c echo.Context) error { return c.String(http.StatusOK, "ok") }
+	e.GET("/v1/lookups/json", handler, keys.Middleware(s, keys.ScopeLookup, false))
+	e.GET("/metrics", handler)
+	return e, secret
+}
+
+// serve performs a request from the passed origin
+func serve(e *echo.Echo, method, target, origin string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set(echo.HeaderOrigin, origin)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPreflight(t *testing.T) {
+	e, _ := testServer(t, "https://example.com")
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		return serve(e, http.MethodOptions, "/v1/lookups/json", origin,
+			echo.HeaderAccessControlRequestMethod, http.MethodGet)
+	}
+
+	// Global origins are allowed the global methods and headers
+	rec := preflight("https://example.com")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://example.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "GET, POST", rec.Header().Get(echo.HeaderAccessControlAllowMethods))
+	assert.Equal(t, "X-Auth-Token", rec.Header().Get(echo.HeaderAccessControlAllowHeaders))
+	assert.Equal(t, "600", rec.Header().Get(echo.HeaderAccessControlMaxAge))
+
+	// The origins of keys are allowed their methods
+	rec = preflight("https://app.example.com")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "GET", rec.Header().Get(echo.HeaderAccessControlAllowMethods))
+	assert.Equal(t, "X-Auth-Token", rec.Header().Get(echo.HeaderAccessControlAllowHeaders))
+
+	// Other origins are rejected
+	rec = preflight("https://evil.example.com")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+}
+
+func TestActualRequest(t *testing.T) {
+	e, secret := testServer(t, "*")
+
+	// Anonymous requests are allowed the global origins
+	rec := serve(e, http.MethodGet, "/v1/lookups/json", "https://example.com")
+	assert.Equal(t, "https://example.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	assert.Contains(t, rec.Header().Get(echo.HeaderAccessControlExposeHeaders), "X-RateLimit-Remaining")
+	assert.Equal(t, echo.HeaderOrigin, rec.Header().Get(echo.HeaderVary))
+
+	// Keys with origins of their own are only allowed those
+	rec = serve(e, http.MethodGet, "/v1/lookups/json", "https://app.example.com",
+		keys.HeaderAuthToken, secret)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	rec = serve(e, http.MethodGet, "/v1/lookups/json", "https://example.com",
+		keys.HeaderAuthToken, secret)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+
+	// Paths without the prefixes are left alone
+	rec = serve(e, http.MethodGet, "/metrics", "https://example.com")
+	assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	assert.Empty(t, rec.Header().Get(echo.HeaderVary))
+}
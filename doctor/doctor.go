@@ -0,0 +1,311 @@
+// Package doctor diagnoses the most common reasons verifications fail on
+// a deployment: blocked port 25 egress, a missing or unconfirmed PTR, a
+// blocklisted IP, SPF or DMARC not covering the source address and an
+// unhealthy DNS resolver
+package doctor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sdwolfe32/trumail/identity"
+)
+
+const (
+	// StatusPass is reported by checks that found no problem
+	StatusPass = "pass"
+	// StatusWarn is reported by checks that found a likely problem or
+	// couldn't be completed
+	StatusWarn = "warn"
+	// StatusFail is reported by checks that found a problem preventing
+	// verifications
+	StatusFail = "fail"
+)
+
+// DefaultTestMX is the mail server port 25 egress is tested against
+const DefaultTestMX = "gmail-smtp-in.l.google.com:25"
+
+// DefaultDNSBLs are the blocklists the public IP is looked up in
+var DefaultDNSBLs = []string{"zen.spamhaus.org", "bl.spamcop.net", "b.barracudacentral.org"}
+
+// Resolver resolves the records checked by a Doctor. It's satisfied by
+// *net.Resolver
+type Resolver interface {
+	identity.Resolver
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Check  string
+	Status string
+	Detail string
+}
+
+// Report is the Result of every check run by a Doctor
+type Report []*Result
+
+// Failed returns the number of failed checks
+func (r Report) Failed() int {
+	failed := 0
+	for _, res := range r {
+		if res.Status == StatusFail {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Write writes the Report as a table
+func (r Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tCHECK\tDETAIL")
+	for _, res := range r {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", strings.ToUpper(res.Status), res.Check, res.Detail)
+	}
+	return tw.Flush()
+}
+
+// Doctor runs every check against the identity the server verifies with
+type Doctor struct {
+	Resolver   Resolver
+	Dial       func(ctx context.Context, network, addr string) (net.Conn, error)
+	Discoverer identity.Discoverer // Discovers the public IP, skipped if nil
+	Hostname   string              // The configured HELO hostname, if any
+	SourceAddr string
+	TestMX     string
+	DNSBLs     []string
+	Timeout    time.Duration // The time allowed for each check
+}
+
+// New generates a new Doctor checking the identity of a server
+// configured with the passed HELO hostname, Discoverer and source address
+func New(hostname string, d identity.Discoverer, sourceAddr string) *Doctor {
+	var dialer net.Dialer
+	return &Doctor{
+		Resolver:   net.DefaultResolver,
+		Dial:       dialer.DialContext,
+		Discoverer: d,
+		Hostname:   hostname,
+		SourceAddr: sourceAddr,
+		TestMX:     DefaultTestMX,
+		DNSBLs:     DefaultDNSBLs,
+		Timeout:    10 * time.Second,
+	}
+}
+
+// Run runs every check, returning their Report
+func (d *Doctor) Run(ctx context.Context) Report {
+	var report Report
+	check := func(fn func(context.Context) *Result) {
+		ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+		report = append(report, fn(ctx))
+	}
+
+	check(d.checkResolver)
+	check(d.checkEgress)
+
+	// The public IP is needed by the identity and blocklist checks
+	var ip net.IP
+	check(func(ctx context.Context) *Result {
+		var res *Result
+		ip, res = d.checkPublicIP(ctx)
+		return res
+	})
+	hostname := d.Hostname
+	if hostname == "" {
+		hostname = identity.Hostname(ctx, d.Resolver, ip)
+	}
+	check(func(ctx context.Context) *Result { return d.checkFCrDNS(ctx, hostname, ip) })
+	for _, zone := range d.DNSBLs {
+		check(func(ctx context.Context) *Result { return d.checkDNSBL(ctx, zone, ip) })
+	}
+
+	domain := d.SourceAddr[strings.LastIndex(d.SourceAddr, "@")+1:]
+	check(func(ctx context.Context) *Result { return d.checkSPF(ctx, domain, ip) })
+	check(func(ctx context.Context) *Result { return d.checkDMARC(ctx, domain) })
+	return report
+}
+
+// checkResolver resolves the test mail server, warning when the resolver
+// is slow to answer
+func (d *Doctor) checkResolver(ctx context.Context) *Result {
+	res := &Result{Check: "DNS resolver"}
+	host, _ := d.testMX()
+	start := time.Now()
+	addrs, err := d.Resolver.LookupHost(ctx, host)
+	took := time.Since(start).Round(time.Millisecond)
+	switch {
+	case err != nil:
+		res.Status, res.Detail = StatusFail, fmt.Sprintf("failed to resolve %s: %v", host, err)
+	case took > 2*time.Second:
+		res.Status, res.Detail = StatusWarn, fmt.Sprintf("resolved %s slowly, in %s", host, took)
+	default:
+		res.Status, res.Detail = StatusPass, fmt.Sprintf("resolved %s to %d addresses in %s",
+			host, len(addrs), took)
+	}
+	return res
+}
+
+// checkEgress connects to port 25 of the test mail server, expecting its
+// greeting
+func (d *Doctor) checkEgress(ctx context.Context) *Result {
+	res := &Result{Check: "Port 25 egress"}
+	host, port := d.testMX()
+	addr := net.JoinHostPort(host, port)
+	conn, err := d.Dial(ctx, "tcp", addr)
+	if err != nil {
+		res.Status, res.Detail = StatusFail,
+			fmt.Sprintf("failed to connect to %s, outbound port %s may be blocked: %v", addr, port, err)
+		return res
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	greeting, err := bufio.NewReader(conn).ReadString('\n')
+	greeting = strings.TrimSpace(greeting)
+	switch {
+	case err != nil:
+		res.Status, res.Detail = StatusWarn, fmt.Sprintf("connected to %s but got no greeting: %v", addr, err)
+	case !strings.HasPrefix(greeting, "220"):
+		res.Status, res.Detail = StatusWarn, fmt.Sprintf("connected to %s but was greeted %q", addr, greeting)
+	default:
+		res.Status, res.Detail = StatusPass, fmt.Sprintf("connected to %s and was greeted", addr)
+	}
+	return res
+}
+
+// testMX returns the host and port of the test mail server, port 25 by
+// default
+func (d *Doctor) testMX() (string, string) {
+	host, port, err := net.SplitHostPort(d.TestMX)
+	if err != nil {
+		return d.TestMX, "25"
+	}
+	return host, port
+}
+
+// checkPublicIP discovers the public IP
+func (d *Doctor) checkPublicIP(ctx context.Context) (net.IP, *Result) {
+	res := &Result{Check: "Public IP"}
+	if d.Discoverer == nil {
+		res.Status, res.Detail = StatusWarn, "discovery is disabled, the IP can't be checked"
+		return nil, res
+	}
+	ip, err := d.Discoverer.PublicIP(ctx)
+	if err != nil {
+		res.Status, res.Detail = StatusFail, fmt.Sprintf("failed to discover the public IP: %v", err)
+		return nil, res
+	}
+	res.Status, res.Detail = StatusPass, ip.String()
+	return ip, res
+}
+
+// checkFCrDNS checks the HELO hostname is forward-confirmed by reverse DNS
+func (d *Doctor) checkFCrDNS(ctx context.Context, hostname string, ip net.IP) *Result {
+	res := &Result{Check: "PTR and FCrDNS"}
+	if err := identity.CheckFCrDNS(ctx, d.Resolver, hostname, ip); err != nil {
+		res.Status, res.Detail = StatusFail, fmt.Sprintf("HELO %s: %v", hostname, err)
+		return res
+	}
+	res.Status, res.Detail = StatusPass, fmt.Sprintf("HELO %s is forward-confirmed", hostname)
+	if ip == nil {
+		res.Status = StatusWarn
+		res.Detail += ", but may not be the hostname of the unknown public IP"
+	}
+	return res
+}
+
+// checkDNSBL looks the public IP up in a DNS blocklist zone
+func (d *Doctor) checkDNSBL(ctx context.Context, zone string, ip net.IP) *Result {
+	res := &Result{Check: "DNSBL " + zone}
+	if ip == nil || ip.To4() == nil {
+		res.Status, res.Detail = StatusWarn, "only known IPv4 addresses can be checked"
+		return res
+	}
+	v4 := ip.To4()
+	query := fmt.Sprintf("%d.%d.%d.%d.%s", v4[3], v4[2], v4[1], v4[0], zone)
+	addrs, err := d.Resolver.LookupHost(ctx, query)
+	if isNotFound(err) {
+		res.Status, res.Detail = StatusPass, ip.String()+" isn't listed"
+		return res
+	} else if err != nil {
+		res.Status, res.Detail = StatusWarn, fmt.Sprintf("failed to query %s: %v", zone, err)
+		return res
+	}
+
+	// Blocklists answer from 127.255.255.0/24 when refusing a query, as
+	// Spamhaus does for queries through public resolvers
+	for _, addr := range addrs {
+		if strings.HasPrefix(addr, "127.255.255.") {
+			res.Status, res.Detail = StatusWarn, fmt.Sprintf("%s refused the query (%s), "+
+				"try a resolver it doesn't block", zone, addr)
+			return res
+		}
+	}
+	res.Status, res.Detail = StatusFail, fmt.Sprintf("%s is listed (%s)", ip, strings.Join(addrs, ", "))
+	return res
+}
+
+// checkSPF evaluates whether the SPF record of the source domain
+// authorizes the public IP
+func (d *Doctor) checkSPF(ctx context.Context, domain string, ip net.IP) *Result {
+	res := &Result{Check: "SPF " + domain}
+	result, err := (&spfChecker{r: d.Resolver, ip: ip}).check(ctx, domain)
+	switch {
+	case result == spfNone:
+		res.Status, res.Detail = StatusFail, domain+" has no SPF record"
+	case err != nil:
+		res.Status, res.Detail = StatusWarn, fmt.Sprintf("SPF %s: %v", result, err)
+	case ip == nil:
+		res.Status, res.Detail = StatusWarn, domain+" has an SPF record, but the public IP is unknown"
+	case result == spfPass:
+		res.Status, res.Detail = StatusPass, fmt.Sprintf("%s authorizes %s", domain, ip)
+	case result == spfFail:
+		res.Status, res.Detail = StatusFail, fmt.Sprintf("%s doesn't authorize %s (fail)", domain, ip)
+	default:
+		res.Status, res.Detail = StatusWarn, fmt.Sprintf("%s doesn't authorize %s (%s)", domain, ip, result)
+	}
+	return res
+}
+
+// checkDMARC looks up the DMARC policy of the source domain
+func (d *Doctor) checkDMARC(ctx context.Context, domain string) *Result {
+	res := &Result{Check: "DMARC " + domain}
+	records, err := d.Resolver.LookupTXT(ctx, "_dmarc."+domain)
+	if err != nil && !isNotFound(err) {
+		res.Status, res.Detail = StatusWarn, fmt.Sprintf("failed to look up DMARC: %v", err)
+		return res
+	}
+	for _, record := range records {
+		if !strings.HasPrefix(strings.ToLower(record), "v=dmarc1") {
+			continue
+		}
+		policy := "none"
+		for _, tag := range strings.Split(record, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(tag), "="); ok && strings.TrimSpace(k) == "p" {
+				policy = strings.TrimSpace(v)
+			}
+		}
+		res.Status, res.Detail = StatusPass, fmt.Sprintf("%s publishes a DMARC policy of %s", domain, policy)
+		return res
+	}
+	res.Status, res.Detail = StatusWarn, domain+" has no DMARC record"
+	return res
+}
+
+// isNotFound returns whether an error reports a name that doesn't exist
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -0,0 +1,157 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeResolver resolves records from maps keyed by the name looked up
+type fakeResolver struct {
+	ptr, hosts, txt map[string][]string
+	mx              map[string][]*net.MX
+}
+
+// notFound returns the error reporting a name doesn't exist
+func notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupAddr(_ context.Context, addr string) ([]string, error) {
+	if names, ok := r.ptr[addr]; ok {
+		return names, nil
+	}
+	return nil, notFound(addr)
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, notFound(host)
+}
+
+func (r *fakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if records, ok := r.txt[name]; ok {
+		return records, nil
+	}
+	return nil, notFound(name)
+}
+
+func (r *fakeResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	if mxs, ok := r.mx[name]; ok {
+		return mxs, nil
+	}
+	return nil, notFound(name)
+}
+
+// fixedIP is an identity.Discoverer returning a fixed IP or error
+type fixedIP struct {
+	ip  net.IP
+	err error
+}
+
+func (f fixedIP) PublicIP(context.Context) (net.IP, error) { return f.ip, f.err }
+
+// listenMX listens on a local port greeting connections as a mail server
+// would, returning its address
+func listenMX(t *testing.T) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("220 mx.test ESMTP ready\r\n"))
+			conn.Close()
+		}
+	}()
+	return lis.Addr().String()
+}
+
+// statuses maps each check of a Report to its status
+func statuses(report Report) map[string]string {
+	m := make(map[string]string)
+	for _, res := range report {
+		m[res.Check] = res.Status
+	}
+	return m
+}
+
+func TestDoctor(t *testing.T) {
+	resolver := &fakeResolver{
+		ptr: map[string][]string{"192.0.2.10": {"mail.example.com."}},
+		hosts: map[string][]string{
+			"127.0.0.1":                  {"127.0.0.1"},
+			"mail.example.com":           {"192.0.2.10"},
+			"10.2.0.192.bl.example.org":  {"127.0.0.2"},
+			"10.2.0.192.zen.example.org": {"127.255.255.254"},
+		},
+		txt: map[string][]string{
+			"example.com":        {"google-site-verification=abc", "v=spf1 a:mail.example.com -all"},
+			"_dmarc.example.com": {"v=DMARC1; p=reject; rua=mailto:dmarc@example.com"},
+		},
+	}
+	d := New("", fixedIP{ip: net.ParseIP("192.0.2.10")}, "verify@example.com")
+	d.Resolver = resolver
+	d.TestMX = listenMX(t)
+	d.DNSBLs = []string{"clean.example.org", "bl.example.org", "zen.example.org"}
+
+	report := d.Run(context.Background())
+	assert.Equal(t, map[string]string{
+		"DNS resolver":            StatusPass,
+		"Port 25 egress":          StatusPass,
+		"Public IP":               StatusPass,
+		"PTR and FCrDNS":          StatusPass,
+		"DNSBL clean.example.org": StatusPass,
+		"DNSBL bl.example.org":    StatusFail,
+		"DNSBL zen.example.org":   StatusWarn,
+		"SPF example.com":         StatusPass,
+		"DMARC example.com":       StatusPass,
+	}, statuses(report))
+	assert.Equal(t, 1, report.Failed())
+
+	var out bytes.Buffer
+	assert.Nil(t, report.Write(&out))
+	assert.Contains(t, out.String(), "FAIL    DNSBL bl.example.org")
+	assert.Contains(t, out.String(), "publishes a DMARC policy of reject")
+}
+
+func TestDoctorFailures(t *testing.T) {
+	// A closed port refuses connections
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	closed := lis.Addr().String()
+	lis.Close()
+
+	d := New("mail.example.com", fixedIP{err: errors.New("offline")}, "verify@example.com")
+	d.Resolver = &fakeResolver{hosts: map[string][]string{"127.0.0.1": {"127.0.0.1"}}}
+	d.TestMX = closed
+	d.DNSBLs = []string{"bl.example.org"}
+	d.Timeout = time.Second
+
+	report := d.Run(context.Background())
+	assert.Equal(t, map[string]string{
+		"DNS resolver":         StatusPass,
+		"Port 25 egress":       StatusFail,
+		"Public IP":            StatusFail,
+		"PTR and FCrDNS":       StatusFail,
+		"DNSBL bl.example.org": StatusWarn,
+		"SPF example.com":      StatusFail,
+		"DMARC example.com":    StatusWarn,
+	}, statuses(report))
+	for _, res := range report {
+		if res.Check == "Port 25 egress" {
+			assert.True(t, strings.Contains(res.Detail, "may be blocked"), res.Detail)
+		}
+	}
+}
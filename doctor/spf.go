@@ -0,0 +1,248 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// The results of evaluating an SPF record, as defined by RFC 7208
+const (
+	spfPass      = "pass"
+	spfFail      = "fail"
+	spfSoftFail  = "softfail"
+	spfNeutral   = "neutral"
+	spfNone      = "none"
+	spfPermError = "permerror"
+	spfTempError = "temperror"
+)
+
+// spfMaxLookups is the most DNS lookups an SPF evaluation may make
+const spfMaxLookups = 10
+
+// spfQualifiers maps the qualifier of a mechanism to the result of a match
+var spfQualifiers = map[byte]string{'+': spfPass, '-': spfFail, '~': spfSoftFail, '?': spfNeutral}
+
+// errSPFMacro is thrown when evaluating a mechanism using macros, which
+// aren't supported
+var errSPFMacro = errors.New("SPF macros aren't supported")
+
+// spfChecker evaluates whether SPF records authorize an IP. It supports
+// every mechanism but ptr, which is deprecated and never matches, and
+// macros
+type spfChecker struct {
+	r       Resolver
+	ip      net.IP
+	lookups int
+}
+
+// check evaluates the SPF record of the passed domain, returning its
+// result along with the reason for any error result
+func (s *spfChecker) check(ctx context.Context, domain string) (string, error) {
+	record, err := s.record(ctx, domain)
+	if err != nil {
+		return spfTempError, err
+	} else if record == "" {
+		return spfNone, nil
+	}
+
+	var redirect string
+	for _, term := range strings.Fields(record)[1:] {
+		if k, v, ok := strings.Cut(term, "="); ok {
+			if strings.EqualFold(k, "redirect") {
+				redirect = v
+			}
+			continue // Other modifiers don't affect the result
+		}
+		qualifier := byte('+')
+		if _, ok := spfQualifiers[term[0]]; ok {
+			qualifier, term = term[0], term[1:]
+		}
+		matched, err := s.match(ctx, domain, term)
+		if err != nil {
+			return spfPermError, err
+		}
+		if matched {
+			return spfQualifiers[qualifier], nil
+		}
+	}
+	if redirect != "" {
+		if err := s.lookup(); err != nil {
+			return spfPermError, err
+		}
+		result, err := s.check(ctx, redirect)
+		if result == spfNone {
+			return spfPermError, fmt.Errorf("redirect to %s without an SPF record", redirect)
+		}
+		return result, err
+	}
+	return spfNeutral, nil
+}
+
+// record returns the SPF record of a domain, or an empty string if it
+// has none
+func (s *spfChecker) record(ctx context.Context, domain string) (string, error) {
+	records, err := s.r.LookupTXT(ctx, domain)
+	if isNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	for _, record := range records {
+		if lower := strings.ToLower(record); lower == "v=spf1" || strings.HasPrefix(lower, "v=spf1 ") {
+			return record, nil
+		}
+	}
+	return "", nil
+}
+
+// match returns whether a mechanism of the SPF record of the passed
+// domain matches the IP
+func (s *spfChecker) match(ctx context.Context, domain, mechanism string) (bool, error) {
+	name, arg := mechanism, ""
+	if i := strings.IndexAny(mechanism, ":/"); i >= 0 {
+		name, arg = mechanism[:i], mechanism[i:]
+	}
+	if strings.Contains(arg, "%") {
+		return false, errSPFMacro
+	}
+	switch strings.ToLower(name) {
+	case "all":
+		return true, nil
+	case "ip4", "ip6":
+		cidr := strings.TrimPrefix(arg, ":")
+		if !strings.Contains(cidr, "/") {
+			cidr += "/128"
+			if strings.EqualFold(name, "ip4") {
+				cidr = strings.TrimSuffix(cidr, "/128") + "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return false, fmt.Errorf("invalid %s mechanism %q", name, mechanism)
+		}
+		return s.ip != nil && network.Contains(s.ip), nil
+	case "a", "mx":
+		if err := s.lookup(); err != nil {
+			return false, err
+		}
+		target, ones, err := spfDomainSpec(arg, domain, s.ip)
+		if err != nil {
+			return false, err
+		}
+		hosts := []string{target}
+		if strings.EqualFold(name, "mx") {
+			mxs, err := s.r.LookupMX(ctx, target)
+			if err != nil && !isNotFound(err) {
+				return false, err
+			}
+			hosts = hosts[:0]
+			for _, mx := range mxs {
+				hosts = append(hosts, strings.TrimSuffix(mx.Host, "."))
+			}
+		}
+		for _, host := range hosts {
+			addrs, err := s.r.LookupHost(ctx, host)
+			if err != nil && !isNotFound(err) {
+				return false, err
+			}
+			for _, addr := range addrs {
+				if s.ip != nil && spfSameNetwork(net.ParseIP(addr), s.ip, ones) {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	case "include":
+		if err := s.lookup(); err != nil {
+			return false, err
+		}
+		include := strings.TrimPrefix(arg, ":")
+		switch result, err := s.check(ctx, include); result {
+		case spfPass:
+			return true, nil
+		case spfNone:
+			return false, fmt.Errorf("include of %s without an SPF record", include)
+		case spfPermError, spfTempError:
+			return false, err
+		default:
+			return false, nil
+		}
+	case "exists":
+		if err := s.lookup(); err != nil {
+			return false, err
+		}
+		addrs, err := s.r.LookupHost(ctx, strings.TrimPrefix(arg, ":"))
+		if err != nil && !isNotFound(err) {
+			return false, err
+		}
+		return len(addrs) > 0, nil
+	case "ptr":
+		return false, s.lookup()
+	default:
+		return false, fmt.Errorf("unknown mechanism %q", mechanism)
+	}
+}
+
+// lookup counts a DNS lookup, refusing those over the limit
+func (s *spfChecker) lookup() error {
+	s.lookups++
+	if s.lookups > spfMaxLookups {
+		return fmt.Errorf("more than %d DNS lookups", spfMaxLookups)
+	}
+	return nil
+}
+
+// spfDomainSpec parses the optional domain and CIDR lengths of an a or mx
+// mechanism, returning the domain and the prefix length applying to ip
+func spfDomainSpec(arg, domain string, ip net.IP) (string, int, error) {
+	arg = strings.TrimPrefix(arg, ":")
+	target, cidrs, _ := strings.Cut(arg, "/")
+	if target == "" {
+		target = domain
+	}
+	ones := 32
+	if ip != nil && ip.To4() == nil {
+		ones = 128
+	}
+	if cidrs == "" {
+		return target, ones, nil
+	}
+
+	// The lengths are given as /v4 or /v4//v6 or //v6
+	v4, v6, dual := strings.Cut(cidrs, "//")
+	if strings.HasPrefix(cidrs, "/") {
+		v4, v6, dual = "", cidrs[1:], true
+	}
+	length := v4
+	if ones == 128 {
+		if !dual {
+			return target, ones, nil
+		}
+		length = v6
+	}
+	if length == "" {
+		return target, ones, nil
+	}
+	n, err := strconv.Atoi(length)
+	if err != nil || n < 0 || n > ones {
+		return "", 0, fmt.Errorf("invalid CIDR length %q", cidrs)
+	}
+	return target, n, nil
+}
+
+// spfSameNetwork returns whether two IPs share the passed prefix length
+func spfSameNetwork(a, b net.IP, ones int) bool {
+	if a == nil || (a.To4() == nil) != (b.To4() == nil) {
+		return false
+	}
+	bits := 128
+	if b.To4() != nil {
+		a, b, bits = a.To4(), b.To4(), 32
+	}
+	mask := net.CIDRMask(ones, bits)
+	return a.Mask(mask).Equal(b.Mask(mask))
+}
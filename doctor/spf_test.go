@@ -0,0 +1,61 @@
+package doctor
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSPF(t *testing.T) {
+	resolver := &fakeResolver{
+		hosts: map[string][]string{
+			"example.com":      {"198.51.100.1"},
+			"mx1.example.com":  {"203.0.113.7"},
+			"mail.example.net": {"2001:db8::25"},
+		},
+		mx: map[string][]*net.MX{"example.com": {{Host: "mx1.example.com.", Pref: 10}}},
+		txt: map[string][]string{
+			"ip.test":        {"v=spf1 ip4:192.0.2.0/24 ip6:2001:db8::/32 -all"},
+			"a.test":         {"v=spf1 a:example.com/24 ~all"},
+			"mx.test":        {"v=spf1 mx:example.com -all"},
+			"include.test":   {"v=spf1 include:ip.test ?all"},
+			"redirect.test":  {"v=spf1 redirect=ip.test"},
+			"neutral.test":   {"v=spf1 ip4:198.51.100.9"},
+			"loop.test":      {"v=spf1 include:loop.test -all"},
+			"macro.test":     {"v=spf1 exists:%{i}.spf.example.com -all"},
+			"missing.test":   {"v=spf1 include:nowhere.test -all"},
+			"a-dual.test":    {"v=spf1 a:mail.example.net//64 -all"},
+			"unknown.test":   {"v=spf1 magic -all"},
+			"notspf.test":    {"v=spf10 -all"},
+			"uppercase.test": {"V=SPF1 IP4:192.0.2.1 -ALL"},
+		},
+	}
+	check := func(domain, ip string) string {
+		result, _ := (&spfChecker{r: resolver, ip: net.ParseIP(ip)}).check(context.Background(), domain)
+		return result
+	}
+
+	assert.Equal(t, spfPass, check("ip.test", "192.0.2.44"))
+	assert.Equal(t, spfPass, check("ip.test", "2001:db8::1"))
+	assert.Equal(t, spfFail, check("ip.test", "198.51.100.1"))
+	assert.Equal(t, spfPass, check("a.test", "198.51.100.200"))
+	assert.Equal(t, spfSoftFail, check("a.test", "198.51.101.1"))
+	assert.Equal(t, spfPass, check("mx.test", "203.0.113.7"))
+	assert.Equal(t, spfFail, check("mx.test", "203.0.113.8"))
+	assert.Equal(t, spfPass, check("include.test", "192.0.2.1"))
+	assert.Equal(t, spfNeutral, check("include.test", "198.51.100.1"))
+	assert.Equal(t, spfPass, check("redirect.test", "192.0.2.1"))
+	assert.Equal(t, spfNeutral, check("neutral.test", "192.0.2.1"))
+	assert.Equal(t, spfPass, check("a-dual.test", "2001:db8::99"))
+	assert.Equal(t, spfPass, check("uppercase.test", "192.0.2.1"))
+	assert.Equal(t, spfNone, check("notspf.test", "192.0.2.1"))
+	assert.Equal(t, spfNone, check("nowhere.test", "192.0.2.1"))
+
+	// Invalid records are permanent errors
+	assert.Equal(t, spfPermError, check("loop.test", "192.0.2.1"))
+	assert.Equal(t, spfPermError, check("macro.test", "192.0.2.1"))
+	assert.Equal(t, spfPermError, check("missing.test", "192.0.2.1"))
+	assert.Equal(t, spfPermError, check("unknown.test", "192.0.2.1"))
+}
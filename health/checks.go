@@ -0,0 +1,105 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"sync/atomic"
+	"time"
+
+	"github.com/sdwolfe32/trumail/verifier"
+)
+
+// Resolver checks the DNS resolver by resolving the passed host
+func Resolver(r *net.Resolver, host string) Check {
+	return func(ctx context.Context) error {
+		if _, err := r.LookupHost(ctx, host); err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", host, err)
+		}
+		return nil
+	}
+}
+
+// Ping checks a storage backend with its Ping method
+func Ping(p interface{ Ping() error }) Check {
+	return func(context.Context) error { return p.Ping() }
+}
+
+// Pool checks a worker pool isn't saturated, which it is when every
+// worker is busy and at least maxQueued tasks are waiting for one. The
+// stats func reports the pools size, busy workers and queued tasks. A
+// zero maxQueued never saturates the pool
+func Pool(stats func() (workers, busy, queued int), maxQueued int) Check {
+	return func(context.Context) error {
+		workers, busy, queued := stats()
+		if maxQueued > 0 && busy >= workers && queued >= maxQueued {
+			return fmt.Errorf("all %d workers are busy with %d tasks queued", workers, queued)
+		}
+		return nil
+	}
+}
+
+// Egress is a verifier.Observer recording when a mail server was last
+// reached on port 25. Its Check passes when one was reached within the
+// window, probing a test mail server otherwise
+type Egress struct {
+	window time.Duration
+	testMX string
+	now    func() time.Time
+	dial   func(ctx context.Context, network, addr string) (net.Conn, error)
+	last   atomic.Int64 // When a mail server was last reached in unix nanoseconds
+}
+
+// NewEgress generates a new Egress probing the test mail server at the
+// passed host:port when none was reached within the window
+func NewEgress(window time.Duration, testMX string) *Egress {
+	var d net.Dialer
+	return &Egress{window: window, testMX: testMX, now: time.Now, dial: d.DialContext}
+}
+
+// ObserveLookup is a no-op, egress is recorded per connection
+func (e *Egress) ObserveLookup(context.Context, *verifier.Lookup, error, time.Duration) {}
+
+// ObservePhase records successful connections to mail servers
+func (e *Egress) ObservePhase(_ context.Context, ev verifier.PhaseEvent) {
+	if ev.Phase == verifier.PhaseDial && ev.Err == nil {
+		e.last.Store(e.now().UnixNano())
+	}
+}
+
+// ObserveSession is a no-op, egress is recorded per connection
+func (e *Egress) ObserveSession(int) {}
+
+// Check passes if a mail server was reached within the window, otherwise
+// probing the test mail server
+func (e *Egress) Check(ctx context.Context) error {
+	if last := e.last.Load(); last != 0 && e.now().Sub(time.Unix(0, last)) < e.window {
+		return nil
+	}
+	if err := e.probe(ctx); err != nil {
+		return fmt.Errorf("no mail server reached on port 25 in the last %s, probing %s failed: %w",
+			e.window, e.testMX, err)
+	}
+	e.last.Store(e.now().UnixNano())
+	return nil
+}
+
+// probe connects to the test mail server, ending the session with QUIT
+// once greeted
+func (e *Egress) probe(ctx context.Context) error {
+	conn, err := e.dial(ctx, "tcp", e.testMX)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	host, _, _ := net.SplitHostPort(e.testMX)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	return client.Quit()
+}
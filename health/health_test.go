@@ -0,0 +1,132 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sdwolfe32/trumail/verifier"
+	"github.com/stretchr/testify/assert"
+)
+
+// listenMX listens on a local port greeting connections as a mail server
+// would, signalling each session ended with QUIT, returning its address
+func listenMX(t *testing.T, quits chan<- struct{}) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("220 mx.test ESMTP ready\r\n"))
+			r := bufio.NewReader(conn)
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					break
+				}
+				if line == "QUIT\r\n" {
+					conn.Write([]byte("221 bye\r\n"))
+					quits <- struct{}{}
+					break
+				}
+				conn.Write([]byte("250 mx.test\r\n"))
+			}
+			conn.Close()
+		}
+	}()
+	return lis.Addr().String()
+}
+
+func TestMonitor(t *testing.T) {
+	m := NewMonitor(time.Minute, time.Second)
+	var dnsErr error
+	m.Add("dns", func(context.Context) error { return dnsErr })
+	m.Add("storage", func(context.Context) error { return nil })
+	var notified []bool
+	m.Notify(func(ready bool) { notified = append(notified, ready) })
+
+	// Components are unknown and the server isn't ready until checked
+	assert.False(t, m.Ready())
+	assert.Equal(t, StatusUnknown, m.Components()[0].Status)
+
+	m.CheckAll(context.Background())
+	assert.True(t, m.Ready())
+	components := m.Components()
+	assert.Equal(t, "dns", components[0].Name)
+	assert.Equal(t, StatusOK, components[0].Status)
+	assert.False(t, components[0].Checked.IsZero())
+
+	// A single failing component makes the server unready
+	dnsErr = errors.New("no route to resolver")
+	m.CheckAll(context.Background())
+	m.CheckAll(context.Background())
+	assert.False(t, m.Ready())
+	components = m.Components()
+	assert.Equal(t, StatusDown, components[0].Status)
+	assert.Equal(t, "no route to resolver", components[0].Detail)
+	assert.Equal(t, StatusOK, components[1].Status)
+
+	// Listeners are notified when added and whenever readiness changes
+	assert.Equal(t, []bool{false, true, false}, notified)
+}
+
+func TestMonitorTimeout(t *testing.T) {
+	m := NewMonitor(time.Minute, 10*time.Millisecond)
+	m.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	m.Start()
+	defer m.Close()
+	for i := 0; i < 100 && m.Components()[0].Status == StatusUnknown; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, StatusDown, m.Components()[0].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), m.Components()[0].Detail)
+}
+
+func TestPool(t *testing.T) {
+	stats := func(workers, busy, queued int) func() (int, int, int) {
+		return func() (int, int, int) { return workers, busy, queued }
+	}
+	ctx := context.Background()
+	assert.Nil(t, Pool(stats(2, 1, 50), 10)(ctx))
+	assert.Nil(t, Pool(stats(2, 2, 9), 10)(ctx))
+	assert.Nil(t, Pool(stats(2, 2, 50), 0)(ctx))
+	assert.EqualError(t, Pool(stats(2, 2, 10), 10)(ctx),
+		"all 2 workers are busy with 10 tasks queued")
+}
+
+func TestEgress(t *testing.T) {
+	quits := make(chan struct{}, 1)
+	e := NewEgress(time.Minute, listenMX(t, quits))
+	now := time.Now()
+	e.now = func() time.Time { return now }
+
+	// The test mail server is probed when none was reached recently
+	assert.Nil(t, e.Check(context.Background()))
+	select {
+	case <-quits:
+	case <-time.After(time.Second):
+		t.Fatal("probe didn't end the session with QUIT")
+	}
+
+	// Lookups connecting to mail servers avoid probing
+	now = now.Add(2 * time.Minute)
+	e.ObservePhase(context.Background(), verifier.PhaseEvent{Phase: verifier.PhaseDial})
+	e.testMX = "127.0.0.1:1"
+	assert.Nil(t, e.Check(context.Background()))
+
+	// Failed connections don't count as egress
+	now = now.Add(2 * time.Minute)
+	e.ObservePhase(context.Background(), verifier.PhaseEvent{Phase: verifier.PhaseDial,
+		Err: errors.New("connection refused")})
+	assert.Error(t, e.Check(context.Background()))
+}
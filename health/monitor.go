@@ -0,0 +1,162 @@
+// Package health checks the dependencies a trumail server needs to
+// verify addresses, reporting whether it's ready to serve traffic
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// StatusOK is the status of a healthy component, and of a ready server
+	StatusOK = "OK"
+	// StatusDown is the status of a failing component, and of a server
+	// that isn't ready
+	StatusDown = "DOWN"
+	// StatusUnknown is the status of a component that hasn't been checked
+	StatusUnknown = "UNKNOWN"
+)
+
+// Check checks a single component, returning why it's unhealthy if it is
+type Check func(ctx context.Context) error
+
+// Component is the last known health of a single component
+type Component struct {
+	Name    string    `json:"name" xml:"name"`
+	Status  string    `json:"status" xml:"status"`
+	Detail  string    `json:"detail,omitempty" xml:"detail,omitempty"`
+	Checked time.Time `json:"checked" xml:"checked"`
+}
+
+// Monitor runs a set of Checks periodically in the background so
+// readiness can be reported without waiting on slow dependencies
+type Monitor struct {
+	interval, timeout time.Duration
+	now               func() time.Time
+
+	mu         sync.Mutex
+	names      []string
+	checks     map[string]Check
+	components map[string]*Component
+	notify     []func(ready bool)
+	ready      bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewMonitor generates a new Monitor running its Checks at the passed
+// interval, allowing each to take up to timeout
+func NewMonitor(interval, timeout time.Duration) *Monitor {
+	return &Monitor{interval: interval, timeout: timeout, now: time.Now,
+		checks: make(map[string]Check), components: make(map[string]*Component),
+		stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+// Add adds a named Check, which must be done before the Monitor is
+// started
+func (m *Monitor) Add(name string, check Check) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.names = append(m.names, name)
+	m.checks[name] = check
+	m.components[name] = &Component{Name: name, Status: StatusUnknown}
+}
+
+// Notify calls fn with the readiness of the server whenever it changes,
+// and once when added
+func (m *Monitor) Notify(fn func(ready bool)) {
+	m.mu.Lock()
+	m.notify = append(m.notify, fn)
+	ready := m.ready
+	m.mu.Unlock()
+	fn(ready)
+}
+
+// Start runs every Check immediately then at the Monitors interval until
+// it's closed
+func (m *Monitor) Start() {
+	go func() {
+		defer close(m.done)
+		m.CheckAll(context.Background())
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.CheckAll(context.Background())
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the periodic checks of a started Monitor
+func (m *Monitor) Close() {
+	close(m.stop)
+	<-m.done
+}
+
+// CheckAll runs every Check concurrently, recording their results
+func (m *Monitor) CheckAll(ctx context.Context) {
+	m.mu.Lock()
+	names := append([]string(nil), m.names...)
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	results := make([]*Component, len(names))
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, m.timeout)
+			defer cancel()
+			c := &Component{Name: name, Status: StatusOK}
+			if err := m.checks[name](ctx); err != nil {
+				c.Status, c.Detail = StatusDown, err.Error()
+			}
+			c.Checked = m.now().UTC()
+			results[i] = c
+		}(i, name)
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	ready := true
+	for _, c := range results {
+		m.components[c.Name] = c
+		ready = ready && c.Status == StatusOK
+	}
+	changed := ready != m.ready
+	m.ready = ready
+	notify := m.notify
+	m.mu.Unlock()
+	if changed {
+		for _, fn := range notify {
+			fn(ready)
+		}
+	}
+}
+
+// Ready returns whether every component was healthy when last checked.
+// The server isn't ready until each has been checked once
+func (m *Monitor) Ready() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ready
+}
+
+// Components returns the last known health of every component in the
+// order they were added
+func (m *Monitor) Components() []*Component {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	components := make([]*Component, len(m.names))
+	for i, name := range m.names {
+		c := *m.components[name]
+		components[i] = &c
+	}
+	return components
+}
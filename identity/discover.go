@@ -0,0 +1,133 @@
+// Package identity determines the hostname trumail identifies itself with
+// in HELO and checks that it's forward-confirmed by reverse DNS, which
+// many mail servers require before answering
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DiscoverURL discovers the public IP by calling a URL answering with it
+	DiscoverURL = "url"
+	// DiscoverInterface uses the address of a local network interface
+	DiscoverInterface = "interface"
+	// DiscoverNone disables discovery, requiring a configured hostname
+	DiscoverNone = "none"
+)
+
+// DefaultURL is the URL called to discover the public IP by default
+const DefaultURL = "https://api.ipify.org/"
+
+// ErrNoAddress is thrown when a network interface has no usable address
+var ErrNoAddress = errors.New("No usable address found on the network interface")
+
+// Discoverer discovers the public IP of the server
+type Discoverer interface {
+	PublicIP(ctx context.Context) (net.IP, error)
+}
+
+// NewDiscoverer generates the named Discoverer, calling the passed URL or
+// reading the passed interface where relevant. A nil Discoverer is
+// returned for DiscoverNone
+func NewDiscoverer(method, url, iface string) (Discoverer, error) {
+	switch strings.ToLower(method) {
+	case DiscoverURL:
+		if url == "" {
+			url = DefaultURL
+		}
+		return &URL{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}, nil
+	case DiscoverInterface:
+		return Interface(iface), nil
+	case DiscoverNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported IP discovery %q, must be url, interface or none", method)
+	}
+}
+
+// URL discovers the public IP by calling a URL answering with it as
+// plain text, as api.ipify.org does
+type URL struct {
+	URL    string
+	Client *http.Client
+}
+
+// PublicIP calls the URL, parsing the IP it answers with
+func (u *URL) PublicIP(ctx context.Context) (net.IP, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := u.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded %s", u.URL, res.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, 64))
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return nil, fmt.Errorf("%s responded with an invalid IP %q", u.URL, body)
+	}
+	return ip, nil
+}
+
+// Interface discovers the IP from the addresses of the named network
+// interface, preferring IPv4. When unnamed the address of the interface
+// routing to the internet is used, which is only the public IP when the
+// server isn't behind NAT
+type Interface string
+
+// PublicIP returns the first global address of the interface
+func (i Interface) PublicIP(ctx context.Context) (net.IP, error) {
+	if i == "" {
+		// Connecting a UDP socket selects the outbound interface without
+		// sending any packet
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "udp", "192.0.2.1:25")
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).IP, nil
+	}
+
+	iface, err := net.InterfaceByName(string(i))
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var found net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+		if found == nil {
+			found = ipNet.IP
+		}
+	}
+	if found == nil {
+		return nil, ErrNoAddress
+	}
+	return found, nil
+}
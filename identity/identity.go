@@ -0,0 +1,90 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrNoPTR is thrown when an IP has no PTR record naming the hostname
+	ErrNoPTR = errors.New("No PTR record names the hostname")
+	// ErrNotForwardConfirmed is thrown when a hostname doesn't resolve back
+	// to the IP naming it
+	ErrNotForwardConfirmed = errors.New("The hostname doesn't resolve to the IP")
+)
+
+// Resolver resolves the records checked for an identity. It's satisfied
+// by *net.Resolver
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Hostname returns the name of the PTR record of the passed IP, or its
+// address literal if it has none. The system hostname is returned when
+// the IP is unknown
+func Hostname(ctx context.Context, r Resolver, ip net.IP) string {
+	if ip == nil {
+		if name, err := os.Hostname(); err == nil {
+			return name
+		}
+		return "localhost"
+	}
+	names, err := r.LookupAddr(ctx, ip.String())
+	if err != nil || len(names) == 0 {
+		return "[" + ip.String() + "]"
+	}
+	return strings.TrimSuffix(names[0], ".")
+}
+
+// CheckFCrDNS checks that the hostname is forward-confirmed by reverse
+// DNS: it must resolve to the passed IP, or to every address it resolves
+// to when the IP is unknown, whose PTR records must name it in turn
+func CheckFCrDNS(ctx context.Context, r Resolver, hostname string, ip net.IP) error {
+	if strings.HasPrefix(hostname, "[") {
+		return fmt.Errorf("%s: %w", strings.Trim(hostname, "[]"), ErrNoPTR)
+	}
+	addrs, err := r.LookupHost(ctx, hostname)
+	if err != nil {
+		return fmt.Errorf("%s doesn't resolve: %w", hostname, err)
+	}
+
+	// Only the known IP needs to be confirmed
+	if ip != nil {
+		found := false
+		for _, addr := range addrs {
+			found = found || net.ParseIP(addr).Equal(ip)
+		}
+		if !found {
+			return fmt.Errorf("%s resolves to %s, not %s: %w", hostname,
+				strings.Join(addrs, ", "), ip, ErrNotForwardConfirmed)
+		}
+		addrs = []string{ip.String()}
+	}
+
+	for _, addr := range addrs {
+		names, err := r.LookupAddr(ctx, addr)
+		var dnsErr *net.DNSError
+		if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+			return fmt.Errorf("%s doesn't resolve: %w", addr, err)
+		}
+		if !contains(names, hostname) {
+			return fmt.Errorf("%s: %w %s", addr, ErrNoPTR, hostname)
+		}
+	}
+	return nil
+}
+
+// contains returns whether the passed DNS names include the hostname
+func contains(names []string, hostname string) bool {
+	for _, name := range names {
+		if strings.EqualFold(strings.TrimSuffix(name, "."), strings.TrimSuffix(hostname, ".")) {
+			return true
+		}
+	}
+	return false
+}
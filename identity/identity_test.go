@@ -0,0 +1,108 @@
+package identity
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeResolver resolves records from maps of PTR and address records
+type fakeResolver struct{ ptr, hosts map[string][]string }
+
+func (r *fakeResolver) LookupAddr(_ context.Context, addr string) ([]string, error) {
+	if names, ok := r.ptr[addr]; ok {
+		return names, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// resolver resolves mail.example.com and 192.0.2.10 to each other, while
+// 192.0.2.20 is named by a PTR that doesn't resolve back
+var resolver = &fakeResolver{
+	ptr: map[string][]string{
+		"192.0.2.10": {"mail.example.com."},
+		"192.0.2.20": {"mail.example.com."},
+	},
+	hosts: map[string][]string{
+		"mail.example.com":  {"192.0.2.10"},
+		"other.example.com": {"192.0.2.30"},
+	},
+}
+
+func TestHostname(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "mail.example.com", Hostname(ctx, resolver, net.ParseIP("192.0.2.10")))
+	assert.Equal(t, "[192.0.2.99]", Hostname(ctx, resolver, net.ParseIP("192.0.2.99")))
+
+	system, _ := os.Hostname()
+	assert.Equal(t, system, Hostname(ctx, resolver, nil))
+}
+
+func TestCheckFCrDNS(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, CheckFCrDNS(ctx, resolver, "mail.example.com", net.ParseIP("192.0.2.10")))
+	assert.Nil(t, CheckFCrDNS(ctx, resolver, "mail.example.com", nil))
+
+	// The PTR doesn't resolve back to the IP
+	err := CheckFCrDNS(ctx, resolver, "mail.example.com", net.ParseIP("192.0.2.20"))
+	assert.ErrorIs(t, err, ErrNotForwardConfirmed)
+
+	// The hostname resolves to an IP without a PTR
+	err = CheckFCrDNS(ctx, resolver, "other.example.com", nil)
+	assert.ErrorIs(t, err, ErrNoPTR)
+	err = CheckFCrDNS(ctx, resolver, "[192.0.2.99]", net.ParseIP("192.0.2.99"))
+	assert.ErrorIs(t, err, ErrNoPTR)
+
+	err = CheckFCrDNS(ctx, resolver, "missing.example.com", nil)
+	assert.Contains(t, err.Error(), "missing.example.com doesn't resolve")
+}
+
+func TestURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.Write([]byte("not an ip"))
+			return
+		}
+		w.Write([]byte("192.0.2.10\n"))
+	}))
+	defer srv.Close()
+
+	d, err := NewDiscoverer(DiscoverURL, srv.URL, "")
+	assert.Nil(t, err)
+	ip, err := d.PublicIP(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "192.0.2.10", ip.String())
+
+	d, _ = NewDiscoverer(DiscoverURL, srv.URL+"/broken", "")
+	_, err = d.PublicIP(context.Background())
+	assert.Contains(t, err.Error(), "invalid IP")
+}
+
+func TestNewDiscoverer(t *testing.T) {
+	d, err := NewDiscoverer(DiscoverNone, "", "")
+	assert.Nil(t, err)
+	assert.Nil(t, d)
+
+	d, err = NewDiscoverer(DiscoverInterface, "", "eth0")
+	assert.Nil(t, err)
+	assert.Equal(t, Interface("eth0"), d)
+
+	_, err = NewDiscoverer("magic", "", "")
+	assert.NotNil(t, err)
+
+	// Loopback addresses are never used
+	_, err = Interface("lo").PublicIP(context.Background())
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrMissingColumn is thrown when the email column of a table can't be
+// found
+var ErrMissingColumn = errors.New("Missing email column")
+
+// TableSource is a Source reading the records of a table with a header
+type TableSource interface {
+	Source
+	// Header returns the names of the tables columns
+	Header() []string
+}
+
+// table is a TableSource reading rows from a CSV or TSV file
+type table struct {
+	r      *csv.Reader
+	header []string
+	column int      // The index of the email column
+	first  []string // The first record when the table has no header
+}
+
+// Table returns a TableSource reading a row for every record of the CSV
+// file, or TSV file if comma is a tab, in the passed reader. Records are
+// read as needed rather than loaded into memory. The email column may be
+// selected by its name in the header or its zero based index, and otherwise
+// the first column with a name containing "email" is used. Without a
+// header the columns are named by their index
+func Table(r io.Reader, comma rune, column string, header bool) (TableSource, error) {
+	t := &table{r: csv.NewReader(r)}
+	t.r.Comma = comma
+	t.r.FieldsPerRecord = -1 // Allow ragged rows
+	t.r.LazyQuotes = true
+
+	// Read the header, generating one from the first record if missing
+	first, err := t.r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, ErrNoRows
+		}
+		return nil, err
+	}
+	if len(first) > 0 {
+		first[0] = strings.TrimPrefix(first[0], "\ufeff") // Excels byte order mark
+	}
+	if header {
+		t.header = first
+	} else {
+		for i := range first {
+			t.header = append(t.header, strconv.Itoa(i))
+		}
+		t.first = first
+	}
+
+	// Find the email column
+	if t.column = t.find(column); t.column < 0 {
+		return nil, ErrMissingColumn
+	}
+	return t, nil
+}
+
+// find returns the index of the named column, or -1 if it can't be found
+func (t *table) find(column string) int {
+	for i, name := range t.header {
+		if column == "" && strings.Contains(strings.ToLower(name), "email") ||
+			column != "" && strings.EqualFold(strings.TrimSpace(name), column) {
+			return i
+		}
+	}
+	if i, err := strconv.Atoi(column); err == nil && i >= 0 && i < len(t.header) {
+		return i
+	}
+	return -1
+}
+
+func (t *table) Header() []string { return t.header }
+
+func (t *table) Next() (*Row, error) {
+	record := t.first
+	if record != nil {
+		t.first = nil
+	} else {
+		var err error
+		if record, err = t.r.Read(); err != nil {
+			return nil, err
+		}
+	}
+	row := &Row{Record: record}
+	if t.column < len(record) {
+		row.Email = strings.TrimSpace(record[t.column])
+	}
+	return row, nil
+}
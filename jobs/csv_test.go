@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readAll reads every row from the Source
+func readAll(t *testing.T, src Source) []*Row {
+	var rows []*Row
+	for {
+		row, err := src.Next()
+		if err == io.EOF {
+			return rows
+		}
+		assert.Nil(t, err)
+		rows = append(rows, row)
+	}
+}
+
+func TestTable(t *testing.T) {
+	src, err := Table(strings.NewReader("\ufeffName,Email Address\nJo,jo@example.com\nAl\n"), ',', "", true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Name", "Email Address"}, src.Header())
+
+	rows := readAll(t, src)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, "jo@example.com", rows[0].Email)
+	assert.Equal(t, []string{"Jo", "jo@example.com"}, rows[0].Record)
+	assert.Equal(t, "", rows[1].Email)
+	assert.Equal(t, []string{"Al"}, rows[1].Record)
+}
+
+func TestTableColumn(t *testing.T) {
+	src, err := Table(strings.NewReader("a\tB\nx\ty@example.com\n"), '\t', "b", true)
+	assert.Nil(t, err)
+	assert.Equal(t, "y@example.com", readAll(t, src)[0].Email)
+
+	src, err = Table(strings.NewReader("x,y@example.com\nz,w@example.com\n"), ',', "1", false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0", "1"}, src.Header())
+	rows := readAll(t, src)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, "y@example.com", rows[0].Email)
+	assert.Equal(t, "w@example.com", rows[1].Email)
+}
+
+func TestTableErrors(t *testing.T) {
+	_, err := Table(strings.NewReader("name,phone\n"), ',', "", true)
+	assert.Equal(t, ErrMissingColumn, err)
+	_, err = Table(strings.NewReader("name,phone\n"), ',', "2", true)
+	assert.Equal(t, ErrMissingColumn, err)
+	_, err = Table(strings.NewReader(""), ',', "", true)
+	assert.Equal(t, ErrNoRows, err)
+}
@@ -0,0 +1,147 @@
+// Package jobs verifies large lists of addresses in the background,
+// persisting the progress of each job so it survives a restart
+package jobs
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sdwolfe32/trumail/verifier"
+	"github.com/sdwolfe32/trumail/webhook"
+)
+
+const (
+	// StatusQueued is the status of a job waiting for a worker
+	StatusQueued = "queued"
+	// StatusRunning is the status of a job being verified
+	StatusRunning = "running"
+	// StatusCompleted is the status of a job with every row verified
+	StatusCompleted = "completed"
+	// StatusCancelled is the status of a job cancelled before completing
+	StatusCancelled = "cancelled"
+)
+
+const (
+	// CallbackPending is the status of a callback yet to be delivered
+	CallbackPending = "pending"
+	// CallbackDelivered is the status of a delivered callback
+	CallbackDelivered = "delivered"
+	// CallbackFailed is the status of a callback that couldn't be delivered
+	CallbackFailed = "failed"
+)
+
+// Job is a list of addresses verified in the background along with the
+// progress made verifying them
+type Job struct {
+	ID       string           `json:"id"`
+	Status   string           `json:"status"`
+	Options  verifier.Options `json:"options"`
+	Header   []string         `json:"header,omitempty"` // The columns of an uploaded table
+	Callback *Callback        `json:"callback,omitempty"`
+	KeyID    string           `json:"keyId,omitempty"` // The API key the job was created with
+	KeyName  string           `json:"keyName,omitempty"`
+	Created  time.Time        `json:"created"`
+	Updated  time.Time        `json:"updated"`
+
+	// Total is the number of rows in the job
+	Total int `json:"total"`
+	// Processed is the number of rows verified so far, each of which is
+	// also counted by the status of its lookup
+	Processed     int `json:"processed"`
+	Deliverable   int `json:"deliverable"`
+	Undeliverable int `json:"undeliverable"`
+	Unknown       int `json:"unknown"`
+}
+
+// Callback is a webhook POSTed once a job finishes along with the log of
+// its delivery
+type Callback struct {
+	URL      string            `json:"url"`
+	Secret   string            `json:"secret"`  // Signs each delivery attempt
+	BaseURL  string            `json:"baseUrl"` // The public URL of the API, if known, when the job was created
+	Status   string            `json:"status"`
+	Attempts []webhook.Attempt `json:"attempts,omitempty"`
+}
+
+// Finished reports whether the job will make no further progress
+func (j *Job) Finished() bool {
+	return j.Status == StatusCompleted || j.Status == StatusCancelled
+}
+
+// count counts a verified row towards the jobs progress
+func (j *Job) count(row *Row) {
+	j.Processed++
+	switch status, _ := verifier.Outcome(row.Lookup, row.Err()); status {
+	case verifier.StatusDeliverable:
+		j.Deliverable++
+	case verifier.StatusUndeliverable:
+		j.Undeliverable++
+	default:
+		j.Unknown++
+	}
+}
+
+// Row is a single address of a job along with its lookup once verified
+type Row struct {
+	Email  string                `json:"email"`
+	Record []string              `json:"record,omitempty"` // The row of an uploaded table
+	Lookup *verifier.Lookup      `json:"lookup,omitempty"`
+	Error  *verifier.LookupError `json:"error,omitempty"`
+}
+
+// Done reports whether the row has been verified
+func (r *Row) Done() bool { return r.Lookup != nil }
+
+// Err returns the error the rows lookup failed with, if any
+func (r *Row) Err() error {
+	if r.Error == nil {
+		return nil
+	}
+	return r.Error
+}
+
+// Source supplies the rows of a new job, returning io.EOF once exhausted
+type Source interface {
+	Next() (*Row, error)
+}
+
+// emails is a Source reading rows from a slice of addresses
+type emails []string
+
+// Emails returns a Source reading a row for each of the passed addresses
+func Emails(addresses []string) Source {
+	e := emails(addresses)
+	return &e
+}
+
+func (e *emails) Next() (*Row, error) {
+	if len(*e) == 0 {
+		return nil, io.EOF
+	}
+	row := &Row{Email: (*e)[0]}
+	*e = (*e)[1:]
+	return row, nil
+}
+
+// lines is a Source reading a row for each line of a reader
+type lines struct{ *bufio.Scanner }
+
+// Lines returns a Source reading a row for each non-blank line of the
+// passed reader
+func Lines(r io.Reader) Source {
+	return lines{bufio.NewScanner(r)}
+}
+
+func (l lines) Next() (*Row, error) {
+	for l.Scan() {
+		if email := strings.TrimSpace(l.Text()); email != "" {
+			return &Row{Email: email}, nil
+		}
+	}
+	if err := l.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
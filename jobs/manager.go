@@ -0,0 +1,383 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/verifier"
+	"github.com/sdwolfe32/trumail/webhook"
+)
+
+// chunkSize is the number of rows of a job verified as a single batch
+const chunkSize = 100
+
+// ErrClosed is thrown when creating a job on a closed Manager
+var ErrClosed = errors.New("Job manager is closed")
+
+// Payload builds the body of the callback POSTed once a job finishes
+type Payload func(*Job) ([]byte, error)
+
+// Manager verifies jobs on a persistent pool of workers, resuming any
+// unfinished jobs when started
+type Manager struct {
+	store       *Store
+	v           *verifier.Verifier
+	logger      *slog.Logger
+	concurrency int // The most domains verified concurrently per job
+	webhooks    *webhook.Client
+	payload     Payload
+
+	ctx  context.Context // Cancelled when the Manager is closed
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	workers int                           // The number of workers started
+	queue   []string                      // IDs of the jobs waiting for a worker
+	cancels map[string]context.CancelFunc // Cancels each running job
+	closed  bool
+}
+
+// NewManager generates a new Manager reference verifying the jobs in the
+// passed Store
+func NewManager(store *Store, v *verifier.Verifier, logger *slog.Logger, concurrency int) *Manager {
+	ctx, stop := context.WithCancel(context.Background())
+	m := &Manager{
+		store:       store,
+		v:           v,
+		logger:      logger,
+		concurrency: concurrency,
+		ctx:         ctx,
+		stop:        stop,
+		cancels:     make(map[string]context.CancelFunc),
+	}
+	m.cond = sync.NewCond(&m.mu)
+	return m
+}
+
+// SetWebhooks sets the Client delivering the callbacks of finished jobs
+// and the Payload they are sent with. Jobs with callbacks aren't notified
+// unless set before the Manager is started
+func (m *Manager) SetWebhooks(c *webhook.Client, payload Payload) {
+	m.webhooks, m.payload = c, payload
+}
+
+// CheckCallback asserts the webhook Client may deliver callbacks to the
+// passed URL
+func (m *Manager) CheckCallback(ctx context.Context, url string) error {
+	if m.webhooks == nil {
+		return nil
+	}
+	return m.webhooks.CheckURL(ctx, url)
+}
+
+// Start queues every unfinished job in the Store, resumes delivering the
+// callbacks of finished jobs and starts the passed number of workers
+func (m *Manager) Start(workers int) error {
+	jobs, err := m.store.Jobs()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.Finished() {
+			m.notify(job)
+		} else {
+			m.logger.Info("resuming job", "job_id", job.ID,
+				"processed", job.Processed, "total", job.Total)
+			m.enqueue(job.ID)
+		}
+	}
+	m.mu.Lock()
+	m.workers += workers
+	m.mu.Unlock()
+	for i := 0; i < workers; i++ {
+		m.wg.Add(1)
+		go m.work()
+	}
+	return nil
+}
+
+// Stats returns the number of workers, how many are running a job and
+// how many jobs are queued waiting for one
+func (m *Manager) Stats() (workers, running, queued int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.workers, len(m.cancels), len(m.queue)
+}
+
+// Close stops the workers, leaving any running jobs to be resumed by the
+// next Manager started on the Store
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	m.cond.Broadcast()
+	m.stop()
+	m.wg.Wait()
+}
+
+// Create stores a job verifying every row read from the Source, failing
+// with ErrTooManyRows if there are more than limit. The header of a
+// TableSource is kept on the job, as is the optional Callback POSTed once
+// it finishes. The job is attributed to the API key carried by the
+// context, which its lookups are reported under. It isn't verified until
+// passed to Queue, so it may be refused with Delete first
+func (m *Manager) Create(ctx context.Context, opts verifier.Options, src Source, limit int,
+	cb *Callback) (*Job, error) {
+	if m.ctx.Err() != nil {
+		return nil, ErrClosed
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	job := &Job{ID: id, Status: StatusQueued, Options: opts, Created: now, Updated: now,
+		Callback: cb}
+	if key := keys.FromContext(ctx); key != nil {
+		job.KeyID, job.KeyName = key.ID, key.Name
+	}
+	if cb != nil {
+		cb.Status = CallbackPending
+	}
+	if t, ok := src.(TableSource); ok {
+		job.Header = t.Header()
+	}
+	if err := m.store.Create(job, src, limit); err != nil {
+		return nil, err
+	}
+	m.logger.Info("created job", "job_id", job.ID, "total", job.Total)
+	return job, nil
+}
+
+// Queue queues the job with the passed ID, created by Create, to be
+// verified once a worker is free
+func (m *Manager) Queue(id string) {
+	m.enqueue(id)
+}
+
+// Delete removes the job with the passed ID, created by Create but never
+// queued, along with its rows
+func (m *Manager) Delete(id string) error {
+	return m.store.Delete(id)
+}
+
+// Job retrieves the job with the passed ID
+func (m *Manager) Job(id string) (*Job, error) {
+	return m.store.Job(id)
+}
+
+// Rows calls fn with every row of the job with the passed ID in order
+func (m *Manager) Rows(id string, fn func(*Row) error) error {
+	return m.store.Rows(id, fn)
+}
+
+// Cancel cancels the job with the passed ID, stopping it if running. Rows
+// already verified are kept
+func (m *Manager) Cancel(id string) (*Job, error) {
+	var cancelled bool
+	job, err := m.store.Update(id, func(job *Job) error {
+		if cancelled = !job.Finished(); cancelled {
+			job.Status = StatusCancelled
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+	}
+	m.mu.Unlock()
+	if cancelled {
+		m.notify(job)
+	}
+	return job, nil
+}
+
+// notify delivers the callback of a finished job in the background unless
+// it has already been delivered or failed. Deliveries interrupted by the
+// Manager closing are resumed when it's next started
+func (m *Manager) notify(job *Job) {
+	if job.Callback == nil || job.Callback.Status != CallbackPending || m.webhooks == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		if err := m.deliver(job); err != nil {
+			m.logger.Warn("callback failed", "job_id", job.ID, "error", err)
+		}
+	}()
+}
+
+// deliver delivers the callback of a finished job, recording each attempt
+// on the job
+func (m *Manager) deliver(job *Job) error {
+	body, err := m.payload(job)
+	if err != nil {
+		return err
+	}
+	cb := job.Callback
+	err = m.webhooks.Deliver(m.ctx, cb.URL, cb.Secret, "job."+job.Status, body,
+		func(a webhook.Attempt) {
+			if _, err := m.store.Update(job.ID, func(job *Job) error {
+				job.Callback.Attempts = append(job.Callback.Attempts, a)
+				return nil
+			}); err != nil {
+				m.logger.Error("failed to record callback", "job_id", job.ID, "error", err)
+			}
+		})
+	if m.ctx.Err() != nil {
+		return nil // Resume delivering once restarted
+	}
+	status := CallbackDelivered
+	if err != nil {
+		status = CallbackFailed
+	}
+	_, uerr := m.store.Update(job.ID, func(job *Job) error {
+		job.Callback.Status = status
+		return nil
+	})
+	if uerr != nil {
+		return uerr
+	}
+	return err
+}
+
+// enqueue adds the job with the passed ID to the queue
+func (m *Manager) enqueue(id string) {
+	m.mu.Lock()
+	m.queue = append(m.queue, id)
+	m.mu.Unlock()
+	m.cond.Signal()
+}
+
+// work runs jobs from the queue until the Manager is closed
+func (m *Manager) work() {
+	defer m.wg.Done()
+	for {
+		m.mu.Lock()
+		for len(m.queue) == 0 && !m.closed {
+			m.cond.Wait()
+		}
+		if m.closed {
+			m.mu.Unlock()
+			return
+		}
+		id := m.queue[0]
+		m.queue = m.queue[1:]
+		ctx, cancel := context.WithCancel(m.ctx)
+		m.cancels[id] = cancel
+		m.mu.Unlock()
+
+		if err := m.run(ctx, id); err != nil {
+			m.logger.Error("job failed", "job_id", id, "error", err)
+		}
+
+		m.mu.Lock()
+		delete(m.cancels, id)
+		m.mu.Unlock()
+		cancel()
+	}
+}
+
+// run verifies every pending row of the job with the passed ID a chunk at
+// a time, saving each chunk as it completes
+func (m *Manager) run(ctx context.Context, id string) error {
+	job, err := m.store.Update(id, func(job *Job) error {
+		if !job.Finished() {
+			job.Status = StatusRunning
+		}
+		return nil
+	})
+	if err != nil || job.Finished() {
+		return err
+	}
+	if job.KeyID != "" {
+		ctx = keys.WithKey(ctx, &keys.Key{ID: job.KeyID, Name: job.KeyName})
+	}
+
+	for from := 0; ctx.Err() == nil; {
+		indexes, rows, err := m.store.Pending(id, from, chunkSize)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		from = indexes[len(indexes)-1] + 1
+
+		// Verify the chunk, discarding rows that were cancelled or aborted by
+		// the Verifier shutting down
+		emails := make([]string, len(rows))
+		for i, row := range rows {
+			emails[i] = row.Email
+		}
+		done := make(map[int]*Row, len(rows))
+		var aborted bool
+		for r := range m.v.VerifyBatch(ctx, emails, job.Options, m.concurrency) {
+			if ctx.Err() != nil && r.Err == ctx.Err() {
+				continue
+			}
+			le, _ := r.Err.(*verifier.LookupError)
+			if le != nil && le.Message == verifier.ErrShuttingDown {
+				aborted = true
+				continue
+			}
+			row := rows[r.Index]
+			row.Lookup = r.Lookup
+			row.Error = le
+			done[indexes[r.Index]] = row
+		}
+		if err := m.store.Save(id, done); err != nil {
+			return err
+		}
+		if aborted {
+			return nil // Resumed once restarted
+		}
+	}
+
+	// Complete the job unless it was cancelled or the Manager closed
+	if ctx.Err() != nil {
+		return nil
+	}
+	var completed bool
+	job, err = m.store.Update(id, func(job *Job) error {
+		if completed = !job.Finished(); completed {
+			job.Status = StatusCompleted
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	m.logger.Info("completed job", "job_id", id, "status", job.Status,
+		"processed", job.Processed, "total", job.Total)
+	if completed {
+		m.notify(job)
+	}
+	return nil
+}
+
+// newID generates a random job ID
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,177 @@
+package jobs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/verifier"
+	"github.com/sdwolfe32/trumail/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingObserver counts the lookups performed by a Verifier, along with
+// those attributed to an API key
+type countingObserver struct{ lookups, keyed int32 }
+
+func (o *countingObserver) ObserveLookup(ctx context.Context, _ *verifier.Lookup, _ error, _ time.Duration) {
+	atomic.AddInt32(&o.lookups, 1)
+	if keys.FromContext(ctx) != nil {
+		atomic.AddInt32(&o.keyed, 1)
+	}
+}
+func (o *countingObserver) ObservePhase(context.Context, verifier.PhaseEvent) {}
+func (o *countingObserver) ObserveSession(int)                                {}
+
+// newManager generates a Manager on the Store with a Verifier reporting to
+// the passed Observer
+func newManager(s *Store, obs verifier.Observer) *Manager {
+	v := verifier.NewVerifier("localhost", "admin@localhost")
+	v.SetObserver(obs)
+	return NewManager(s, v, slog.New(slog.NewTextHandler(io.Discard, nil)), 2)
+}
+
+// waitFinished waits for the job with the passed ID to finish
+func waitFinished(t *testing.T, m *Manager, id string) *Job {
+	for i := 0; i < 100; i++ {
+		job, err := m.Job(id)
+		assert.Nil(t, err)
+		if job.Finished() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("job didn't finish")
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	obs := &countingObserver{}
+	m := newManager(openStore(t), obs)
+	assert.Nil(t, m.Start(2))
+	defer m.Close()
+	workers, running, queued := m.Stats()
+	assert.Equal(t, []int{2, 0, 0}, []int{workers, running, queued})
+
+	// Jobs are attributed to the key they were created with
+	ctx := keys.WithKey(context.Background(), &keys.Key{ID: "a", Name: "reporting"})
+	job, err := m.Create(ctx, verifier.DefaultOptions, Emails([]string{"one", "two", "three"}),
+		10, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusQueued, job.Status)
+	assert.Equal(t, 3, job.Total)
+	assert.Equal(t, "reporting", job.KeyName)
+	m.Queue(job.ID)
+
+	job = waitFinished(t, m, job.ID)
+	assert.Equal(t, StatusCompleted, job.Status)
+	assert.Equal(t, 3, job.Processed)
+	assert.Equal(t, 3, job.Undeliverable)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&obs.keyed))
+}
+
+func TestManagerResume(t *testing.T) {
+	s := openStore(t)
+	job := &Job{ID: "job", Status: StatusRunning, Options: verifier.DefaultOptions}
+	assert.Nil(t, s.Create(job, Emails([]string{"one", "two", "three"}), 10))
+	assert.Nil(t, s.Save("job", map[int]*Row{0: {Email: "one", Lookup: &verifier.Lookup{}}}))
+
+	obs := &countingObserver{}
+	m := newManager(s, obs)
+	assert.Nil(t, m.Start(1))
+	defer m.Close()
+
+	job = waitFinished(t, m, "job")
+	assert.Equal(t, StatusCompleted, job.Status)
+	assert.Equal(t, 3, job.Processed)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&obs.lookups))
+}
+
+func TestManagerCancel(t *testing.T) {
+	m := newManager(openStore(t), nil)
+	job, err := m.Create(context.Background(), verifier.DefaultOptions,
+		Emails([]string{"one"}), 10, nil)
+	assert.Nil(t, err)
+
+	job, err = m.Cancel(job.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusCancelled, job.Status)
+
+	// A cancelled job is never started
+	assert.Nil(t, m.Start(1))
+	defer m.Close()
+	time.Sleep(20 * time.Millisecond)
+	job, err = m.Job(job.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusCancelled, job.Status)
+	assert.Equal(t, 0, job.Processed)
+
+	_, err = m.Cancel("missing")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestManagerDelete(t *testing.T) {
+	m := newManager(openStore(t), nil)
+	assert.Nil(t, m.Start(1))
+	defer m.Close()
+	job, err := m.Create(context.Background(), verifier.DefaultOptions,
+		Emails([]string{"one"}), 10, nil)
+	assert.Nil(t, err)
+
+	// Jobs aren't verified until queued
+	time.Sleep(20 * time.Millisecond)
+	job, err = m.Job(job.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusQueued, job.Status)
+	assert.Equal(t, 0, job.Processed)
+
+	assert.Nil(t, m.Delete(job.ID))
+	_, err = m.Job(job.ID)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestManagerCallback(t *testing.T) {
+	var received int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Nil(t, webhook.Verify("secret", r.Header, body, time.Minute))
+		assert.Equal(t, "job.completed", r.Header.Get(webhook.HeaderEvent))
+		if atomic.AddInt32(&received, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		assert.Equal(t, `{"status":"completed"}`, string(body))
+	}))
+	defer srv.Close()
+
+	m := newManager(openStore(t), nil)
+	client := webhook.NewClient()
+	client.Backoff, client.AllowPrivate = time.Millisecond, true
+	m.SetWebhooks(client, func(job *Job) ([]byte, error) {
+		return []byte(`{"status":"` + job.Status + `"}`), nil
+	})
+	assert.Nil(t, m.Start(1))
+	defer m.Close()
+
+	job, err := m.Create(context.Background(), verifier.DefaultOptions, Emails([]string{"one"}), 10,
+		&Callback{URL: srv.URL, Secret: "secret"})
+	assert.Nil(t, err)
+	m.Queue(job.ID)
+	for i := 0; i < 100; i++ {
+		if job, _ = m.Job(job.ID); job.Callback.Status != CallbackPending {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, CallbackDelivered, job.Callback.Status)
+	assert.Len(t, job.Callback.Attempts, 2)
+	assert.Equal(t, http.StatusBadGateway, job.Callback.Attempts[0].Status)
+	assert.Equal(t, http.StatusOK, job.Callback.Attempts[1].Status)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&received))
+}
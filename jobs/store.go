@@ -0,0 +1,289 @@
+package jobs
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// rowBatch is the number of rows written per transaction when creating
+// a job
+const rowBatch = 1000
+
+var (
+	// ErrNotFound is thrown when a job doesn't exist
+	ErrNotFound = errors.New("Job not found")
+	// ErrTooManyRows is thrown when a job is created from more rows than
+	// allowed
+	ErrTooManyRows = errors.New("Too many rows")
+	// ErrNoRows is thrown when a job is created without any rows
+	ErrNoRows = errors.New("No rows")
+
+	// jobsBucket holds every Job by ID
+	jobsBucket = []byte("jobs")
+	// rowsBucket holds a bucket of Rows keyed by index for each job ID
+	rowsBucket = []byte("rows")
+)
+
+// Store persists jobs and their rows in a local bolt database so they
+// survive a restart
+type Store struct{ db *bolt.DB }
+
+// Open opens, creating if needed, the Store at the passed path
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(jobsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(rowsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db}, nil
+}
+
+// Close closes the Stores database
+func (s *Store) Close() error { return s.db.Close() }
+
+// Ping checks the Stores database can be read
+func (s *Store) Ping() error {
+	return s.db.View(func(*bolt.Tx) error { return nil })
+}
+
+// Create stores the passed job along with every row read from the Source,
+// failing with ErrNoRows if there are none or ErrTooManyRows if there are
+// more than limit. The jobs Total is set to the number of rows read
+func (s *Store) Create(job *Job, src Source, limit int) error {
+	// Write the rows in batches, each read before its own transaction so a
+	// slow Source doesn't hold the write lock
+	var index uint64
+	for done := false; !done; {
+		batch := make([]*Row, 0, rowBatch)
+		for len(batch) < rowBatch {
+			row, err := src.Next()
+			if err == io.EOF {
+				done = true
+				break
+			}
+			if err == nil && int(index)+len(batch) >= limit {
+				err = ErrTooManyRows
+			}
+			if err != nil {
+				s.Delete(job.ID)
+				return err
+			}
+			batch = append(batch, row)
+		}
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			rows, err := tx.Bucket(rowsBucket).CreateBucketIfNotExists([]byte(job.ID))
+			if err != nil {
+				return err
+			}
+			for i, row := range batch {
+				if err := putJSON(rows, key(index+uint64(i)), row); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			s.Delete(job.ID)
+			return err
+		}
+		index += uint64(len(batch))
+	}
+
+	if index == 0 {
+		s.Delete(job.ID)
+		return ErrNoRows
+	}
+
+	// Store the job once all of its rows are written
+	job.Total = int(index)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(jobsBucket), []byte(job.ID), job)
+	})
+}
+
+// Job retrieves the job with the passed ID
+func (s *Store) Job(id string) (*Job, error) {
+	var job Job
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return getJSON(tx.Bucket(jobsBucket), []byte(id), &job)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Jobs retrieves every stored job
+func (s *Store) Jobs() ([]*Job, error) {
+	var jobs []*Job
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).ForEach(func(_, v []byte) error {
+			var job Job
+			if err := json.Unmarshal(v, &job); err != nil {
+				return err
+			}
+			jobs = append(jobs, &job)
+			return nil
+		})
+	})
+	return jobs, err
+}
+
+// Update applies fn to the job with the passed ID and stores the result,
+// discarding the change if fn returns an error
+func (s *Store) Update(id string, fn func(*Job) error) (*Job, error) {
+	var job Job
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		jobs := tx.Bucket(jobsBucket)
+		if err := getJSON(jobs, []byte(id), &job); err != nil {
+			return err
+		}
+		if err := fn(&job); err != nil {
+			return err
+		}
+		job.Updated = time.Now().UTC()
+		return putJSON(jobs, []byte(id), &job)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Delete removes the job with the passed ID and all of its rows
+func (s *Store) Delete(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(rowsBucket).DeleteBucket([]byte(id)); err != nil &&
+			err != bolt.ErrBucketNotFound {
+			return err
+		}
+		return tx.Bucket(jobsBucket).Delete([]byte(id))
+	})
+}
+
+// Pending retrieves up to n rows of the job that haven't been verified,
+// starting at the passed index
+func (s *Store) Pending(id string, from, n int) ([]int, []*Row, error) {
+	var indexes []int
+	var pending []*Row
+	err := s.db.View(func(tx *bolt.Tx) error {
+		rows := tx.Bucket(rowsBucket).Bucket([]byte(id))
+		if rows == nil {
+			return ErrNotFound
+		}
+		c := rows.Cursor()
+		for k, v := c.Seek(key(uint64(from))); k != nil && len(pending) < n; k, v = c.Next() {
+			var row Row
+			if err := json.Unmarshal(v, &row); err != nil {
+				return err
+			}
+			if !row.Done() {
+				indexes = append(indexes, int(binary.BigEndian.Uint64(k)))
+				pending = append(pending, &row)
+			}
+		}
+		return nil
+	})
+	return indexes, pending, err
+}
+
+// Save stores the verified rows of a job by index, counting them towards
+// the jobs progress in the same transaction
+func (s *Store) Save(id string, rows map[int]*Row) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		jobs := tx.Bucket(jobsBucket)
+		var job Job
+		if err := getJSON(jobs, []byte(id), &job); err != nil {
+			return err
+		}
+		bucket := tx.Bucket(rowsBucket).Bucket([]byte(id))
+		if bucket == nil {
+			return ErrNotFound
+		}
+		for i, row := range rows {
+			if err := putJSON(bucket, key(uint64(i)), row); err != nil {
+				return err
+			}
+			job.count(row)
+		}
+		job.Updated = time.Now().UTC()
+		return putJSON(jobs, []byte(id), &job)
+	})
+}
+
+// Rows calls fn with every row of the job in order, stopping at the first
+// error returned. Rows are read a page at a time and fn is called outside
+// the read transaction, so a slow fn doesn't keep it open
+func (s *Store) Rows(id string, fn func(*Row) error) error {
+	for from, done := uint64(0), false; !done; {
+		page := make([]*Row, 0, rowBatch)
+		err := s.db.View(func(tx *bolt.Tx) error {
+			rows := tx.Bucket(rowsBucket).Bucket([]byte(id))
+			if rows == nil {
+				return ErrNotFound
+			}
+			c := rows.Cursor()
+			k, v := c.Seek(key(from))
+			for ; k != nil && len(page) < rowBatch; k, v = c.Next() {
+				var row Row
+				if err := json.Unmarshal(v, &row); err != nil {
+					return err
+				}
+				page = append(page, &row)
+				from = binary.BigEndian.Uint64(k) + 1
+			}
+			done = k == nil
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, row := range page {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// key encodes a row index so rows are ordered by index
+func key(index uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, index)
+	return k
+}
+
+// putJSON stores the JSON encoding of v under the passed key
+func putJSON(b *bolt.Bucket, k []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put(k, data)
+}
+
+// getJSON decodes the value stored under the passed key into v, failing
+// with ErrNotFound if there is none
+func getJSON(b *bolt.Bucket, k []byte, v interface{}) error {
+	data := b.Get(k)
+	if data == nil {
+		return ErrNotFound
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,136 @@
+package jobs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sdwolfe32/trumail/verifier"
+	"github.com/stretchr/testify/assert"
+)
+
+// openStore opens a Store in a temporary directory
+func openStore(t *testing.T) *Store {
+	s, err := Open(filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestStoreCreate(t *testing.T) {
+	s := openStore(t)
+	assert.Nil(t, s.Ping())
+	job := &Job{ID: "job", Status: StatusQueued}
+	assert.Nil(t, s.Create(job, Lines(strings.NewReader("a@b.com\n\n c@d.com \n")), 10))
+	assert.Equal(t, 2, job.Total)
+
+	stored, err := s.Job("job")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, stored.Total)
+
+	var emails []string
+	assert.Nil(t, s.Rows("job", func(r *Row) error {
+		emails = append(emails, r.Email)
+		return nil
+	}))
+	assert.Equal(t, []string{"a@b.com", "c@d.com"}, emails)
+}
+
+func TestStoreCreateTooManyRows(t *testing.T) {
+	s := openStore(t)
+	err := s.Create(&Job{ID: "job"}, Emails([]string{"a", "b", "c"}), 2)
+	assert.Equal(t, ErrTooManyRows, err)
+
+	_, err = s.Job("job")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ErrNotFound, s.Rows("job", func(*Row) error { return nil }))
+}
+
+func TestStorePendingAndSave(t *testing.T) {
+	s := openStore(t)
+	assert.Nil(t, s.Create(&Job{ID: "job"}, Emails([]string{"a", "b", "c", "d"}), 10))
+
+	assert.Nil(t, s.Save("job", map[int]*Row{
+		1: {Email: "b", Lookup: &verifier.Lookup{}},
+		2: {Email: "c", Lookup: &verifier.Lookup{ValidFormat: true, HostExists: true},
+			Error: &verifier.LookupError{Message: verifier.ErrTimeout}},
+	}))
+	indexes, rows, err := s.Pending("job", 0, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 3}, indexes)
+	assert.Equal(t, "a", rows[0].Email)
+	assert.Equal(t, "d", rows[1].Email)
+
+	indexes, _, err = s.Pending("job", 1, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{3}, indexes)
+
+	job, err := s.Job("job")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, job.Processed)
+	assert.Equal(t, 1, job.Undeliverable)
+	assert.Equal(t, 1, job.Unknown)
+}
+
+func TestStoreCreateNoRows(t *testing.T) {
+	s := openStore(t)
+	assert.Equal(t, ErrNoRows, s.Create(&Job{ID: "job"}, Lines(strings.NewReader("\n\n")), 10))
+	_, err := s.Job("job")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+// stalledSource is a Source calling stall before reading its first row
+type stalledSource struct {
+	Source
+	stall func()
+}
+
+func (s *stalledSource) Next() (*Row, error) {
+	if s.stall != nil {
+		s.stall()
+		s.stall = nil
+	}
+	return s.Source.Next()
+}
+
+// unlocked asserts fn, writing to the Store, completes within a second
+func unlocked(t *testing.T, fn func() error) {
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Error("the Store is locked")
+	}
+}
+
+func TestStoreUnlocked(t *testing.T) {
+	s := openStore(t)
+	assert.Nil(t, s.Create(&Job{ID: "a"}, Emails([]string{"a"}), 10))
+
+	// Other jobs are written to while the Source is read
+	save := func() error { return s.Save("a", map[int]*Row{0: {Email: "a"}}) }
+	assert.Nil(t, s.Create(&Job{ID: "b"}, &stalledSource{Emails([]string{"b"}),
+		func() { unlocked(t, save) }}, 10))
+
+	// And while the rows are read, a page at a time
+	emails := make([]string, rowBatch+1)
+	for i := range emails {
+		emails[i] = fmt.Sprint(i)
+	}
+	assert.Nil(t, s.Create(&Job{ID: "c"}, Emails(emails), len(emails)))
+	var read []string
+	assert.Nil(t, s.Rows("c", func(r *Row) error {
+		if len(read) == 0 {
+			unlocked(t, save)
+		}
+		read = append(read, r.Email)
+		return nil
+	}))
+	assert.Equal(t, emails, read)
+}
@@ -0,0 +1,155 @@
+package keys
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// HeaderAuthToken is the header holding the token of a request
+const HeaderAuthToken = "X-Auth-Token"
+
+// Authenticator authenticates the token presented on a request, returning
+// the Key it belongs to
+type Authenticator interface {
+	Authenticate(token string) (*Key, error)
+}
+
+// CORSProvider is implemented by Authenticators whose keys carry their own
+// CORS, so preflight requests, which are sent without a token, can be
+// allowed for their origins
+type CORSProvider interface {
+	// CORS returns the merged CORS of every key allowing the origin,
+	// reporting whether any does
+	CORS(origin string) (CORS, bool)
+}
+
+// static is an Authenticator accepting a single token
+type static struct {
+	token string
+	key   *Key
+}
+
+// Static returns an Authenticator accepting a single token with every
+// scope, attributed to a Key named static. An empty token authenticates
+// nothing
+func Static(token string) Authenticator {
+	return &static{token, &Key{ID: "static", Name: "static", Scopes: []string{ScopeAdmin},
+		Enabled: true}}
+}
+
+func (s *static) Authenticate(token string) (*Key, error) {
+	if s.token == "" {
+		return nil, ErrNoKeys
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		return nil, ErrInvalidKey
+	}
+	return s.key, nil
+}
+
+// chain is an Authenticator trying several in turn
+type chain []Authenticator
+
+// Chain returns an Authenticator trying each of the passed Authenticators
+// in turn until one recognizes the token. When none have any keys every
+// request is allowed anonymously, authenticating to a nil Key
+func Chain(as ...Authenticator) Authenticator {
+	return chain(as)
+}
+
+func (c chain) Authenticate(token string) (*Key, error) {
+	var configured bool
+	for _, a := range c {
+		key, err := a.Authenticate(token)
+		switch err {
+		case nil:
+			return key, nil
+		case ErrNoKeys:
+		case ErrInvalidKey:
+			configured = true
+		default:
+			return nil, err
+		}
+	}
+	if !configured {
+		return nil, nil
+	}
+	return nil, ErrInvalidKey
+}
+
+// CORS merges the CORS of every Authenticator that is a CORSProvider
+func (c chain) CORS(origin string) (CORS, bool) {
+	var cors CORS
+	var ok bool
+	for _, a := range c {
+		if p, isProvider := a.(CORSProvider); isProvider {
+			if pc, allowed := p.CORS(origin); allowed {
+				cors, ok = cors.Merge(pc), true
+			}
+		}
+	}
+	return cors, ok
+}
+
+// Middleware returns a middleware asserting the token in the X-Auth-Token
+// header, a Bearer Authorization header or the token queryparam if
+// allowQuery, belongs to a Key granted the passed scope. The Key is
+// carried by the context of the request
+func Middleware(a Authenticator, scope string, allowQuery bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := c.Request().Header.Get(HeaderAuthToken)
+			if token == "" {
+				token = BearerToken(c.Request().Header.Get(echo.HeaderAuthorization))
+			}
+			if token == "" && allowQuery {
+				token = c.QueryParam("token")
+			}
+			key, err := Authorize(a, token, scope)
+			if err != nil {
+				return err
+			}
+			if key != nil {
+				req := c.Request()
+				c.SetRequest(req.WithContext(WithKey(req.Context(), key)))
+			}
+			return next(c)
+		}
+	}
+}
+
+// BearerToken returns the token of a Bearer Authorization header, or an
+// empty string for any other header
+func BearerToken(authorization string) string {
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+	return ""
+}
+
+// Authorize authenticates the passed token and asserts its Key is granted
+// the passed scope, returning a 401 or 403 echo.HTTPError if not. A nil
+// Key is returned for anonymous requests
+func Authorize(a Authenticator, token, scope string) (*Key, error) {
+	key, err := a.Authenticate(token)
+	if err != nil {
+		if token == "" {
+			return nil, echo.ErrUnauthorized
+		}
+		switch err {
+		case ErrInvalidKey, ErrExpiredKey, ErrDisabledKey:
+			return nil, echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		default:
+			return nil, err
+		}
+	}
+	if key != nil && !key.HasScope(scope) {
+		return nil, echo.NewHTTPError(http.StatusForbidden,
+			fmt.Sprintf("API key lacks the %s scope", scope))
+	}
+	return key, nil
+}
@@ -0,0 +1,71 @@
+package keys
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+// authorized performs a request against a route requiring the passed scope,
+// returning the response status and the name of the key it was attributed to
+func authorized(a Authenticator, scope, token string) (int, string) {
+	e := echo.New()
+	var name string
+	e.GET("/", func(c echo.Context) error {
+		if key := FromContext(c.Request().Context()); key != nil {
+			name = key.Name
+		}
+		return c.NoContent(http.StatusOK)
+	}, Middleware(a, scope, false))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		req.Header.Set(HeaderAuthToken, token)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code, name
+}
+
+func TestMiddlewareAnonymous(t *testing.T) {
+	code, name := authorized(Chain(Static(""), testStore(t)), ScopeBatch, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, name)
+}
+
+func TestMiddlewareScopes(t *testing.T) {
+	s := testStore(t)
+	_, lookup, err := s.Create("lookups", []string{ScopeLookup}, time.Time{})
+	assert.Nil(t, err)
+	_, admin, err := s.Create("admins", []string{ScopeAdmin}, time.Time{})
+	assert.Nil(t, err)
+	a := Chain(Static("static-token"), s)
+
+	for _, tc := range []struct {
+		token, scope string
+		code         int
+		name         string
+	}{
+		{lookup, ScopeLookup, http.StatusOK, "lookups"},
+		{lookup, ScopeBatch, http.StatusForbidden, ""},
+		{lookup, ScopeAny, http.StatusOK, "lookups"},
+		{admin, ScopeBatch, http.StatusOK, "admins"},
+		{"static-token", ScopeAdmin, http.StatusOK, "static"},
+		{"", ScopeLookup, http.StatusUnauthorized, ""},
+		{"wrong", ScopeLookup, http.StatusUnauthorized, ""},
+	} {
+		code, name := authorized(a, tc.scope, tc.token)
+		assert.Equal(t, tc.code, code, tc.token)
+		assert.Equal(t, tc.name, name, tc.token)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	ctx := Track(httptest.NewRequest(http.MethodGet, "/", nil).Context())
+	WithKey(ctx, &Key{Name: "tracked"})
+	assert.Equal(t, "tracked", FromContext(ctx).Name)
+}
@@ -0,0 +1,236 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwtPrefix prefixes the IDs of the Keys authenticated from JWTs
+const jwtPrefix = "jwt:"
+
+// ErrNoJWTKeys is thrown when a JWT Authenticator has neither a secret nor
+// a JWKS file to verify tokens with
+var ErrNoJWTKeys = errors.New("JWT authentication requires a secret or JWKS file")
+
+// JWTConfig configures the verification of JWT bearer tokens
+type JWTConfig struct {
+	Secret     string // Verifies HS256 tokens
+	JWKSFile   string // Holds the public keys verifying RS256 and ES256 tokens
+	Audience   string // Required in the aud claim unless empty
+	Issuer     string // Required as the iss claim unless empty
+	ScopeClaim string // Holds the scopes granted, space separated or as an array
+	QuotaClaim string // Holds the daily quota or an object of daily and monthly quotas
+}
+
+// JWT is an Authenticator accepting JWTs signed by an identity provider.
+// Each token authenticates to a Key named by its subject, granted the
+// scopes and quota held by the configured claims
+type JWT struct {
+	config JWTConfig
+	rsa    map[string]*rsa.PublicKey   // The RSA keys of the JWKS by ID
+	ecdsa  map[string]*ecdsa.PublicKey // The ECDSA keys of the JWKS by ID
+}
+
+// NewJWT generates a new JWT Authenticator, loading the public keys of the
+// configured JWKS file
+func NewJWT(config JWTConfig) (*JWT, error) {
+	if config.Secret == "" && config.JWKSFile == "" {
+		return nil, ErrNoJWTKeys
+	}
+	j := &JWT{config: config, rsa: make(map[string]*rsa.PublicKey),
+		ecdsa: make(map[string]*ecdsa.PublicKey)}
+	if config.JWKSFile != "" {
+		if err := j.loadJWKS(config.JWKSFile); err != nil {
+			return nil, fmt.Errorf("failed to load JWKS: %v", err)
+		}
+	}
+	return j, nil
+}
+
+// Authenticate verifies the signature and registered claims of a JWT and
+// returns the Key it authenticates to. Tokens must expire and be issued
+// for this service
+func (j *JWT) Authenticate(token string) (*Key, error) {
+	opts := []jwt.ParserOption{jwt.WithExpirationRequired()}
+	if j.config.Audience != "" {
+		opts = append(opts, jwt.WithAudience(j.config.Audience))
+	}
+	if j.config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(j.config.Issuer))
+	}
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, j.keyFunc, opts...); err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredKey
+		}
+		return nil, ErrInvalidKey
+	}
+
+	// Tokens without a subject can't be told apart
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, ErrInvalidKey
+	}
+	key := &Key{ID: jwtPrefix + subject, Name: subject, Enabled: true,
+		Scopes: scopesClaim(claims[j.config.ScopeClaim]),
+		Quota:  quotaClaim(claims[j.config.QuotaClaim])}
+	if exp, _ := claims.GetExpirationTime(); exp != nil {
+		key.Expires = exp.Time.UTC()
+	}
+	return key, nil
+}
+
+// keyFunc returns the key verifying a token, only accepting the algorithm
+// each key is intended for
+func (j *JWT) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	switch t.Method {
+	case jwt.SigningMethodHS256:
+		if j.config.Secret != "" {
+			return []byte(j.config.Secret), nil
+		}
+	case jwt.SigningMethodRS256:
+		if key := findKey(j.rsa, kid); key != nil {
+			return key, nil
+		}
+	case jwt.SigningMethodES256:
+		if key := findKey(j.ecdsa, kid); key != nil && key.Curve == elliptic.P256() {
+			return key, nil
+		}
+	}
+	return nil, ErrInvalidKey
+}
+
+// findKey returns the key with the passed ID, or the only key if the token
+// doesn't name one
+func findKey[K any](keys map[string]K, kid string) K {
+	if key, ok := keys[kid]; ok || kid != "" {
+		return key
+	}
+	var only K
+	if len(keys) == 1 {
+		for _, key := range keys {
+			only = key
+		}
+	}
+	return only
+}
+
+// jwk is a JSON Web Key holding an RSA or EC public key
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// loadJWKS loads the signing keys of a JWKS file
+func (j *JWT) loadJWKS(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return err
+	}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, err := decodeInt(k.N)
+			if err != nil {
+				return err
+			}
+			e, err := decodeInt(k.E)
+			if err != nil {
+				return err
+			}
+			j.rsa[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			if k.Crv != "P-256" {
+				continue // Only ES256 is supported
+			}
+			x, err := decodeInt(k.X)
+			if err != nil {
+				return err
+			}
+			y, err := decodeInt(k.Y)
+			if err != nil {
+				return err
+			}
+			if !elliptic.P256().IsOnCurve(x, y) {
+				return fmt.Errorf("key %q is not on P-256", k.Kid)
+			}
+			j.ecdsa[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		}
+	}
+	if len(j.rsa)+len(j.ecdsa) == 0 {
+		return errors.New("no RSA or P-256 signing keys")
+	}
+	return nil
+}
+
+// decodeInt decodes a base64url encoded big-endian integer
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// scopesClaim returns the known scopes held by a space separated string or
+// array claim, ignoring any others
+func scopesClaim(claim interface{}) []string {
+	var values []string
+	switch c := claim.(type) {
+	case string:
+		values = strings.Fields(c)
+	case []interface{}:
+		for _, v := range c {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	scopes := []string{}
+	for _, v := range values {
+		if CheckScopes([]string{v}) == nil {
+			scopes = append(scopes, v)
+		}
+	}
+	return scopes
+}
+
+// quotaClaim returns the Quota held by a claim, either a number of daily
+// requests or an object of daily and monthly requests
+func quotaClaim(claim interface{}) Quota {
+	switch c := claim.(type) {
+	case float64:
+		return Quota{Daily: int(c)}
+	case map[string]interface{}:
+		daily, _ := c["daily"].(float64)
+		monthly, _ := c["monthly"].(float64)
+		return Quota{Daily: int(daily), Monthly: int(monthly)}
+	}
+	return Quota{}
+}
@@ -0,0 +1,144 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// sign signs the passed claims, expiring in an hour unless set
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	assert.Nil(t, err)
+	return token
+}
+
+// writeJWKS writes a JWKS file holding the passed public keys
+func writeJWKS(t *testing.T, rsaKey *rsa.PublicKey, ecKey *ecdsa.PublicKey) string {
+	enc := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	set := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": enc(rsaKey.N),
+			"e": enc(big.NewInt(int64(rsaKey.E)))},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": enc(ecKey.X), "y": enc(ecKey.Y)},
+	}}
+	b, err := json.Marshal(set)
+	assert.Nil(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.Nil(t, os.WriteFile(path, b, 0600))
+	return path
+}
+
+func TestJWTSecret(t *testing.T) {
+	j, err := NewJWT(JWTConfig{Secret: "secret", Audience: "trumail", Issuer: "idp",
+		ScopeClaim: "scope", QuotaClaim: "quota"})
+	assert.Nil(t, err)
+
+	key, err := j.Authenticate(sign(t, jwt.SigningMethodHS256, []byte("secret"), jwt.MapClaims{
+		"sub": "reporting", "aud": []interface{}{"other", "trumail"}, "iss": "idp",
+		"scope": "openid lookup batch", "quota": map[string]interface{}{"daily": 100, "monthly": 2000},
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, "reporting", key.Name)
+	assert.True(t, key.JWT())
+	assert.Equal(t, []string{ScopeLookup, ScopeBatch}, key.Scopes)
+	assert.Equal(t, Quota{Daily: 100, Monthly: 2000}, key.Quota)
+
+	for name, claims := range map[string]jwt.MapClaims{
+		"wrong audience": {"aud": "other", "iss": "idp"},
+		"wrong issuer":   {"aud": "trumail", "iss": "other"},
+		"no audience":    {"sub": "reporting", "iss": "idp"},
+		"no subject":     {"aud": "trumail", "iss": "idp"},
+		"empty subject":  {"sub": "", "aud": "trumail", "iss": "idp"},
+	} {
+		_, err = j.Authenticate(sign(t, jwt.SigningMethodHS256, []byte("secret"), claims))
+		assert.Equal(t, ErrInvalidKey, err, name)
+	}
+	_, err = j.Authenticate(sign(t, jwt.SigningMethodHS256, []byte("wrong"),
+		jwt.MapClaims{"aud": "trumail", "iss": "idp"}))
+	assert.Equal(t, ErrInvalidKey, err)
+	_, err = j.Authenticate(sign(t, jwt.SigningMethodHS256, []byte("secret"),
+		jwt.MapClaims{"aud": "trumail", "iss": "idp", "exp": time.Now().Add(-time.Minute).Unix()}))
+	assert.Equal(t, ErrExpiredKey, err)
+	_, err = j.Authenticate("not-a-jwt")
+	assert.Equal(t, ErrInvalidKey, err)
+}
+
+func TestJWTRequiresExpiry(t *testing.T) {
+	j, err := NewJWT(JWTConfig{Secret: "secret"})
+	assert.Nil(t, err)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "a"}).
+		SignedString([]byte("secret"))
+	assert.Nil(t, err)
+	_, err = j.Authenticate(token)
+	assert.Equal(t, ErrInvalidKey, err)
+}
+
+func TestJWTJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	j, err := NewJWT(JWTConfig{JWKSFile: writeJWKS(t, &rsaKey.PublicKey, &ecKey.PublicKey),
+		ScopeClaim: "scp", QuotaClaim: "quota"})
+	assert.Nil(t, err)
+
+	// RS256 tokens are verified by the RSA key
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "rsa",
+		"scp": []interface{}{"admin"}, "quota": 50, "exp": time.Now().Add(time.Hour).Unix()})
+	token.Header["kid"] = "rsa"
+	signed, err := token.SignedString(rsaKey)
+	assert.Nil(t, err)
+	key, err := j.Authenticate(signed)
+	assert.Nil(t, err)
+	assert.True(t, key.HasScope(ScopeBatch))
+	assert.Equal(t, Quota{Daily: 50}, key.Quota)
+
+	// ES256 tokens are verified by the EC key
+	token = jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"sub": "ec",
+		"exp": time.Now().Add(time.Hour).Unix()})
+	token.Header["kid"] = "ec"
+	signed, err = token.SignedString(ecKey)
+	assert.Nil(t, err)
+	key, err = j.Authenticate(signed)
+	assert.Nil(t, err)
+	assert.Equal(t, "ec", key.Name)
+	assert.Empty(t, key.Scopes)
+
+	// Tokens naming an unknown key or algorithm are rejected
+	token = jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix()})
+	token.Header["kid"] = "missing"
+	signed, err = token.SignedString(rsaKey)
+	assert.Nil(t, err)
+	_, err = j.Authenticate(signed)
+	assert.Equal(t, ErrInvalidKey, err)
+	_, err = j.Authenticate(sign(t, jwt.SigningMethodHS256, []byte("anything"), jwt.MapClaims{}))
+	assert.Equal(t, ErrInvalidKey, err)
+}
+
+func TestNewJWTErrors(t *testing.T) {
+	_, err := NewJWT(JWTConfig{})
+	assert.Equal(t, ErrNoJWTKeys, err)
+	_, err = NewJWT(JWTConfig{JWKSFile: filepath.Join(t.TempDir(), "missing.json")})
+	assert.NotNil(t, err)
+}
+
+func TestBearerToken(t *testing.T) {
+	assert.Equal(t, "abc", BearerToken("Bearer abc"))
+	assert.Equal(t, "abc", BearerToken("bearer abc"))
+	assert.Empty(t, BearerToken("Basic abc"))
+	assert.Empty(t, BearerToken("Bearer "))
+}
@@ -0,0 +1,244 @@
+// Package keys authenticates API requests with named and scoped keys whose
+// secrets are stored hashed in a local database
+package keys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// ScopeLookup allows verifying single addresses
+	ScopeLookup = "lookup"
+	// ScopeBatch allows verifying lists of addresses as batches and jobs
+	ScopeBatch = "batch"
+	// ScopeAdmin allows administering the service, along with every other
+	// scope
+	ScopeAdmin = "admin"
+	// ScopeAny is required of routes that any key may use
+	ScopeAny = ""
+)
+
+const (
+	// secretPrefix prefixes every secret, making leaked keys easy to search for
+	secretPrefix = "tm_"
+	// webhookSecretPrefix prefixes every webhook secret
+	webhookSecretPrefix = "whsec_"
+)
+
+// Scopes are every scope a Key may be granted
+var Scopes = []string{ScopeLookup, ScopeBatch, ScopeAdmin}
+
+// ErrInvalidScope is thrown when a Key is granted an unknown scope
+var ErrInvalidScope = errors.New("Invalid scope, must be one of lookup, batch or admin")
+
+// ErrInvalidOrigin is thrown when a Key is allowed an origin that isn't *
+// or a scheme and host
+var ErrInvalidOrigin = errors.New("Invalid origin, must be * or like https://example.com")
+
+// Key is an API key identifying the consumer of the API
+type Key struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Hash    string    `json:"hash"` // The hex encoded SHA-256 of the secret
+	Scopes  []string  `json:"scopes"`
+	Enabled bool      `json:"enabled"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"` // The key never expires if zero
+	Quota   Quota     `json:"quota"`
+	CORS    CORS      `json:"cors"`
+
+	// WebhookSecret signs the callbacks of jobs created with the Key. It's
+	// stored as is since signing requires it
+	WebhookSecret string `json:"webhookSecret,omitempty"`
+}
+
+// Quota limits the requests a Key may make. Zero limits fall back to the
+// defaults of the server
+type Quota struct {
+	Daily   int `json:"daily,omitempty"`
+	Monthly int `json:"monthly,omitempty"`
+}
+
+// CORS allows browsers on its origins to call the API with its methods
+// and headers. An origin of * allows every origin
+type CORS struct {
+	Origins []string `json:"origins,omitempty"`
+	Methods []string `json:"methods,omitempty"`
+	Headers []string `json:"headers,omitempty"`
+}
+
+// AllowsOrigin reports whether the CORS allows the passed origin
+func (c CORS) AllowsOrigin(origin string) bool {
+	for _, o := range c.Origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidOrigin reports whether the passed origin is * or a scheme and host
+// without a path
+func ValidOrigin(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Scheme != "" && u.Host != "" && strings.TrimSuffix(u.Path, "/") == ""
+}
+
+// Merge returns the CORS allowing everything either CORS allows
+func (c CORS) Merge(o CORS) CORS {
+	return CORS{
+		Origins: union(c.Origins, o.Origins),
+		Methods: union(c.Methods, o.Methods),
+		Headers: union(c.Headers, o.Headers),
+	}
+}
+
+// union returns the case-insensitively distinct values of both slices in
+// order
+func union(a, b []string) []string {
+	var values []string
+	seen := make(map[string]bool)
+	for _, v := range append(append([]string(nil), a...), b...) {
+		if !seen[strings.ToLower(v)] {
+			seen[strings.ToLower(v)] = true
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// HasScope reports whether the Key is granted the passed scope. Admin keys
+// are granted every scope
+func (k *Key) HasScope(scope string) bool {
+	if scope == ScopeAny {
+		return true
+	}
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// JWT reports whether the Key was authenticated from a JWT rather than
+// stored in a Store or static
+func (k *Key) JWT() bool {
+	return strings.HasPrefix(k.ID, jwtPrefix)
+}
+
+// Expired reports whether the Key had expired at the passed time
+func (k *Key) Expired(now time.Time) bool {
+	return !k.Expires.IsZero() && !now.Before(k.Expires)
+}
+
+// check asserts the Key may currently be used
+func (k *Key) check(now time.Time) error {
+	if !k.Enabled {
+		return ErrDisabledKey
+	}
+	if k.Expired(now) {
+		return ErrExpiredKey
+	}
+	return nil
+}
+
+// CheckScopes asserts every passed scope is known
+func CheckScopes(scopes []string) error {
+	for _, scope := range scopes {
+		switch scope {
+		case ScopeLookup, ScopeBatch, ScopeAdmin:
+		default:
+			return ErrInvalidScope
+		}
+	}
+	return nil
+}
+
+// newSecret generates a new secret for the Key with the passed ID. The ID
+// is embedded in the secret so its Key can be found without a scan
+func newSecret(id string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return secretPrefix + id + "_" + hex.EncodeToString(b), nil
+}
+
+// newWebhookSecret generates a new webhook secret
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + hex.EncodeToString(b), nil
+}
+
+// parseSecret returns the ID of the Key embedded in a secret
+func parseSecret(secret string) (string, bool) {
+	if !strings.HasPrefix(secret, secretPrefix) {
+		return "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(secret, secretPrefix), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// hash returns the hex encoded SHA-256 of a secret. Secrets are random
+// enough that a slow hash adds nothing
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// newID generates a random Key ID
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// principalKey is the context key holding the principal of a request
+type principalKey struct{}
+
+// principal records the Key a request was authenticated with
+type principal struct{ key *Key }
+
+// Track returns a copy of the passed context that records the Key the
+// request is later authenticated with, letting middleware that wraps
+// authentication attribute the request to its Key
+func Track(ctx context.Context) context.Context {
+	return context.WithValue(ctx, principalKey{}, &principal{})
+}
+
+// WithKey returns a copy of the passed context carrying the passed Key,
+// also recording it on the context if tracked
+func WithKey(ctx context.Context, key *Key) context.Context {
+	if p, ok := ctx.Value(principalKey{}).(*principal); ok {
+		p.key = key
+		return ctx
+	}
+	return context.WithValue(ctx, principalKey{}, &principal{key})
+}
+
+// FromContext returns the Key carried by the passed context, if any
+func FromContext(ctx context.Context) *Key {
+	if p, ok := ctx.Value(principalKey{}).(*principal); ok {
+		return p.key
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+package keys
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// reloadInterval is the longest a Store authenticates against its cached
+// keys before checking the database for changes
+const reloadInterval = time.Second
+
+var (
+	// ErrNotFound is thrown when a key doesn't exist
+	ErrNotFound = errors.New("API key not found")
+	// ErrInvalidKey is thrown when a token isn't the secret of any key
+	ErrInvalidKey = errors.New("Invalid API key")
+	// ErrExpiredKey is thrown when authenticating with an expired key
+	ErrExpiredKey = errors.New("Expired API key")
+	// ErrDisabledKey is thrown when authenticating with a revoked key
+	ErrDisabledKey = errors.New("Revoked API key")
+	// ErrNoKeys is thrown by an Authenticator without any keys to
+	// authenticate against
+	ErrNoKeys = errors.New("No API keys")
+
+	// keysBucket holds every Key by ID
+	keysBucket = []byte("keys")
+)
+
+// Store persists API keys in a local bolt database. The database is only
+// held open while being read or written so keys can be managed by another
+// process while the server runs. Keys are authenticated against a cache
+// that is reloaded whenever the database changes
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	keys    map[string]*Key // The cached keys by ID
+	modTime time.Time       // The modification time of the cached database
+	checked time.Time       // When the database was last checked for changes
+}
+
+// Open opens, creating if needed, the Store at the passed path
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+	if err := s.update(func(*bolt.Bucket) error { return nil }); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Create creates a Key with the passed name and scopes, expiring at the
+// passed time unless zero, and returns it along with its secret. The
+// secret is not stored and can't be retrieved again. The Key is given a
+// webhook secret of its own
+func (s *Store) Create(name string, scopes []string, expires time.Time) (*Key, string, error) {
+	if err := CheckScopes(scopes); err != nil {
+		return nil, "", err
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := newSecret(id)
+	if err != nil {
+		return nil, "", err
+	}
+	webhookSecret, err := newWebhookSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	key := &Key{ID: id, Name: name, Hash: hash(secret), Scopes: scopes, Enabled: true,
+		Created: time.Now().UTC(), Expires: expires, WebhookSecret: webhookSecret}
+	if err := s.update(func(b *bolt.Bucket) error { return put(b, key) }); err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// Keys returns every Key in the order they were created
+func (s *Store) Keys() ([]*Key, error) {
+	var keys []*Key
+	err := s.view(func(b *bolt.Bucket) error {
+		return b.ForEach(func(_, v []byte) error {
+			var key Key
+			if err := json.Unmarshal(v, &key); err != nil {
+				return err
+			}
+			keys = append(keys, &key)
+			return nil
+		})
+	})
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Created.Before(keys[j].Created) })
+	return keys, err
+}
+
+// Key retrieves the Key with the passed ID
+func (s *Store) Key(id string) (*Key, error) {
+	var key *Key
+	err := s.view(func(b *bolt.Bucket) error {
+		var err error
+		key, err = get(b, id)
+		return err
+	})
+	return key, err
+}
+
+// Revoke disables the Key with the passed ID
+func (s *Store) Revoke(id string) (*Key, error) {
+	var key *Key
+	err := s.update(func(b *bolt.Bucket) error {
+		var err error
+		if key, err = get(b, id); err != nil {
+			return err
+		}
+		key.Enabled = false
+		return put(b, key)
+	})
+	return key, err
+}
+
+// SetQuota replaces the Quota of the Key with the passed ID
+func (s *Store) SetQuota(id string, quota Quota) (*Key, error) {
+	var key *Key
+	err := s.update(func(b *bolt.Bucket) error {
+		var err error
+		if key, err = get(b, id); err != nil {
+			return err
+		}
+		key.Quota = quota
+		return put(b, key)
+	})
+	return key, err
+}
+
+// SetCORS replaces the CORS of the Key with the passed ID
+func (s *Store) SetCORS(id string, cors CORS) (*Key, error) {
+	for _, origin := range cors.Origins {
+		if !ValidOrigin(origin) {
+			return nil, ErrInvalidOrigin
+		}
+	}
+	var key *Key
+	err := s.update(func(b *bolt.Bucket) error {
+		var err error
+		if key, err = get(b, id); err != nil {
+			return err
+		}
+		key.CORS = cors
+		return put(b, key)
+	})
+	return key, err
+}
+
+// CORS returns the merged CORS of every usable Key allowing the passed
+// origin, reporting whether any does
+func (s *Store) CORS(origin string) (CORS, bool) {
+	keys, err := s.cached()
+	if err != nil {
+		return CORS{}, false
+	}
+	ids := make([]string, 0, len(keys))
+	for id := range keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids) // Merge in a stable order
+
+	var cors CORS
+	var ok bool
+	now := time.Now()
+	for _, id := range ids {
+		if key := keys[id]; key.check(now) == nil && key.CORS.AllowsOrigin(origin) {
+			cors, ok = cors.Merge(key.CORS), true
+		}
+	}
+	return cors, ok
+}
+
+// Rotate replaces the secret of the Key with the passed ID, returning the
+// Key along with its new secret. The previous secret stops working
+// immediately
+func (s *Store) Rotate(id string) (*Key, string, error) {
+	secret, err := newSecret(id)
+	if err != nil {
+		return nil, "", err
+	}
+	var key *Key
+	err = s.update(func(b *bolt.Bucket) error {
+		var err error
+		if key, err = get(b, id); err != nil {
+			return err
+		}
+		key.Hash = hash(secret)
+		return put(b, key)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// RotateWebhookSecret replaces the webhook secret of the Key with the
+// passed ID, returning the Key along with its new webhook secret. Jobs
+// created beforehand keep signing with the previous secret
+func (s *Store) RotateWebhookSecret(id string) (*Key, string, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	var key *Key
+	err = s.update(func(b *bolt.Bucket) error {
+		var err error
+		if key, err = get(b, id); err != nil {
+			return err
+		}
+		key.WebhookSecret = secret
+		return put(b, key)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// Authenticate returns the Key the passed token is the secret of, failing
+// with ErrNoKeys if the Store is empty
+func (s *Store) Authenticate(token string) (*Key, error) {
+	keys, err := s.cached()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	id, ok := parseSecret(token)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	key, ok := keys[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash(token))) != 1 {
+		return nil, ErrInvalidKey
+	}
+	if err := key.check(time.Now()); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// cached returns the cached keys, reloading them if the database has
+// changed since they were loaded
+func (s *Store) cached() (map[string]*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys != nil && time.Since(s.checked) < reloadInterval {
+		return s.keys, nil
+	}
+	s.checked = time.Now()
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+	if s.keys != nil && info.ModTime().Equal(s.modTime) {
+		return s.keys, nil
+	}
+	list, err := s.Keys()
+	if err != nil {
+		return nil, err
+	}
+	s.keys = make(map[string]*Key, len(list))
+	for _, key := range list {
+		s.keys[key.ID] = key
+	}
+	s.modTime = info.ModTime()
+	return s.keys, nil
+}
+
+// Ping checks the database can be opened and read
+func (s *Store) Ping() error {
+	return s.view(func(*bolt.Bucket) error { return nil })
+}
+
+// view opens the database read only for the duration of fn
+func (s *Store) view(fn func(*bolt.Bucket) error) error {
+	db, err := bolt.Open(s.path, 0600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error { return fn(tx.Bucket(keysBucket)) })
+}
+
+// update opens the database for the duration of fn, creating the keys
+// bucket if needed, and invalidates the cached keys
+func (s *Store) update(fn func(*bolt.Bucket) error) error {
+	db, err := bolt.Open(s.path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer s.invalidate()
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(keysBucket)
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+}
+
+// invalidate drops the cached keys so they're reloaded when next used
+func (s *Store) invalidate() {
+	s.mu.Lock()
+	s.keys = nil
+	s.mu.Unlock()
+}
+
+// get reads the Key with the passed ID from the bucket
+func get(b *bolt.Bucket, id string) (*Key, error) {
+	v := b.Get([]byte(id))
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	var key Key
+	return &key, json.Unmarshal(v, &key)
+}
+
+// put writes the Key to the bucket
+func put(b *bolt.Bucket, key *Key) error {
+	v, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key.ID), v)
+}
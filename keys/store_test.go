@@ -0,0 +1,157 @@
+package keys
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testStore opens a Store in a temporary directory
+func testStore(t *testing.T) *Store {
+	s, err := Open(filepath.Join(t.TempDir(), "keys.db"))
+	assert.Nil(t, err)
+	return s
+}
+
+func TestStoreAuthenticate(t *testing.T) {
+	s := testStore(t)
+	assert.Nil(t, s.Ping())
+	_, err := s.Authenticate("anything")
+	assert.Equal(t, ErrNoKeys, err)
+
+	key, secret, err := s.Create("reporting", []string{ScopeLookup}, time.Time{})
+	assert.Nil(t, err)
+	assert.NotContains(t, key.Hash, secret)
+
+	authed, err := s.Authenticate(secret)
+	assert.Nil(t, err)
+	assert.Equal(t, key.ID, authed.ID)
+	assert.Equal(t, "reporting", authed.Name)
+	assert.True(t, authed.HasScope(ScopeLookup))
+	assert.False(t, authed.HasScope(ScopeBatch))
+
+	for _, token := range []string{"", "secret", "tm_" + key.ID + "_wrong", secret + "0"} {
+		_, err = s.Authenticate(token)
+		assert.Equal(t, ErrInvalidKey, err, token)
+	}
+}
+
+func TestStoreRevokeRotate(t *testing.T) {
+	s := testStore(t)
+	key, secret, err := s.Create("billing", []string{ScopeBatch}, time.Time{})
+	assert.Nil(t, err)
+
+	// Rotating replaces the secret
+	rotated, newSecret, err := s.Rotate(key.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, key.ID, rotated.ID)
+	_, err = s.Authenticate(secret)
+	assert.Equal(t, ErrInvalidKey, err)
+	_, err = s.Authenticate(newSecret)
+	assert.Nil(t, err)
+
+	// Rotating the webhook secret keeps the API secret
+	assert.True(t, strings.HasPrefix(key.WebhookSecret, "whsec_"))
+	_, webhookSecret, err := s.RotateWebhookSecret(key.ID)
+	assert.Nil(t, err)
+	assert.NotEqual(t, key.WebhookSecret, webhookSecret)
+	authed, err := s.Authenticate(newSecret)
+	assert.Nil(t, err)
+	assert.Equal(t, webhookSecret, authed.WebhookSecret)
+	_, _, err = s.RotateWebhookSecret("missing")
+	assert.Equal(t, ErrNotFound, err)
+
+	// Revoking disables the key
+	_, err = s.Revoke(key.ID)
+	assert.Nil(t, err)
+	_, err = s.Authenticate(newSecret)
+	assert.Equal(t, ErrDisabledKey, err)
+
+	_, err = s.Revoke("missing")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestStoreSetQuota(t *testing.T) {
+	s := testStore(t)
+	key, secret, err := s.Create("metered", []string{ScopeLookup}, time.Time{})
+	assert.Nil(t, err)
+	_, err = s.SetQuota(key.ID, Quota{Daily: 10, Monthly: 100})
+	assert.Nil(t, err)
+	authed, err := s.Authenticate(secret)
+	assert.Nil(t, err)
+	assert.Equal(t, Quota{Daily: 10, Monthly: 100}, authed.Quota)
+
+	_, err = s.SetQuota("missing", Quota{})
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestStoreCORS(t *testing.T) {
+	s := testStore(t)
+	app, _, err := s.Create("app", []string{ScopeLookup}, time.Time{})
+	assert.Nil(t, err)
+	admin, _, err := s.Create("admin", []string{ScopeAdmin}, time.Time{})
+	assert.Nil(t, err)
+	_, err = s.SetCORS(app.ID, CORS{Origins: []string{"https://app.example.com"},
+		Methods: []string{"GET"}})
+	assert.Nil(t, err)
+	_, err = s.SetCORS(admin.ID, CORS{Origins: []string{"*"}, Methods: []string{"get", "DELETE"}})
+	assert.Nil(t, err)
+
+	// Every usable key allowing the origin is merged
+	cors, ok := s.CORS("https://APP.example.com")
+	assert.True(t, ok)
+	assert.ElementsMatch(t, []string{"https://app.example.com", "*"}, cors.Origins)
+	assert.Len(t, cors.Methods, 2)
+	assert.Contains(t, cors.Methods, "DELETE")
+
+	// Revoked keys allow nothing
+	_, err = s.Revoke(admin.ID)
+	assert.Nil(t, err)
+	_, ok = s.CORS("https://other.example.com")
+	assert.False(t, ok)
+
+	_, err = s.SetCORS(app.ID, CORS{Origins: []string{"app.example.com"}})
+	assert.Equal(t, ErrInvalidOrigin, err)
+	_, err = s.SetCORS("missing", CORS{})
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestStoreExpiry(t *testing.T) {
+	s := testStore(t)
+	_, secret, err := s.Create("expired", []string{ScopeLookup}, time.Now().Add(-time.Minute))
+	assert.Nil(t, err)
+	_, err = s.Authenticate(secret)
+	assert.Equal(t, ErrExpiredKey, err)
+}
+
+func TestStoreSharedDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.db")
+	server, err := Open(path)
+	assert.Nil(t, err)
+	cli, err := Open(path)
+	assert.Nil(t, err)
+
+	// Keys created by another process are picked up once reloaded
+	_, _, err = server.Create("first", []string{ScopeLookup}, time.Time{})
+	assert.Nil(t, err)
+	_, secret, err := cli.Create("second", []string{ScopeLookup}, time.Time{})
+	assert.Nil(t, err)
+	server.mu.Lock()
+	server.checked = time.Time{}
+	server.mu.Unlock()
+	_, err = server.Authenticate(secret)
+	assert.Nil(t, err)
+
+	list, err := server.Keys()
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, "first", list[0].Name)
+}
+
+func TestCheckScopes(t *testing.T) {
+	assert.Nil(t, CheckScopes([]string{ScopeLookup, ScopeBatch, ScopeAdmin}))
+	assert.Equal(t, ErrInvalidScope, CheckScopes([]string{"root"}))
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sdwolfe32/trumail/keys"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// metadataRequestID is the gRPC metadata key holding a calls request ID
+const metadataRequestID = "x-request-id"
+
+// UnaryInterceptor returns a gRPC interceptor that assigns every unary call
+// an ID and writes a structured access log entry once it has been handled.
+// Errors are redacted using the passed Redactor
+func UnaryInterceptor(logger *slog.Logger, r *Redactor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx = withCallID(ctx)
+		res, err := handler(ctx, req)
+		logCall(ctx, logger, r, info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+// StreamInterceptor returns a gRPC interceptor that assigns every streaming
+// call an ID and writes a structured access log entry once it has been
+// handled. Errors are redacted using the passed Redactor
+func StreamInterceptor(logger *slog.Logger, r *Redactor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := withCallID(ss.Context())
+		err := handler(srv, &serverStream{ss, ctx})
+		logCall(ctx, logger, r, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// withCallID reuses the callers request ID or generates a new one,
+// returning it in the response header and on the context along with the
+// API key the call is authenticated with
+func withCallID(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(metadataRequestID); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if id == "" || len(id) > maxRequestIDLen {
+		id = newRequestID()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(metadataRequestID, id))
+	return keys.Track(WithRequestID(ctx, id))
+}
+
+// logCall logs a call at a level matching its status code
+func logCall(ctx context.Context, logger *slog.Logger, r *Redactor, method string,
+	start time.Time, err error) {
+	st := status.Convert(err)
+	level := slog.LevelInfo
+	switch st.Code() {
+	case codes.OK:
+	case codes.Unknown, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss:
+		level = slog.LevelError
+	default:
+		level = slog.LevelWarn
+	}
+	var remoteIP, errStr string
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteIP = p.Addr.String()
+	}
+	if err != nil {
+		errStr = r.Text(st.Message())
+	}
+	logger.LogAttrs(ctx, level, "rpc",
+		slog.String("remote_ip", remoteIP),
+		slog.String("method", method),
+		slog.String("code", st.Code().String()),
+		slog.Float64("latency_ms", float64(time.Since(start))/float64(time.Millisecond)),
+		slog.String("error", errStr),
+	)
+}
+
+// serverStream is a grpc.ServerStream with a replaced context
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/sdwolfe32/trumail/keys"
+)
+
+// New generates a new structured logger writing JSON to the passed writer.
+// Entries logged with a context carrying a request ID or API key include
+// them. Passing a *slog.LevelVar allows the level to be changed later
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(&contextHandler{slog.NewJSONHandler(w,
+		&slog.HandlerOptions{Level: level})})
+}
+
+// ParseLevel parses one of debug, info, warn or error into a slog.Level.
+// An empty string is parsed as info
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if strings.TrimSpace(level) == "" {
+		return slog.LevelInfo, nil
+	}
+	err := l.UnmarshalText([]byte(level))
+	return l, err
+}
+
+// requestIDKey is the context key holding a requests ID
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of the passed context carrying the
+// passed request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by the passed context, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler is a slog.Handler that adds the request ID and API key
+// carried by the context to every record
+type contextHandler struct{ slog.Handler }
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if key := keys.FromContext(ctx); key != nil {
+		r.AddAttrs(slog.String("key_id", key.ID), slog.String("key_name", key.Name))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/keys"
+)
+
+// maxRequestIDLen is the longest request ID accepted from a client
+const maxRequestIDLen = 128
+
+// Middleware returns a middleware that assigns every request an ID and
+// writes a structured access log entry once it has been handled. The
+// request path is redacted using the passed Redactor
+func Middleware(logger *slog.Logger, r *Redactor) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+			res := c.Response()
+
+			// Reuse the callers request ID or generate a new one
+			id := req.Header.Get(echo.HeaderXRequestID)
+			if id == "" || len(id) > maxRequestIDLen {
+				id = newRequestID()
+			}
+			res.Header().Set(echo.HeaderXRequestID, id)
+			ctx := keys.Track(WithRequestID(req.Context(), id))
+			c.SetRequest(req.WithContext(ctx))
+
+			// Handle the request
+			var errStr string
+			if err := next(c); err != nil {
+				c.Error(err)
+				errStr = r.Text(err.Error())
+			}
+
+			// Log the request at a level matching the response status
+			level := slog.LevelInfo
+			switch {
+			case res.Status >= 500:
+				level = slog.LevelError
+			case res.Status >= 400:
+				level = slog.LevelWarn
+			}
+			logger.LogAttrs(ctx, level, "request",
+				slog.String("remote_ip", c.RealIP()),
+				slog.String("method", req.Method),
+				slog.String("route", c.Path()),
+				slog.String("path", r.Text(req.URL.Path)),
+				slog.Int("status", res.Status),
+				slog.Int64("bytes_out", res.Size),
+				slog.Float64("latency_ms", float64(time.Since(start))/float64(time.Millisecond)),
+				slog.String("error", errStr),
+			)
+			return nil
+		}
+	}
+}
+
+// newRequestID generates a new random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/sdwolfe32/trumail/verifier"
+)
+
+// Observer is a verifier.Observer that writes a debug log entry for every
+// lookup and every SMTP phase within it. Addresses are redacted using the
+// Observers Redactor
+type Observer struct {
+	logger   *slog.Logger
+	redactor *Redactor
+}
+
+// NewObserver generates a new Observer logging to the passed logger
+func NewObserver(logger *slog.Logger, r *Redactor) *Observer {
+	return &Observer{logger, r}
+}
+
+// ObserveLookup logs the outcome of a lookup
+func (o *Observer) ObserveLookup(ctx context.Context, l *verifier.Lookup, err error, took time.Duration) {
+	if !o.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	status, reason := verifier.Outcome(l, err)
+	attrs := []slog.Attr{
+		slog.String("address", o.redactor.Address(l.Address.Address)),
+		slog.String("status", status),
+		slog.String("reason", reason),
+		slog.Float64("took_ms", float64(took)/float64(time.Millisecond)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", o.redactor.Text(err.Error())))
+	}
+	o.logger.LogAttrs(ctx, slog.LevelDebug, "lookup", attrs...)
+}
+
+// ObservePhase logs the outcome of a single phase of a lookup
+func (o *Observer) ObservePhase(ctx context.Context, e verifier.PhaseEvent) {
+	if !o.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("phase", e.Phase),
+		slog.Float64("took_ms", float64(e.Took)/float64(time.Millisecond)),
+	}
+	if e.Address != "" {
+		attrs = append(attrs, slog.String("address", o.redactor.Address(e.Address)))
+	}
+	if e.Host != "" {
+		attrs = append(attrs, slog.String("host", e.Host))
+	}
+	if e.Err != nil {
+		attrs = append(attrs, slog.String("error", o.redactor.Text(e.Err.Error())))
+	}
+	o.logger.LogAttrs(ctx, slog.LevelDebug, "phase", attrs...)
+}
+
+// ObserveSession satisfies the verifier.Observer interface
+func (o *Observer) ObserveSession(int) {}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// RedactMask keeps the first character of the local part of an
+	// address and masks the rest, eg: "j***@example.com"
+	RedactMask = "mask"
+	// RedactHash replaces an address with the SHA-256 hash of its
+	// lower-cased form
+	RedactHash = "hash"
+	// RedactNone logs addresses in plain text
+	RedactNone = "none"
+)
+
+// ErrUnsupportedRedaction is thrown when an unknown redaction mode
+// is requested
+var ErrUnsupportedRedaction = errors.New("Unsupported redaction mode specified")
+
+// addressPattern matches anything resembling an email address, including
+// the URL escaped form found in request paths. Local parts are quoted
+// strings or any run of characters that can't delimit an address, so
+// internationalized addresses are matched whole
+var addressPattern = regexp.MustCompile(
+	`(?:"[^"\r\n]*"|[^\s<>,;:/"()\[\]]+)(?:@|%40)[\p{L}\p{N}](?:[\p{L}\p{N}.-]*[\p{L}\p{N}])?`)
+
+// Redactor removes email addresses from anything destined for the logs
+type Redactor struct{ mode string }
+
+// NewRedactor generates a new Redactor using the passed mode
+func NewRedactor(mode string) (*Redactor, error) {
+	switch mode = strings.ToLower(mode); mode {
+	case "":
+		return &Redactor{RedactMask}, nil
+	case RedactMask, RedactHash, RedactNone:
+		return &Redactor{mode}, nil
+	default:
+		return nil, ErrUnsupportedRedaction
+	}
+}
+
+// Address returns the passed address redacted per the Redactors mode
+func (r *Redactor) Address(address string) string {
+	switch r.mode {
+	case RedactNone:
+		return address
+	case RedactHash:
+		sum := sha256.Sum256([]byte(strings.ToLower(address)))
+		return hex.EncodeToString(sum[:])
+	default:
+		index := strings.LastIndex(address, "@")
+		if index < 1 {
+			return "***"
+		}
+		_, size := utf8.DecodeRuneInString(address)
+		return address[:size] + "***" + address[index:]
+	}
+}
+
+// Text returns the passed text with every address found within it
+// redacted. Mail server replies and request paths frequently echo the
+// address being verified
+func (r *Redactor) Text(text string) string {
+	if r.mode == RedactNone {
+		return text
+	}
+	return addressPattern.ReplaceAllStringFunc(text, func(match string) string {
+		return r.Address(strings.Replace(match, "%40", "@", 1))
+	})
+}
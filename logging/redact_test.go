@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactorMask(t *testing.T) {
+	r, err := NewRedactor("")
+	assert.Nil(t, err)
+	assert.Equal(t, "j***@example.com", r.Address("john.doe@example.com"))
+	assert.Equal(t, "***", r.Address("not-an-address"))
+	assert.Equal(t, "550 5.1.1 <j***@example.com>: Recipient address rejected",
+		r.Text("550 5.1.1 <john.doe@example.com>: Recipient address rejected"))
+	assert.Equal(t, "/v1/json/j***@example.com", r.Text("/v1/json/john.doe%40example.com"))
+
+	// Unicode and quoted local parts are redacted whole
+	assert.Equal(t, "ö***@exämple.com", r.Address("öle@exämple.com"))
+	assert.Equal(t, "rejected <j***@x.com>", r.Text("rejected <jöhn.smith@x.com>"))
+	assert.Equal(t, `rejected "***@x.com: no such user`, r.Text(`rejected "john smith"@x.com: no such user`))
+	assert.Equal(t, `{"email":"j***@x.com"}`, r.Text(`{"email":"jöhn@x.com"}`))
+}
+
+func TestRedactorHash(t *testing.T) {
+	r, err := NewRedactor(RedactHash)
+	assert.Nil(t, err)
+	assert.Equal(t, r.Address("John@Example.com"), r.Address("john@example.com"))
+	assert.NotContains(t, r.Text("rejected john@example.com"), "john")
+}
+
+func TestRedactorUnsupported(t *testing.T) {
+	_, err := NewRedactor("scramble")
+	assert.Equal(t, ErrUnsupportedRedaction, err)
+}
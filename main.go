@@ -1,82 +1,462 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
-	"github.com/entrik/httpclient"
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sdwolfe32/trumail/api"
+	"github.com/sdwolfe32/trumail/certs"
+	"github.com/sdwolfe32/trumail/config"
+	"github.com/sdwolfe32/trumail/cors"
+	"github.com/sdwolfe32/trumail/health"
+	"github.com/sdwolfe32/trumail/identity"
+	"github.com/sdwolfe32/trumail/jobs"
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/logging"
+	"github.com/sdwolfe32/trumail/metrics"
+	"github.com/sdwolfe32/trumail/ratelimit"
+	"github.com/sdwolfe32/trumail/tracing"
+	"github.com/sdwolfe32/trumail/usage"
 	"github.com/sdwolfe32/trumail/verifier"
-)
-
-var (
-	// port defines the port used by the api server
-	port = getEnv("PORT", "8080")
-	// sourceAddr defines the address used on verifier
-	sourceAddr = getEnv("SOURCE_ADDR", "admin@gmail.com")
+	"github.com/sdwolfe32/trumail/webhook"
+	"google.golang.org/grpc"
 )
 
 func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "trumail:", err)
+		os.Exit(1)
+	}
+}
+
+// serve runs the API servers with the passed Config until interrupted or
+// terminated, reloading the config with the passed flags on SIGHUP
+func serve(c *config.Config, args []string) {
+	current := new(atomic.Pointer[config.Config])
+	current.Store(c)
+
+	// Configure structured logging
+	level := new(slog.LevelVar)
+	l, _ := logging.ParseLevel(c.LogLevel) // Already validated
+	level.Set(l)
+	redactor, err := logging.NewRedactor(c.LogRedaction)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger := logging.New(os.Stdout, level)
+	slog.SetDefault(logger)
+
+	// Configure tracing
+	shutdownTracing, err := tracing.Setup(c.TraceExporter, "trumail")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Declare the router
 	e := echo.New()
-	e.Use(middleware.Logger())
+	e.HTTPErrorHandler = api.ErrorHandler
+	e.Use(logging.Middleware(logger, redactor))
 	e.Use(middleware.Recover())
+	e.Use(tracing.Middleware())
+	e.Use(webhookMiddleware(func() *config.Config { return current.Load() }))
 
-	// Define the API Services
-	v := verifier.NewVerifier(retrievePTR(), sourceAddr)
+	// Record the daily usage of each API key
+	usageStore, err := usage.Open(c.UsageDB)
+	if err != nil {
+		log.Fatal(err)
+	}
+	usageRecorder := usage.NewRecorder(usageStore, 10*time.Second)
+	defer usageRecorder.Close()
 
-	// Bind the API endpoints to router
-	e.GET("/v1/:format/:email", api.LookupHandler(v), authMiddleware)
-	e.GET("/v1/health", api.HealthHandler(), authMiddleware)
+	// Define the API Services, recording when mail servers were last
+	// reached for readiness checks
+	egress := health.NewEgress(c.HealthEgressWindow, c.HealthTestMX)
+	v := verifier.NewVerifier(heloHostname(c, logger), c.SourceAddr)
+	v.SetSessionRCPTs(c.BatchSessionRCPTs)
+	v.SetObserver(verifier.MultiObserver(
+		metrics.NewRecorder(prometheus.DefaultRegisterer),
+		logging.NewObserver(logger, redactor),
+		usageRecorder,
+		egress,
+	))
 
-	// Listen and Serve
-	e.Logger.Fatal(e.Start(":" + port))
-}
+	// Resume and run bulk jobs in the background
+	store, err := jobs.Open(c.JobsDB)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+	m := jobs.NewManager(store, v, logger, c.BatchWorkers)
+	webhooks := webhook.NewClient()
+	webhooks.AllowPrivate = c.WebhookAllowPrivate
+	m.SetWebhooks(webhooks, api.JobPayload)
+	if err := m.Start(c.JobsWorkers); err != nil {
+		log.Fatal(err)
+	}
 
-// RetrievePTR attempts to retrieve the PTR record for the IP
-// address retrieved via an API call on api.ipify.org
-func retrievePTR() string {
-	// Request the IP from ipify
-	ip, err := httpclient.GetString("https://api.ipify.org/")
+	// Authenticate requests with the static token, any stored API key or a
+	// bearer token signed by the identity provider
+	keyStore, err := keys.Open(c.KeysDB)
 	if err != nil {
-		log.Fatal("Failed to retrieve public IP")
+		log.Fatal(err)
+	}
+	authenticators := []keys.Authenticator{keys.Static(c.AuthToken), keyStore}
+	if c.JWTSecret != "" || c.JWTJWKS != "" {
+		j, err := keys.NewJWT(keys.JWTConfig{
+			Secret:     c.JWTSecret,
+			JWKSFile:   c.JWTJWKS,
+			Audience:   c.JWTAudience,
+			Issuer:     c.JWTIssuer,
+			ScopeClaim: c.JWTScopeClaim,
+			QuotaClaim: c.JWTQuotaClaim,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		authenticators = append(authenticators, j)
+	}
+	auth := keys.Chain(authenticators...)
+
+	// Allow browsers on the origins of the config or of API keys to call
+	// the API, only answering in JSONP if enabled
+	e.Use(cors.Middleware(func() cors.Config { return corsConfig(current.Load()) }, auth,
+		"/v1/", "/v2/"))
+	e.Use(jsonpMiddleware(func() bool { return current.Load().JSONP }))
+
+	// Limit the requests of each key and client IP, persisting quotas if
+	// configured
+	var counter ratelimit.Counter = ratelimit.NewMemory()
+	var rateStore *ratelimit.Bolt
+	if c.RateLimitDB != "" {
+		if rateStore, err = ratelimit.OpenBolt(c.RateLimitDB); err != nil {
+			log.Fatal(err)
+		}
+		defer rateStore.Close()
+		counter = rateStore
+	}
+	limiter := ratelimit.New(limits(c), counter)
+
+	// Check the dependencies needed to verify addresses in the background,
+	// reporting readiness once each has been checked
+	testHost, _, _ := net.SplitHostPort(c.HealthTestMX) // Already validated
+	mon := health.NewMonitor(c.HealthInterval, 10*time.Second)
+	mon.Add("dns", health.Resolver(net.DefaultResolver, testHost))
+	mon.Add("egress", egress.Check)
+	mon.Add("workers", health.Pool(m.Stats, c.HealthMaxQueuedJobs))
+	mon.Add("storage.jobs", health.Ping(store))
+	mon.Add("storage.keys", health.Ping(keyStore))
+	mon.Add("storage.usage", health.Ping(usageStore))
+	if rateStore != nil {
+		mon.Add("storage.ratelimit", health.Ping(rateStore))
+	}
+	mon.Start()
+	defer mon.Close()
+
+	// Bind the API endpoints to router
+	bindRoutes(e, c, v, m, usageStore, auth, limiter, mon)
+
+	// Reload the log level, limits and webhook secret on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			next, err := loadConfig(newFlagSet("serve", io.Discard), args)
+			if err != nil {
+				logger.Error("Failed to reload config", "error", err)
+				continue
+			}
+			reloaded, changed, restart := current.Load().Reload(next)
+			l, _ := logging.ParseLevel(reloaded.LogLevel)
+			level.Set(l)
+			limiter.SetConfig(limits(reloaded))
+			current.Store(reloaded)
+			logger.Info("Reloaded config", "changed", changed)
+			if len(restart) > 0 {
+				logger.Warn("Ignored settings that require a restart", "settings", restart)
+			}
+		}
+	}()
+
+	// Serve the gRPC API on its own port
+	var s *grpc.Server
+	if c.GRPCPort != "" {
+		lis, err := net.Listen("tcp", ":"+c.GRPCPort)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s = api.NewGRPCServer(v, auth, limiter, c.BatchLimit, c.BatchWorkers, mon,
+			grpc.ChainUnaryInterceptor(logging.UnaryInterceptor(logger, redactor)),
+			grpc.ChainStreamInterceptor(logging.StreamInterceptor(logger, redactor)))
+		go func() {
+			if err := s.Serve(lis); err != nil {
+				log.Fatal(err)
+			}
+		}()
 	}
 
-	// Retrieve the PTR record for our IP and return without a trailing dot
-	names, err := net.LookupAddr(ip)
+	// Listen and Serve until interrupted or terminated, redirecting HTTP to
+	// HTTPS on its own port when serving HTTPS
+	redirectHandler, err := configureTLS(e, c)
 	if err != nil {
-		return ip
+		log.Fatal(err)
+	}
+	errs := make(chan error, 2)
+	redirect := &http.Server{Addr: ":" + c.HTTPRedirectPort, Handler: redirectHandler}
+	if redirectHandler != nil && c.HTTPRedirectPort != "" {
+		go func() { errs <- redirect.ListenAndServe() }()
 	}
-	return strings.TrimSuffix(names[0], ".")
+	go func() { errs <- start(e, c) }()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		e.Logger.Fatal(err)
+	case sig := <-stop:
+		logger.Info("Shutting down", "signal", sig.String(), "timeout", c.ShutdownTimeout)
+	}
+	shutdown(c.ShutdownTimeout, logger, e, redirect, s, v, m)
 }
 
-// authMiddleware verifies the auth token on the request matches the
-// one defined in the environment
-func authMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
-	// authToken is the token that must be used on all requests
-	authToken := getEnv("AUTH_TOKEN", "")
-
-	// Return the Handlerfunc that asserts the auth token
-	return func(c echo.Context) error {
-		if authToken != "" {
-			if c.Request().Header.Get("X-Auth-Token") == authToken {
-				return next(c)
+// shutdown stops accepting requests, including on the HTTP to HTTPS
+// redirect server, and waits for the lookups in flight to complete until
+// the timeout, then aborts the remaining ones, ending their SMTP sessions
+// with QUIT. Requests are allowed shutdownGrace longer to respond. Running
+// jobs are stopped once their lookups in flight are done, saving the rows
+// verified so far, and resumed on restart
+func shutdown(timeout time.Duration, logger *slog.Logger, e *echo.Echo, redirect *http.Server,
+	s *grpc.Server, v *verifier.Verifier, m *jobs.Manager) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	graceCtx, cancelGrace := context.WithTimeout(context.Background(), timeout+shutdownGrace)
+	defer cancelGrace()
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		if err := e.Shutdown(graceCtx); err != nil {
+			logger.Warn("Closed HTTP requests that didn't complete", "error", err)
+			e.Close()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := redirect.Shutdown(graceCtx); err != nil {
+			redirect.Close()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		m.Close()
+	}()
+	if s != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopped := make(chan struct{})
+			go func() {
+				s.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-graceCtx.Done():
+				logger.Warn("Closed gRPC calls that didn't complete")
+				s.Stop()
 			}
-			return echo.ErrUnauthorized
+		}()
+	}
+
+	if err := v.Shutdown(ctx); err != nil {
+		logger.Warn("Aborted lookups that didn't complete", "timeout", timeout)
+	}
+	wg.Wait()
+	logger.Info("Shut down")
+}
+
+// shutdownGrace is how long requests are allowed to respond once their
+// lookups have been aborted on shutdown
+const shutdownGrace = 10 * time.Second
+
+// limits returns the limits enforced by the Config
+func limits(c *config.Config) ratelimit.Config {
+	return ratelimit.Config{
+		KeyRate:  c.RateLimit,
+		KeyBurst: c.RateBurst,
+		IPRate:   c.IPRateLimit,
+		IPBurst:  c.IPRateBurst,
+		Daily:    c.DailyQuota,
+		Monthly:  c.MonthlyQuota,
+	}
+}
+
+// corsConfig returns the global CORS of the Config
+func corsConfig(c *config.Config) cors.Config {
+	return cors.Config{
+		CORS: keys.CORS{
+			Origins: config.List(c.CORSOrigins),
+			Methods: config.List(c.CORSMethods),
+			Headers: config.List(c.CORSHeaders),
+		},
+		MaxAge: c.CORSMaxAge,
+	}
+}
+
+// configureTLS configures the router to serve HTTPS when a certificate or
+// ACME hosts are configured, returning the handler redirecting HTTP to
+// HTTPS, or nil to serve plain HTTP
+func configureTLS(e *echo.Echo, c *config.Config) (http.Handler, error) {
+	redirect := certs.RedirectHandler(c.Port)
+	switch {
+	case c.TLSCert != "" || c.TLSKey != "":
+		r, err := certs.NewReloader(c.TLSCert, c.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		e.TLSServer.TLSConfig = r.TLSConfig()
+	case c.ACMEHosts != "":
+		m, err := certs.NewACME(certs.ACMEConfig{
+			Hosts:        config.List(c.ACMEHosts),
+			Email:        c.ACMEEmail,
+			DirectoryURL: c.ACMEDirectory,
+			CacheDir:     c.ACMECache,
+			CAFile:       c.ACMECA,
+		})
+		if err != nil {
+			return nil, err
 		}
-		return next(c)
+		e.TLSServer.TLSConfig = m.TLSConfig()
+		redirect = m.HTTPHandler(redirect) // Also answers HTTP-01 challenges
+	default:
+		return nil, nil
 	}
+	return redirect, nil
 }
 
-// getEnv retrieves variables from the environment and falls back
-// to a passed fallback variable if it isn't set
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+// start serves the router over HTTPS once configured by configureTLS, and
+// over plain HTTP otherwise
+func start(e *echo.Echo, c *config.Config) error {
+	if e.TLSServer.TLSConfig == nil {
+		return e.Start(":" + c.Port)
+	}
+	e.TLSServer.Addr = ":" + c.Port
+	return e.StartServer(e.TLSServer)
+}
+
+// bindRoutes binds every API endpoint to the router, authenticating
+// requests with the passed Authenticator and limiting all but health
+// checks with the passed Limiter. Health checks report the readiness of
+// the passed Monitor. Each route must also be described by the
+// api.OpenAPI document
+func bindRoutes(e *echo.Echo, c *config.Config, v *verifier.Verifier, m *jobs.Manager,
+	u *usage.Store, a keys.Authenticator, l *ratelimit.Limiter, mon *health.Monitor) {
+	// auth asserts the X-Auth-Token header holds a key granted the scope
+	auth := func(scope string) echo.MiddlewareFunc {
+		return keys.Middleware(a, scope, false)
+	}
+	// authQuery also accepts the token queryparam
+	authQuery := func(scope string) echo.MiddlewareFunc {
+		return keys.Middleware(a, scope, true)
+	}
+	// limit counts requests against the limits of their key and IP
+	limit := ratelimit.Middleware(l, c.TrustProxy)
+
+	e.GET("/v1/:format/:email", api.LookupHandler(v), auth(keys.ScopeLookup), limit)
+	e.POST("/v1/:format", api.LookupPostHandler(v), auth(keys.ScopeLookup), limit)
+	e.POST("/v1/batch/:format", api.BatchHandler(v, c.BatchLimit, c.BatchWorkers),
+		auth(keys.ScopeBatch), limit)
+	e.GET("/v1/batch/:format", api.BatchHandler(v, c.BatchLimit, c.BatchWorkers),
+		authQuery(keys.ScopeBatch), limit)
+	e.POST("/v1/jobs/:format", api.CreateJobHandler(m, c.JobsLimit), auth(keys.ScopeBatch), limit)
+	e.POST("/v1/async/:format", api.AsyncLookupHandler(m), auth(keys.ScopeLookup), limit)
+	e.GET("/v1/jobs/:format/:id", api.JobHandler(m), auth(keys.ScopeBatch), limit)
+	e.DELETE("/v1/jobs/:format/:id", api.CancelJobHandler(m), auth(keys.ScopeBatch), limit)
+	e.GET("/v1/jobs/:format/:id/results", api.JobResultsHandler(m), auth(keys.ScopeBatch), limit)
+	e.GET("/v1/usage/:format", api.UsageHandler(u), auth(keys.ScopeAdmin), limit)
+	e.GET("/v1/health", api.HealthHandler(mon), auth(keys.ScopeAny))
+	e.GET("/v1/health/live", api.LivenessHandler())
+	e.GET("/v1/health/ready", api.HealthHandler(mon))
+	e.GET("/metrics", echo.WrapHandler(metrics.Handler(prometheus.DefaultGatherer)))
+	e.GET("/openapi.json", api.OpenAPIHandler())
+
+	// Bind the v2 API endpoints to router
+	v2 := e.Group("/v2", api.ErrorMiddlewareV2)
+	v2.GET("/lookups/:format", api.LookupV2Handler(v), authQuery(keys.ScopeLookup), limit)
+	v2.GET("/health", api.HealthHandler(mon), authQuery(keys.ScopeAny))
+}
+
+// heloHostname returns the configured HELO hostname, or the PTR of the
+// discovered public IP, warning when it isn't forward-confirmed by
+// reverse DNS. Failing to discover the IP isn't fatal so the server can
+// start on restricted networks
+func heloHostname(c *config.Config, logger *slog.Logger) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var ip net.IP
+	d, _ := identity.NewDiscoverer(c.IPDiscovery, c.IPDiscoveryURL,
+		c.IPDiscoveryInterface) // Already validated
+	if d != nil {
+		var err error
+		if ip, err = d.PublicIP(ctx); err != nil {
+			logger.Warn("Failed to discover the public IP", "discovery", c.IPDiscovery,
+				"error", err)
+		}
+	}
+	hostname := c.HELOHostname
+	if hostname == "" {
+		hostname = identity.Hostname(ctx, net.DefaultResolver, ip)
+	}
+	if err := identity.CheckFCrDNS(ctx, net.DefaultResolver, hostname, ip); err != nil {
+		logger.Warn("HELO hostname isn't forward-confirmed by reverse DNS, mail servers may refuse lookups",
+			"hostname", hostname, "error", err)
+	} else {
+		logger.Info("Identifying as HELO hostname", "hostname", hostname)
+	}
+	return hostname
+}
+
+// webhookMiddleware makes the current global secret signing the callbacks
+// of jobs, and the public URL they link to, available to the handlers
+func webhookMiddleware(config func() *config.Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			current := config()
+			if current.WebhookSecret != "" {
+				c.Set(api.WebhookSecretKey, current.WebhookSecret)
+			}
+			if current.PublicURL != "" {
+				c.Set(api.PublicURLKey, current.PublicURL)
+			}
+			return next(c)
+		}
+	}
+}
+
+// jsonpMiddleware disables JSONP responses for the handlers unless it's
+// currently enabled
+func jsonpMiddleware(enabled func() bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !enabled() {
+				c.Set(api.DisableJSONPKey, true)
+			}
+			return next(c)
+		}
 	}
-	return fallback
 }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/api"
+	"github.com/sdwolfe32/trumail/config"
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/ratelimit"
+	"github.com/sdwolfe32/trumail/verifier"
+	"github.com/stretchr/testify/assert"
+)
+
+// pathParam matches the echo path parameters in a route
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	e := echo.New()
+	bindRoutes(e, config.Default(), verifier.NewVerifier("localhost", "admin@localhost"), nil, nil,
+		keys.Static(""), ratelimit.New(ratelimit.Config{}, ratelimit.NewMemory()), nil)
+	spec := api.OpenAPI()
+
+	// Every registered route must be documented
+	registered := make(map[string]bool)
+	for _, r := range e.Routes() {
+		if r.Path == "/v2" || strings.HasSuffix(r.Path, "/*") {
+			continue // The catch-all routes added by echo groups
+		}
+		path := pathParam.ReplaceAllString(r.Path, "{$1}")
+		method := strings.ToLower(r.Method)
+		registered[method+" "+path] = true
+		assert.NotNil(t, spec.Paths[path][method], "%s %s is not documented", r.Method, path)
+	}
+
+	// Every documented route must be registered
+	for path, item := range spec.Paths {
+		for method := range item {
+			assert.True(t, registered[method+" "+path], "%s %s is not registered", method, path)
+		}
+	}
+}
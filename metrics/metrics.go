@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/verifier"
+)
+
+const (
+	// namespace prefixes every metric exported by Trumail
+	namespace = "trumail"
+	// jwtKey is the key label of every lookup authenticated by a JWT
+	jwtKey = "jwt"
+)
+
+// Recorder is a verifier.Observer that records lookup outcomes, phase
+// latencies, in-flight SMTP sessions and mail server errors as
+// Prometheus metrics. All labels are drawn from fixed sets, or the names
+// of stored API keys, so metric cardinality stays bounded regardless of
+// the addresses verified or the subjects of JWTs
+type Recorder struct {
+	lookups        *prometheus.CounterVec
+	keyLookups     *prometheus.CounterVec
+	lookupErrors   *prometheus.CounterVec
+	lookupDuration prometheus.Histogram
+	phaseDuration  *prometheus.HistogramVec
+	sessions       prometheus.Gauge
+	mxErrors       *prometheus.CounterVec
+}
+
+// NewRecorder generates a new Recorder and registers its metrics with
+// the passed Registerer
+func NewRecorder(reg prometheus.Registerer) *Recorder {
+	r := &Recorder{
+		lookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "lookups_total",
+			Help:      "Lookups performed by status and reason.",
+		}, []string{"status", "reason"}),
+		keyLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "key_lookups_total",
+			Help:      "Lookups performed by API key and status.",
+		}, []string{"key", "status"}),
+		lookupErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "lookup_errors_total",
+			Help:      "Lookups that failed by LookupError code.",
+		}, []string{"code"}),
+		lookupDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "lookup_duration_seconds",
+			Help:      "Total time taken to perform a lookup.",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+		}),
+		phaseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "phase_duration_seconds",
+			Help:      "Time taken by each phase of a lookup.",
+			Buckets:   []float64{.001, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"phase"}),
+		sessions: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "smtp_sessions_in_flight",
+			Help:      "SMTP sessions currently open with mail servers.",
+		}),
+		mxErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mx_errors_total",
+			Help:      "Errors returned by mail servers by provider and phase.",
+		}, []string{"provider", "phase"}),
+	}
+	reg.MustRegister(r.lookups, r.keyLookups, r.lookupErrors, r.lookupDuration,
+		r.phaseDuration, r.sessions, r.mxErrors)
+	return r
+}
+
+// ObserveLookup records the outcome and total duration of a lookup,
+// attributing it to the API key carried by the context. Anonymous lookups
+// are attributed to an empty key and every JWT to a single jwt key
+func (r *Recorder) ObserveLookup(ctx context.Context, l *verifier.Lookup, err error, took time.Duration) {
+	status, reason := verifier.Outcome(l, err)
+	r.lookups.WithLabelValues(status, reason).Inc()
+	var name string
+	if key := keys.FromContext(ctx); key != nil && key.JWT() {
+		name = jwtKey
+	} else if key != nil {
+		name = key.Name
+	}
+	r.keyLookups.WithLabelValues(name, status).Inc()
+	if le, ok := err.(*verifier.LookupError); ok {
+		r.lookupErrors.WithLabelValues(le.Code()).Inc()
+	} else if err != nil {
+		r.lookupErrors.WithLabelValues(verifier.CodeUnknown).Inc()
+	}
+	r.lookupDuration.Observe(took.Seconds())
+}
+
+// ObservePhase records the duration of a lookup phase along with any
+// error returned by a mail server. Rejections of missing mailboxes, which
+// include the catch-all probe, are expected answers rather than errors
+func (r *Recorder) ObservePhase(_ context.Context, e verifier.PhaseEvent) {
+	r.phaseDuration.WithLabelValues(e.Phase).Observe(e.Took.Seconds())
+	if e.Host != "" && verifier.ParseSMTPError(e.Err) != nil {
+		r.mxErrors.WithLabelValues(Provider(e.Host), e.Phase).Inc()
+	}
+}
+
+// ObserveSession tracks the number of open SMTP sessions
+func (r *Recorder) ObserveSession(delta int) {
+	r.sessions.Add(float64(delta))
+}
+
+// Handler returns an http.Handler that serves the metrics registered
+// with the passed Gatherer
+func Handler(g prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/verifier"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	r := NewRecorder(reg)
+	ctx := keys.WithKey(context.Background(), &keys.Key{ID: "k1", Name: "reporting"})
+
+	// Lookups are counted by outcome, key and error code
+	r.ObserveLookup(ctx, &verifier.Lookup{ValidFormat: true, HostExists: true, Deliverable: true},
+		nil, time.Second)
+	r.ObserveLookup(context.Background(), nil,
+		&verifier.LookupError{Message: verifier.ErrTimeout}, time.Second)
+	assert.Equal(t, 1.0, testutil.ToFloat64(
+		r.keyLookups.WithLabelValues("reporting", verifier.StatusDeliverable)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.lookupErrors.WithLabelValues(verifier.CodeTimeout)))
+
+	// Every JWT subject shares a single key label
+	for _, subject := range []string{"alice", "bob"} {
+		r.ObserveLookup(keys.WithKey(context.Background(), &keys.Key{ID: "jwt:" + subject,
+			Name: subject}), nil, &verifier.LookupError{Message: verifier.ErrTimeout}, time.Second)
+	}
+	assert.Equal(t, 2.0, testutil.ToFloat64(
+		r.keyLookups.WithLabelValues("jwt", verifier.StatusUnknown)))
+
+	// Only mail server errors that aren't a missing mailbox are counted
+	host := "alt1.gmail-smtp-in.l.google.com"
+	r.ObservePhase(ctx, verifier.PhaseEvent{Phase: verifier.PhaseRcpt, Host: host,
+		Took: time.Millisecond, Err: &textproto.Error{Code: 550, Msg: "5.1.1 User unknown"}})
+	r.ObservePhase(ctx, verifier.PhaseEvent{Phase: verifier.PhaseCatchAll, Host: host,
+		Err: &textproto.Error{Code: 550, Msg: "5.1.1 No such mailbox"}})
+	r.ObservePhase(ctx, verifier.PhaseEvent{Phase: verifier.PhaseRcpt, Host: host,
+		Err: &textproto.Error{Code: 421, Msg: "4.7.0 Try again later"}})
+	r.ObservePhase(ctx, verifier.PhaseEvent{Phase: verifier.PhaseDial, Host: host,
+		Err: errors.New("dial tcp: i/o timeout")})
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.mxErrors.WithLabelValues("google", verifier.PhaseRcpt)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.mxErrors.WithLabelValues("google", verifier.PhaseDial)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(r.mxErrors.WithLabelValues("google", verifier.PhaseCatchAll)))
+
+	// Sessions are tracked as they open and close
+	r.ObserveSession(1)
+	r.ObserveSession(1)
+	r.ObserveSession(-1)
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.sessions))
+
+	// The metrics are served in the Prometheus text format
+	rec := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), `trumail_lookups_total{reason="accepted_email",status="deliverable"} 1`)
+	assert.Contains(t, rec.Body.String(), "trumail_lookup_duration_seconds_count 4")
+	assert.Contains(t, rec.Body.String(), "trumail_smtp_sessions_in_flight 1")
+	assert.Contains(t, rec.Body.String(), `trumail_mx_errors_total{phase="rcpt",provider="google"} 1`)
+}
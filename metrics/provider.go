@@ -0,0 +1,40 @@
+package metrics
+
+import "strings"
+
+// ProviderOther is the provider assigned to any mail server not run by
+// one of the known providers
+const ProviderOther = "other"
+
+// providers maps MX host suffixes to the mail provider operating them
+var providers = []struct{ suffix, name string }{
+	{"google.com", "google"},
+	{"googlemail.com", "google"},
+	{"outlook.com", "microsoft"},
+	{"hotmail.com", "microsoft"},
+	{"yahoodns.net", "yahoo"},
+	{"icloud.com", "apple"},
+	{"me.com", "apple"},
+	{"zoho.com", "zoho"},
+	{"pphosted.com", "proofpoint"},
+	{"ppe-hosted.com", "proofpoint"},
+	{"mimecast.com", "mimecast"},
+	{"messagelabs.com", "broadcom"},
+	{"barracudanetworks.com", "barracuda"},
+	{"secureserver.net", "godaddy"},
+	{"mailgun.org", "mailgun"},
+	{"yandex.net", "yandex"},
+	{"mail.ru", "mailru"},
+}
+
+// Provider returns the name of the mail provider operating the passed MX
+// host, or ProviderOther if it isn't a known provider
+func Provider(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, p := range providers {
+		if host == p.suffix || strings.HasSuffix(host, "."+p.suffix) {
+			return p.name
+		}
+	}
+	return ProviderOther
+}
@@ -0,0 +1,15 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider(t *testing.T) {
+	assert.Equal(t, "google", Provider("alt1.gmail-smtp-in.l.google.com."))
+	assert.Equal(t, "microsoft", Provider("domain-com.mail.protection.outlook.com"))
+	assert.Equal(t, "proofpoint", Provider("MX0A-001.PPHOSTED.COM"))
+	assert.Equal(t, ProviderOther, Provider("mail.example.com"))
+	assert.Equal(t, ProviderOther, Provider("notgoogle.com"))
+}
@@ -0,0 +1,5 @@
+// Package pb contains the protocol buffer messages and services shared by
+// the API encodings
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative trumail.proto
@@ -0,0 +1,1555 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: trumail.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Lookup contains all output data for an email verification Lookup
+type Lookup struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Username      string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	Domain        string                 `protobuf:"bytes,3,opt,name=domain,proto3" json:"domain,omitempty"`
+	Md5Hash       string                 `protobuf:"bytes,4,opt,name=md5_hash,json=md5Hash,proto3" json:"md5_hash,omitempty"`
+	ValidFormat   bool                   `protobuf:"varint,5,opt,name=valid_format,json=validFormat,proto3" json:"valid_format,omitempty"`
+	Deliverable   bool                   `protobuf:"varint,6,opt,name=deliverable,proto3" json:"deliverable,omitempty"`
+	FullInbox     bool                   `protobuf:"varint,7,opt,name=full_inbox,json=fullInbox,proto3" json:"full_inbox,omitempty"`
+	HostExists    bool                   `protobuf:"varint,8,opt,name=host_exists,json=hostExists,proto3" json:"host_exists,omitempty"`
+	CatchAll      bool                   `protobuf:"varint,9,opt,name=catch_all,json=catchAll,proto3" json:"catch_all,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Lookup) Reset() {
+	*x = Lookup{}
+	mi := &file_trumail_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Lookup) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Lookup) ProtoMessage() {}
+
+func (x *Lookup) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Lookup.ProtoReflect.Descriptor instead.
+func (*Lookup) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Lookup) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *Lookup) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *Lookup) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *Lookup) GetMd5Hash() string {
+	if x != nil {
+		return x.Md5Hash
+	}
+	return ""
+}
+
+func (x *Lookup) GetValidFormat() bool {
+	if x != nil {
+		return x.ValidFormat
+	}
+	return false
+}
+
+func (x *Lookup) GetDeliverable() bool {
+	if x != nil {
+		return x.Deliverable
+	}
+	return false
+}
+
+func (x *Lookup) GetFullInbox() bool {
+	if x != nil {
+		return x.FullInbox
+	}
+	return false
+}
+
+func (x *Lookup) GetHostExists() bool {
+	if x != nil {
+		return x.HostExists
+	}
+	return false
+}
+
+func (x *Lookup) GetCatchAll() bool {
+	if x != nil {
+		return x.CatchAll
+	}
+	return false
+}
+
+// LookupV2 is a Lookup extended with the outcome and timings of the lookup
+type LookupV2 struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Lookup        *Lookup                `protobuf:"bytes,1,opt,name=lookup,proto3" json:"lookup,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	Score         int32                  `protobuf:"varint,4,opt,name=score,proto3" json:"score,omitempty"`
+	Timings       *Timings               `protobuf:"bytes,5,opt,name=timings,proto3" json:"timings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupV2) Reset() {
+	*x = LookupV2{}
+	mi := &file_trumail_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupV2) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupV2) ProtoMessage() {}
+
+func (x *LookupV2) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupV2.ProtoReflect.Descriptor instead.
+func (*LookupV2) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *LookupV2) GetLookup() *Lookup {
+	if x != nil {
+		return x.Lookup
+	}
+	return nil
+}
+
+func (x *LookupV2) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *LookupV2) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *LookupV2) GetScore() int32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *LookupV2) GetTimings() *Timings {
+	if x != nil {
+		return x.Timings
+	}
+	return nil
+}
+
+// Timings holds the milliseconds taken by a lookup and each of its phases
+type Timings struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Total         int64                  `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"`
+	Parse         int64                  `protobuf:"varint,2,opt,name=parse,proto3" json:"parse,omitempty"`
+	Mx            int64                  `protobuf:"varint,3,opt,name=mx,proto3" json:"mx,omitempty"`
+	Dial          int64                  `protobuf:"varint,4,opt,name=dial,proto3" json:"dial,omitempty"`
+	Hello         int64                  `protobuf:"varint,5,opt,name=hello,proto3" json:"hello,omitempty"`
+	Mail          int64                  `protobuf:"varint,6,opt,name=mail,proto3" json:"mail,omitempty"`
+	CatchAll      int64                  `protobuf:"varint,7,opt,name=catch_all,json=catchAll,proto3" json:"catch_all,omitempty"`
+	Rcpt          int64                  `protobuf:"varint,8,opt,name=rcpt,proto3" json:"rcpt,omitempty"`
+	Quit          int64                  `protobuf:"varint,9,opt,name=quit,proto3" json:"quit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Timings) Reset() {
+	*x = Timings{}
+	mi := &file_trumail_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Timings) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Timings) ProtoMessage() {}
+
+func (x *Timings) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Timings.ProtoReflect.Descriptor instead.
+func (*Timings) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Timings) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Timings) GetParse() int64 {
+	if x != nil {
+		return x.Parse
+	}
+	return 0
+}
+
+func (x *Timings) GetMx() int64 {
+	if x != nil {
+		return x.Mx
+	}
+	return 0
+}
+
+func (x *Timings) GetDial() int64 {
+	if x != nil {
+		return x.Dial
+	}
+	return 0
+}
+
+func (x *Timings) GetHello() int64 {
+	if x != nil {
+		return x.Hello
+	}
+	return 0
+}
+
+func (x *Timings) GetMail() int64 {
+	if x != nil {
+		return x.Mail
+	}
+	return 0
+}
+
+func (x *Timings) GetCatchAll() int64 {
+	if x != nil {
+		return x.CatchAll
+	}
+	return 0
+}
+
+func (x *Timings) GetRcpt() int64 {
+	if x != nil {
+		return x.Rcpt
+	}
+	return 0
+}
+
+func (x *Timings) GetQuit() int64 {
+	if x != nil {
+		return x.Quit
+	}
+	return 0
+}
+
+// Health is a healthcheck response body
+type Health struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"` // OK when ready, DOWN otherwise
+	Components    []*HealthComponent     `protobuf:"bytes,2,rep,name=components,proto3" json:"components,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Health) Reset() {
+	*x = Health{}
+	mi := &file_trumail_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Health) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Health) ProtoMessage() {}
+
+func (x *Health) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Health.ProtoReflect.Descriptor instead.
+func (*Health) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Health) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Health) GetComponents() []*HealthComponent {
+	if x != nil {
+		return x.Components
+	}
+	return nil
+}
+
+// HealthComponent is the last known health of a single dependency
+type HealthComponent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"` // OK, DOWN or UNKNOWN
+	Detail        string                 `protobuf:"bytes,3,opt,name=detail,proto3" json:"detail,omitempty"`
+	Checked       string                 `protobuf:"bytes,4,opt,name=checked,proto3" json:"checked,omitempty"` // RFC 3339
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthComponent) Reset() {
+	*x = HealthComponent{}
+	mi := &file_trumail_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthComponent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthComponent) ProtoMessage() {}
+
+func (x *HealthComponent) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthComponent.ProtoReflect.Descriptor instead.
+func (*HealthComponent) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *HealthComponent) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *HealthComponent) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *HealthComponent) GetDetail() string {
+	if x != nil {
+		return x.Detail
+	}
+	return ""
+}
+
+func (x *HealthComponent) GetChecked() string {
+	if x != nil {
+		return x.Checked
+	}
+	return ""
+}
+
+// Error is the body of an error returned from a v1 route
+type Error struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	mi := &file_trumail_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// LookupError is the body of a failed v1 lookup
+type LookupError struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	Details       string                 `protobuf:"bytes,2,opt,name=details,proto3" json:"details,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupError) Reset() {
+	*x = LookupError{}
+	mi := &file_trumail_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupError) ProtoMessage() {}
+
+func (x *LookupError) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupError.ProtoReflect.Descriptor instead.
+func (*LookupError) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *LookupError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *LookupError) GetDetails() string {
+	if x != nil {
+		return x.Details
+	}
+	return ""
+}
+
+// ErrorV2 is the body of every error returned from a v2 route
+type ErrorV2 struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       string                 `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	Status        int32                  `protobuf:"varint,2,opt,name=status,proto3" json:"status,omitempty"`
+	Code          string                 `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	Details       string                 `protobuf:"bytes,5,opt,name=details,proto3" json:"details,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ErrorV2) Reset() {
+	*x = ErrorV2{}
+	mi := &file_trumail_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ErrorV2) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ErrorV2) ProtoMessage() {}
+
+func (x *ErrorV2) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ErrorV2.ProtoReflect.Descriptor instead.
+func (*ErrorV2) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ErrorV2) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *ErrorV2) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+func (x *ErrorV2) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ErrorV2) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ErrorV2) GetDetails() string {
+	if x != nil {
+		return x.Details
+	}
+	return ""
+}
+
+// BatchLookup is a Lookup performed as part of a batch along with the error
+// it failed with, if any
+type BatchLookup struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Lookup        *Lookup                `protobuf:"bytes,1,opt,name=lookup,proto3" json:"lookup,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Code          string                 `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchLookup) Reset() {
+	*x = BatchLookup{}
+	mi := &file_trumail_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchLookup) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchLookup) ProtoMessage() {}
+
+func (x *BatchLookup) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchLookup.ProtoReflect.Descriptor instead.
+func (*BatchLookup) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *BatchLookup) GetLookup() *Lookup {
+	if x != nil {
+		return x.Lookup
+	}
+	return nil
+}
+
+func (x *BatchLookup) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *BatchLookup) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+// BatchLookups are the lookups of a batch in the order of its emails
+type BatchLookups struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Lookups       []*BatchLookup         `protobuf:"bytes,1,rep,name=lookups,proto3" json:"lookups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchLookups) Reset() {
+	*x = BatchLookups{}
+	mi := &file_trumail_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchLookups) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchLookups) ProtoMessage() {}
+
+func (x *BatchLookups) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchLookups.ProtoReflect.Descriptor instead.
+func (*BatchLookups) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *BatchLookups) GetLookups() []*BatchLookup {
+	if x != nil {
+		return x.Lookups
+	}
+	return nil
+}
+
+// Job reports the progress of a bulk verification job
+type Job struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Total         int32                  `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+	Processed     int32                  `protobuf:"varint,4,opt,name=processed,proto3" json:"processed,omitempty"`
+	Deliverable   int32                  `protobuf:"varint,5,opt,name=deliverable,proto3" json:"deliverable,omitempty"`
+	Undeliverable int32                  `protobuf:"varint,6,opt,name=undeliverable,proto3" json:"undeliverable,omitempty"`
+	Unknown       int32                  `protobuf:"varint,7,opt,name=unknown,proto3" json:"unknown,omitempty"`
+	Created       string                 `protobuf:"bytes,8,opt,name=created,proto3" json:"created,omitempty"` // RFC 3339
+	Updated       string                 `protobuf:"bytes,9,opt,name=updated,proto3" json:"updated,omitempty"` // RFC 3339
+	Callback      *JobCallback           `protobuf:"bytes,10,opt,name=callback,proto3" json:"callback,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_trumail_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Job) ProtoMessage() {}
+
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Job) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Job) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Job) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Job) GetProcessed() int32 {
+	if x != nil {
+		return x.Processed
+	}
+	return 0
+}
+
+func (x *Job) GetDeliverable() int32 {
+	if x != nil {
+		return x.Deliverable
+	}
+	return 0
+}
+
+func (x *Job) GetUndeliverable() int32 {
+	if x != nil {
+		return x.Undeliverable
+	}
+	return 0
+}
+
+func (x *Job) GetUnknown() int32 {
+	if x != nil {
+		return x.Unknown
+	}
+	return 0
+}
+
+func (x *Job) GetCreated() string {
+	if x != nil {
+		return x.Created
+	}
+	return ""
+}
+
+func (x *Job) GetUpdated() string {
+	if x != nil {
+		return x.Updated
+	}
+	return ""
+}
+
+func (x *Job) GetCallback() *JobCallback {
+	if x != nil {
+		return x.Callback
+	}
+	return nil
+}
+
+// JobCallback reports the delivery of a jobs callback
+type JobCallback struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Attempts      []*CallbackAttempt     `protobuf:"bytes,3,rep,name=attempts,proto3" json:"attempts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobCallback) Reset() {
+	*x = JobCallback{}
+	mi := &file_trumail_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobCallback) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobCallback) ProtoMessage() {}
+
+func (x *JobCallback) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobCallback.ProtoReflect.Descriptor instead.
+func (*JobCallback) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *JobCallback) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *JobCallback) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *JobCallback) GetAttempts() []*CallbackAttempt {
+	if x != nil {
+		return x.Attempts
+	}
+	return nil
+}
+
+// CallbackAttempt records a single attempt at delivering a callback
+type CallbackAttempt struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Attempt       int32                  `protobuf:"varint,1,opt,name=attempt,proto3" json:"attempt,omitempty"`
+	Time          string                 `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"` // RFC 3339
+	Status        int32                  `protobuf:"varint,3,opt,name=status,proto3" json:"status,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	Duration      int64                  `protobuf:"varint,5,opt,name=duration,proto3" json:"duration,omitempty"` // Nanoseconds
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CallbackAttempt) Reset() {
+	*x = CallbackAttempt{}
+	mi := &file_trumail_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CallbackAttempt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CallbackAttempt) ProtoMessage() {}
+
+func (x *CallbackAttempt) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CallbackAttempt.ProtoReflect.Descriptor instead.
+func (*CallbackAttempt) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *CallbackAttempt) GetAttempt() int32 {
+	if x != nil {
+		return x.Attempt
+	}
+	return 0
+}
+
+func (x *CallbackAttempt) GetTime() string {
+	if x != nil {
+		return x.Time
+	}
+	return ""
+}
+
+func (x *CallbackAttempt) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+func (x *CallbackAttempt) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *CallbackAttempt) GetDuration() int64 {
+	if x != nil {
+		return x.Duration
+	}
+	return 0
+}
+
+// StreamLookup is a BatchLookup streamed as soon as it completes, along with
+// the index of its email in the request
+type StreamLookup struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Lookup        *BatchLookup           `protobuf:"bytes,2,opt,name=lookup,proto3" json:"lookup,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamLookup) Reset() {
+	*x = StreamLookup{}
+	mi := &file_trumail_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamLookup) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamLookup) ProtoMessage() {}
+
+func (x *StreamLookup) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamLookup.ProtoReflect.Descriptor instead.
+func (*StreamLookup) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *StreamLookup) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *StreamLookup) GetLookup() *BatchLookup {
+	if x != nil {
+		return x.Lookup
+	}
+	return nil
+}
+
+// LookupOptions control how a lookup is performed. Zero valued options use
+// the verifiers defaults
+type LookupOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Timeout       int32                  `protobuf:"varint,1,opt,name=timeout,proto3" json:"timeout,omitempty"` // Seconds
+	Retries       int32                  `protobuf:"varint,2,opt,name=retries,proto3" json:"retries,omitempty"`
+	SkipCatchAll  bool                   `protobuf:"varint,3,opt,name=skip_catch_all,json=skipCatchAll,proto3" json:"skip_catch_all,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupOptions) Reset() {
+	*x = LookupOptions{}
+	mi := &file_trumail_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupOptions) ProtoMessage() {}
+
+func (x *LookupOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupOptions.ProtoReflect.Descriptor instead.
+func (*LookupOptions) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *LookupOptions) GetTimeout() int32 {
+	if x != nil {
+		return x.Timeout
+	}
+	return 0
+}
+
+func (x *LookupOptions) GetRetries() int32 {
+	if x != nil {
+		return x.Retries
+	}
+	return 0
+}
+
+func (x *LookupOptions) GetSkipCatchAll() bool {
+	if x != nil {
+		return x.SkipCatchAll
+	}
+	return false
+}
+
+// VerifyRequest is the request of a single lookup
+type VerifyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Email         string                 `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	Options       *LookupOptions         `protobuf:"bytes,2,opt,name=options,proto3" json:"options,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyRequest) Reset() {
+	*x = VerifyRequest{}
+	mi := &file_trumail_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyRequest) ProtoMessage() {}
+
+func (x *VerifyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyRequest.ProtoReflect.Descriptor instead.
+func (*VerifyRequest) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *VerifyRequest) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *VerifyRequest) GetOptions() *LookupOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+// VerifyBatchRequest is the request of a batch of lookups
+type VerifyBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Emails        []string               `protobuf:"bytes,1,rep,name=emails,proto3" json:"emails,omitempty"`
+	Options       *LookupOptions         `protobuf:"bytes,2,opt,name=options,proto3" json:"options,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyBatchRequest) Reset() {
+	*x = VerifyBatchRequest{}
+	mi := &file_trumail_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyBatchRequest) ProtoMessage() {}
+
+func (x *VerifyBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyBatchRequest.ProtoReflect.Descriptor instead.
+func (*VerifyBatchRequest) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *VerifyBatchRequest) GetEmails() []string {
+	if x != nil {
+		return x.Emails
+	}
+	return nil
+}
+
+func (x *VerifyBatchRequest) GetOptions() *LookupOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+// UsageRecord is the usage of a single API key on a single UTC day
+type UsageRecord struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Date          string                 `protobuf:"bytes,1,opt,name=date,proto3" json:"date,omitempty"` // YYYY-MM-DD
+	KeyId         string                 `protobuf:"bytes,2,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	KeyName       string                 `protobuf:"bytes,3,opt,name=key_name,json=keyName,proto3" json:"key_name,omitempty"`
+	Lookups       int32                  `protobuf:"varint,4,opt,name=lookups,proto3" json:"lookups,omitempty"`
+	Deliverable   int32                  `protobuf:"varint,5,opt,name=deliverable,proto3" json:"deliverable,omitempty"`
+	Undeliverable int32                  `protobuf:"varint,6,opt,name=undeliverable,proto3" json:"undeliverable,omitempty"`
+	Unknown       int32                  `protobuf:"varint,7,opt,name=unknown,proto3" json:"unknown,omitempty"`
+	Live          int32                  `protobuf:"varint,8,opt,name=live,proto3" json:"live,omitempty"`
+	Cached        int32                  `protobuf:"varint,9,opt,name=cached,proto3" json:"cached,omitempty"`
+	BatchRows     int32                  `protobuf:"varint,10,opt,name=batch_rows,json=batchRows,proto3" json:"batch_rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UsageRecord) Reset() {
+	*x = UsageRecord{}
+	mi := &file_trumail_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UsageRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UsageRecord) ProtoMessage() {}
+
+func (x *UsageRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UsageRecord.ProtoReflect.Descriptor instead.
+func (*UsageRecord) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *UsageRecord) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *UsageRecord) GetKeyId() string {
+	if x != nil {
+		return x.KeyId
+	}
+	return ""
+}
+
+func (x *UsageRecord) GetKeyName() string {
+	if x != nil {
+		return x.KeyName
+	}
+	return ""
+}
+
+func (x *UsageRecord) GetLookups() int32 {
+	if x != nil {
+		return x.Lookups
+	}
+	return 0
+}
+
+func (x *UsageRecord) GetDeliverable() int32 {
+	if x != nil {
+		return x.Deliverable
+	}
+	return 0
+}
+
+func (x *UsageRecord) GetUndeliverable() int32 {
+	if x != nil {
+		return x.Undeliverable
+	}
+	return 0
+}
+
+func (x *UsageRecord) GetUnknown() int32 {
+	if x != nil {
+		return x.Unknown
+	}
+	return 0
+}
+
+func (x *UsageRecord) GetLive() int32 {
+	if x != nil {
+		return x.Live
+	}
+	return 0
+}
+
+func (x *UsageRecord) GetCached() int32 {
+	if x != nil {
+		return x.Cached
+	}
+	return 0
+}
+
+func (x *UsageRecord) GetBatchRows() int32 {
+	if x != nil {
+		return x.BatchRows
+	}
+	return 0
+}
+
+// UsageRecords are the usage records of an export in order of date
+type UsageRecords struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Records       []*UsageRecord         `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UsageRecords) Reset() {
+	*x = UsageRecords{}
+	mi := &file_trumail_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UsageRecords) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UsageRecords) ProtoMessage() {}
+
+func (x *UsageRecords) ProtoReflect() protoreflect.Message {
+	mi := &file_trumail_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UsageRecords.ProtoReflect.Descriptor instead.
+func (*UsageRecords) Descriptor() ([]byte, []int) {
+	return file_trumail_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *UsageRecords) GetRecords() []*UsageRecord {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+var File_trumail_proto protoreflect.FileDescriptor
+
+const file_trumail_proto_rawDesc = "" +
+	"\n" +
+	"\rtrumail.proto\x12\atrumail\"\x93\x02\n" +
+	"\x06Lookup\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\x1a\n" +
+	"\busername\x18\x02 \x01(\tR\busername\x12\x16\n" +
+	"\x06domain\x18\x03 \x01(\tR\x06domain\x12\x19\n" +
+	"\bmd5_hash\x18\x04 \x01(\tR\amd5Hash\x12!\n" +
+	"\fvalid_format\x18\x05 \x01(\bR\vvalidFormat\x12 \n" +
+	"\vdeliverable\x18\x06 \x01(\bR\vdeliverable\x12\x1d\n" +
+	"\n" +
+	"full_inbox\x18\a \x01(\bR\tfullInbox\x12\x1f\n" +
+	"\vhost_exists\x18\b \x01(\bR\n" +
+	"hostExists\x12\x1b\n" +
+	"\tcatch_all\x18\t \x01(\bR\bcatchAll\"\xa5\x01\n" +
+	"\bLookupV2\x12'\n" +
+	"\x06lookup\x18\x01 \x01(\v2\x0f.trumail.LookupR\x06lookup\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\x12\x14\n" +
+	"\x05score\x18\x04 \x01(\x05R\x05score\x12*\n" +
+	"\atimings\x18\x05 \x01(\v2\x10.trumail.TimingsR\atimings\"\xc8\x01\n" +
+	"\aTimings\x12\x14\n" +
+	"\x05total\x18\x01 \x01(\x03R\x05total\x12\x14\n" +
+	"\x05parse\x18\x02 \x01(\x03R\x05parse\x12\x0e\n" +
+	"\x02mx\x18\x03 \x01(\x03R\x02mx\x12\x12\n" +
+	"\x04dial\x18\x04 \x01(\x03R\x04dial\x12\x14\n" +
+	"\x05hello\x18\x05 \x01(\x03R\x05hello\x12\x12\n" +
+	"\x04mail\x18\x06 \x01(\x03R\x04mail\x12\x1b\n" +
+	"\tcatch_all\x18\a \x01(\x03R\bcatchAll\x12\x12\n" +
+	"\x04rcpt\x18\b \x01(\x03R\x04rcpt\x12\x12\n" +
+	"\x04quit\x18\t \x01(\x03R\x04quit\"Z\n" +
+	"\x06Health\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x128\n" +
+	"\n" +
+	"components\x18\x02 \x03(\v2\x18.trumail.HealthComponentR\n" +
+	"components\"o\n" +
+	"\x0fHealthComponent\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x16\n" +
+	"\x06detail\x18\x03 \x01(\tR\x06detail\x12\x18\n" +
+	"\achecked\x18\x04 \x01(\tR\achecked\"!\n" +
+	"\x05Error\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\"A\n" +
+	"\vLookupError\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\x12\x18\n" +
+	"\adetails\x18\x02 \x01(\tR\adetails\"\x83\x01\n" +
+	"\aErrorV2\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\tR\aversion\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\x05R\x06status\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\tR\x04code\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\x12\x18\n" +
+	"\adetails\x18\x05 \x01(\tR\adetails\"`\n" +
+	"\vBatchLookup\x12'\n" +
+	"\x06lookup\x18\x01 \x01(\v2\x0f.trumail.LookupR\x06lookup\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\tR\x04code\">\n" +
+	"\fBatchLookups\x12.\n" +
+	"\alookups\x18\x01 \x03(\v2\x14.trumail.BatchLookupR\alookups\"\xa9\x02\n" +
+	"\x03Job\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x14\n" +
+	"\x05total\x18\x03 \x01(\x05R\x05total\x12\x1c\n" +
+	"\tprocessed\x18\x04 \x01(\x05R\tprocessed\x12 \n" +
+	"\vdeliverable\x18\x05 \x01(\x05R\vdeliverable\x12$\n" +
+	"\rundeliverable\x18\x06 \x01(\x05R\rundeliverable\x12\x18\n" +
+	"\aunknown\x18\a \x01(\x05R\aunknown\x12\x18\n" +
+	"\acreated\x18\b \x01(\tR\acreated\x12\x18\n" +
+	"\aupdated\x18\t \x01(\tR\aupdated\x120\n" +
+	"\bcallback\x18\n" +
+	" \x01(\v2\x14.trumail.JobCallbackR\bcallback\"m\n" +
+	"\vJobCallback\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x124\n" +
+	"\battempts\x18\x03 \x03(\v2\x18.trumail.CallbackAttemptR\battempts\"\x89\x01\n" +
+	"\x0fCallbackAttempt\x12\x18\n" +
+	"\aattempt\x18\x01 \x01(\x05R\aattempt\x12\x12\n" +
+	"\x04time\x18\x02 \x01(\tR\x04time\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\x05R\x06status\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12\x1a\n" +
+	"\bduration\x18\x05 \x01(\x03R\bduration\"R\n" +
+	"\fStreamLookup\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12,\n" +
+	"\x06lookup\x18\x02 \x01(\v2\x14.trumail.BatchLookupR\x06lookup\"i\n" +
+	"\rLookupOptions\x12\x18\n" +
+	"\atimeout\x18\x01 \x01(\x05R\atimeout\x12\x18\n" +
+	"\aretries\x18\x02 \x01(\x05R\aretries\x12$\n" +
+	"\x0eskip_catch_all\x18\x03 \x01(\bR\fskipCatchAll\"W\n" +
+	"\rVerifyRequest\x12\x14\n" +
+	"\x05email\x18\x01 \x01(\tR\x05email\x120\n" +
+	"\aoptions\x18\x02 \x01(\v2\x16.trumail.LookupOptionsR\aoptions\"^\n" +
+	"\x12VerifyBatchRequest\x12\x16\n" +
+	"\x06emails\x18\x01 \x03(\tR\x06emails\x120\n" +
+	"\aoptions\x18\x02 \x01(\v2\x16.trumail.LookupOptionsR\aoptions\"\x9a\x02\n" +
+	"\vUsageRecord\x12\x12\n" +
+	"\x04date\x18\x01 \x01(\tR\x04date\x12\x15\n" +
+	"\x06key_id\x18\x02 \x01(\tR\x05keyId\x12\x19\n" +
+	"\bkey_name\x18\x03 \x01(\tR\akeyName\x12\x18\n" +
+	"\alookups\x18\x04 \x01(\x05R\alookups\x12 \n" +
+	"\vdeliverable\x18\x05 \x01(\x05R\vdeliverable\x12$\n" +
+	"\rundeliverable\x18\x06 \x01(\x05R\rundeliverable\x12\x18\n" +
+	"\aunknown\x18\a \x01(\x05R\aunknown\x12\x12\n" +
+	"\x04live\x18\b \x01(\x05R\x04live\x12\x16\n" +
+	"\x06cached\x18\t \x01(\x05R\x06cached\x12\x1d\n" +
+	"\n" +
+	"batch_rows\x18\n" +
+	" \x01(\x05R\tbatchRows\">\n" +
+	"\fUsageRecords\x12.\n" +
+	"\arecords\x18\x01 \x03(\v2\x14.trumail.UsageRecordR\arecords2\x81\x01\n" +
+	"\aTrumail\x121\n" +
+	"\x06Verify\x12\x16.trumail.VerifyRequest\x1a\x0f.trumail.Lookup\x12C\n" +
+	"\vVerifyBatch\x12\x1b.trumail.VerifyBatchRequest\x1a\x15.trumail.StreamLookup0\x01B!Z\x1fgithub.com/sdwolfe32/trumail/pbb\x06proto3"
+
+var (
+	file_trumail_proto_rawDescOnce sync.Once
+	file_trumail_proto_rawDescData []byte
+)
+
+func file_trumail_proto_rawDescGZIP() []byte {
+	file_trumail_proto_rawDescOnce.Do(func() {
+		file_trumail_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_trumail_proto_rawDesc), len(file_trumail_proto_rawDesc)))
+	})
+	return file_trumail_proto_rawDescData
+}
+
+var file_trumail_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_trumail_proto_goTypes = []any{
+	(*Lookup)(nil),             // 0: trumail.Lookup
+	(*LookupV2)(nil),           // 1: trumail.LookupV2
+	(*Timings)(nil),            // 2: trumail.Timings
+	(*Health)(nil),             // 3: trumail.Health
+	(*HealthComponent)(nil),    // 4: trumail.HealthComponent
+	(*Error)(nil),              // 5: trumail.Error
+	(*LookupError)(nil),        // 6: trumail.LookupError
+	(*ErrorV2)(nil),            // 7: trumail.ErrorV2
+	(*BatchLookup)(nil),        // 8: trumail.BatchLookup
+	(*BatchLookups)(nil),       // 9: trumail.BatchLookups
+	(*Job)(nil),                // 10: trumail.Job
+	(*JobCallback)(nil),        // 11: trumail.JobCallback
+	(*CallbackAttempt)(nil),    // 12: trumail.CallbackAttempt
+	(*StreamLookup)(nil),       // 13: trumail.StreamLookup
+	(*LookupOptions)(nil),      // 14: trumail.LookupOptions
+	(*VerifyRequest)(nil),      // 15: trumail.VerifyRequest
+	(*VerifyBatchRequest)(nil), // 16: trumail.VerifyBatchRequest
+	(*UsageRecord)(nil),        // 17: trumail.UsageRecord
+	(*UsageRecords)(nil),       // 18: trumail.UsageRecords
+}
+var file_trumail_proto_depIdxs = []int32{
+	0,  // 0: trumail.LookupV2.lookup:type_name -> trumail.Lookup
+	2,  // 1: trumail.LookupV2.timings:type_name -> trumail.Timings
+	4,  // 2: trumail.Health.components:type_name -> trumail.HealthComponent
+	0,  // 3: trumail.BatchLookup.lookup:type_name -> trumail.Lookup
+	8,  // 4: trumail.BatchLookups.lookups:type_name -> trumail.BatchLookup
+	11, // 5: trumail.Job.callback:type_name -> trumail.JobCallback
+	12, // 6: trumail.JobCallback.attempts:type_name -> trumail.CallbackAttempt
+	8,  // 7: trumail.StreamLookup.lookup:type_name -> trumail.BatchLookup
+	14, // 8: trumail.VerifyRequest.options:type_name -> trumail.LookupOptions
+	14, // 9: trumail.VerifyBatchRequest.options:type_name -> trumail.LookupOptions
+	17, // 10: trumail.UsageRecords.records:type_name -> trumail.UsageRecord
+	15, // 11: trumail.Trumail.Verify:input_type -> trumail.VerifyRequest
+	16, // 12: trumail.Trumail.VerifyBatch:input_type -> trumail.VerifyBatchRequest
+	0,  // 13: trumail.Trumail.Verify:output_type -> trumail.Lookup
+	13, // 14: trumail.Trumail.VerifyBatch:output_type -> trumail.StreamLookup
+	13, // [13:15] is the sub-list for method output_type
+	11, // [11:13] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_trumail_proto_init() }
+func file_trumail_proto_init() {
+	if File_trumail_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_trumail_proto_rawDesc), len(file_trumail_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   19,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_trumail_proto_goTypes,
+		DependencyIndexes: file_trumail_proto_depIdxs,
+		MessageInfos:      file_trumail_proto_msgTypes,
+	}.Build()
+	File_trumail_proto = out.File
+	file_trumail_proto_goTypes = nil
+	file_trumail_proto_depIdxs = nil
+}
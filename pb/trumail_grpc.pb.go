@@ -0,0 +1,177 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: trumail.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Trumail_Verify_FullMethodName      = "/trumail.Trumail/Verify"
+	Trumail_VerifyBatch_FullMethodName = "/trumail.Trumail/VerifyBatch"
+)
+
+// TrumailClient is the client API for Trumail service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Trumail verifies email addresses. Every call requires the auth token in
+// the x-auth-token metadata when one is configured
+type TrumailClient interface {
+	// Verify verifies a single email address. Failed lookups return an
+	// INTERNAL status carrying the LookupError as a detail
+	Verify(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*Lookup, error)
+	// VerifyBatch verifies every email address in the request, streaming each
+	// lookup as soon as it completes
+	VerifyBatch(ctx context.Context, in *VerifyBatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamLookup], error)
+}
+
+type trumailClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTrumailClient(cc grpc.ClientConnInterface) TrumailClient {
+	return &trumailClient{cc}
+}
+
+func (c *trumailClient) Verify(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*Lookup, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Lookup)
+	err := c.cc.Invoke(ctx, Trumail_Verify_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trumailClient) VerifyBatch(ctx context.Context, in *VerifyBatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamLookup], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Trumail_ServiceDesc.Streams[0], Trumail_VerifyBatch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[VerifyBatchRequest, StreamLookup]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Trumail_VerifyBatchClient = grpc.ServerStreamingClient[StreamLookup]
+
+// TrumailServer is the server API for Trumail service.
+// All implementations must embed UnimplementedTrumailServer
+// for forward compatibility.
+//
+// Trumail verifies email addresses. Every call requires the auth token in
+// the x-auth-token metadata when one is configured
+type TrumailServer interface {
+	// Verify verifies a single email address. Failed lookups return an
+	// INTERNAL status carrying the LookupError as a detail
+	Verify(context.Context, *VerifyRequest) (*Lookup, error)
+	// VerifyBatch verifies every email address in the request, streaming each
+	// lookup as soon as it completes
+	VerifyBatch(*VerifyBatchRequest, grpc.ServerStreamingServer[StreamLookup]) error
+	mustEmbedUnimplementedTrumailServer()
+}
+
+// UnimplementedTrumailServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTrumailServer struct{}
+
+func (UnimplementedTrumailServer) Verify(context.Context, *VerifyRequest) (*Lookup, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Verify not implemented")
+}
+func (UnimplementedTrumailServer) VerifyBatch(*VerifyBatchRequest, grpc.ServerStreamingServer[StreamLookup]) error {
+	return status.Errorf(codes.Unimplemented, "method VerifyBatch not implemented")
+}
+func (UnimplementedTrumailServer) mustEmbedUnimplementedTrumailServer() {}
+func (UnimplementedTrumailServer) testEmbeddedByValue()                 {}
+
+// UnsafeTrumailServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TrumailServer will
+// result in compilation errors.
+type UnsafeTrumailServer interface {
+	mustEmbedUnimplementedTrumailServer()
+}
+
+func RegisterTrumailServer(s grpc.ServiceRegistrar, srv TrumailServer) {
+	// If the following call pancis, it indicates UnimplementedTrumailServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Trumail_ServiceDesc, srv)
+}
+
+func _Trumail_Verify_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrumailServer).Verify(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Trumail_Verify_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrumailServer).Verify(ctx, req.(*VerifyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Trumail_VerifyBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(VerifyBatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TrumailServer).VerifyBatch(m, &grpc.GenericServerStream[VerifyBatchRequest, StreamLookup]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Trumail_VerifyBatchServer = grpc.ServerStreamingServer[StreamLookup]
+
+// Trumail_ServiceDesc is the grpc.ServiceDesc for Trumail service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Trumail_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "trumail.Trumail",
+	HandlerType: (*TrumailServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Verify",
+			Handler:    _Trumail_Verify_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "VerifyBatch",
+			Handler:       _Trumail_VerifyBatch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "trumail.proto",
+}
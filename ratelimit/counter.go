@@ -0,0 +1,173 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Counter stores the named counters tracking quota usage. Each counter
+// expires at the end of the window it counts
+type Counter interface {
+	// Get returns the value of the named counter, zero if it doesn't exist
+	// or has expired
+	Get(name string, now time.Time) (int, error)
+	// Add adds n to the named counter, creating it to expire at the passed
+	// time if it doesn't exist
+	Add(name string, n int, expires, now time.Time) error
+}
+
+// count is the value of a counter and when it expires
+type count struct {
+	Value   int       `json:"value"`
+	Expires time.Time `json:"expires"`
+}
+
+// Memory is a Counter holding every counter in memory, losing them on a
+// restart
+type Memory struct {
+	mu        sync.Mutex
+	counts    map[string]*count
+	nextPrune time.Time
+}
+
+// NewMemory generates a new, empty, Memory Counter
+func NewMemory() *Memory {
+	return &Memory{counts: make(map[string]*count)}
+}
+
+// Get returns the value of the named counter
+func (m *Memory) Get(name string, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c, ok := m.counts[name]; ok && now.Before(c.Expires) {
+		return c.Value, nil
+	}
+	return 0, nil
+}
+
+// Add adds n to the named counter, pruning expired counters at most once
+// a minute
+func (m *Memory) Add(name string, n int, expires, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.After(m.nextPrune) {
+		for k, c := range m.counts {
+			if !now.Before(c.Expires) {
+				delete(m.counts, k)
+			}
+		}
+		m.nextPrune = now.Add(time.Minute)
+	}
+	c, ok := m.counts[name]
+	if !ok || !now.Before(c.Expires) {
+		c = &count{Expires: expires}
+		m.counts[name] = c
+	}
+	c.Value += n
+	return nil
+}
+
+// countersBucket holds every counter by name
+var countersBucket = []byte("counters")
+
+// Bolt is a Counter persisting every counter in a local bolt database so
+// quotas survive a restart
+type Bolt struct{ db *bolt.DB }
+
+// OpenBolt opens, creating if needed, the Bolt Counter at the passed path,
+// deleting any expired counters
+func OpenBolt(path string) (*Bolt, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(countersBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Drop the counters of windows that ended while stopped
+	b := &Bolt{db}
+	if err := b.Prune(time.Now()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// Close closes the Counters database
+func (b *Bolt) Close() error { return b.db.Close() }
+
+// Ping checks the Counters database can be read
+func (b *Bolt) Ping() error {
+	return b.db.View(func(*bolt.Tx) error { return nil })
+}
+
+// Get returns the value of the named counter
+func (b *Bolt) Get(name string, now time.Time) (int, error) {
+	var c count
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(countersBucket).Get([]byte(name))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &c)
+	})
+	if err != nil || !now.Before(c.Expires) {
+		return 0, err
+	}
+	return c.Value, nil
+}
+
+// Add adds n to the named counter, restarting it if it has expired
+func (b *Bolt) Add(name string, n int, expires, now time.Time) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(countersBucket)
+		var c count
+		if v := bucket.Get([]byte(name)); v != nil {
+			if err := json.Unmarshal(v, &c); err != nil {
+				return err
+			}
+		}
+		if !now.Before(c.Expires) {
+			c = count{Expires: expires}
+		}
+		c.Value += n
+		v, err := json.Marshal(&c)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(name), v)
+	})
+}
+
+// Prune deletes every expired counter
+func (b *Bolt) Prune(now time.Time) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(countersBucket)
+		var expired [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			var c count
+			if err := json.Unmarshal(v, &c); err != nil || !now.Before(c.Expires) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCounter asserts a Counter adds to counters until they expire
+func testCounter(t *testing.T, c Counter) {
+	now := time.Now()
+	expires := now.Add(time.Hour)
+	assert.Nil(t, c.Add("a", 1, expires, now))
+	assert.Nil(t, c.Add("a", 2, expires, now))
+	assert.Nil(t, c.Add("b", 1, expires, now))
+	n, err := c.Get("a", now)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+
+	// Expired counters restart
+	later := expires.Add(time.Second)
+	n, err = c.Get("a", later)
+	assert.Nil(t, err)
+	assert.Zero(t, n)
+	assert.Nil(t, c.Add("a", 1, later.Add(time.Hour), later))
+	n, err = c.Get("a", later)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestMemory(t *testing.T) {
+	testCounter(t, NewMemory())
+}
+
+func TestBolt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limits.db")
+	b, err := OpenBolt(path)
+	assert.Nil(t, err)
+	assert.Nil(t, b.Ping())
+	testCounter(t, b)
+
+	// Counters survive reopening
+	now := time.Now()
+	assert.Nil(t, b.Add("c", 4, now.Add(time.Hour), now))
+	assert.Nil(t, b.Close())
+	b, err = OpenBolt(path)
+	assert.Nil(t, err)
+	defer b.Close()
+	n, err := b.Get("c", now)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+}
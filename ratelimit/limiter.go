@@ -0,0 +1,261 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sdwolfe32/trumail/keys"
+)
+
+// Config configures the limits enforced by a Limiter. Zero rates and
+// quotas are not enforced
+type Config struct {
+	KeyRate  float64 // The requests per second allowed per API key
+	KeyBurst int     // The requests an API key may make at once
+	IPRate   float64 // The requests per second allowed per client IP
+	IPBurst  int     // The requests a client IP may make at once
+	Daily    int     // The emails allowed per key, or IP, per UTC day
+	Monthly  int     // The emails allowed per key, or IP, per UTC month
+}
+
+// Result describes the most restrictive limit applied to a request
+type Result struct {
+	Allowed    bool
+	Reason     string        // Why the request was refused, if it was
+	Limit      int           // The requests allowed by the limit
+	Remaining  int           // The requests remaining before the limit
+	Reset      time.Duration // The time until the limit is fully reset
+	RetryAfter time.Duration // The time until a refused request may retry
+}
+
+// SetHeaders sets the X-RateLimit headers describing the Result, along
+// with Retry-After if the request was refused
+func (r *Result) SetHeaders(h http.Header) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(r.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(r.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(seconds(r.Reset)))
+	if !r.Allowed {
+		h.Set("Retry-After", strconv.Itoa(seconds(r.RetryAfter)))
+	}
+}
+
+// Message returns the message reported to a refused request
+func (r *Result) Message() string {
+	return fmt.Sprintf("%s exceeded, retry in %ds", r.Reason, seconds(r.RetryAfter))
+}
+
+// Limiter enforces token bucket rate limits per API key and client IP
+// along with daily and monthly quotas per key, or per IP for anonymous
+// requests. Buckets are held in memory while quotas are counted by a
+// Counter
+type Limiter struct {
+	config  Config
+	counter Counter
+	now     func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	nextPrune time.Time
+}
+
+// New generates a new Limiter enforcing the passed Config, counting
+// quotas with the passed Counter
+func New(config Config, counter Counter) *Limiter {
+	return &Limiter{config: config, counter: counter, now: time.Now,
+		buckets: make(map[string]*bucket)}
+}
+
+// SetConfig replaces the Config enforced by the Limiter. Buckets keep
+// their tokens and quotas keep their counts
+func (l *Limiter) SetConfig(config Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config = config
+}
+
+// limit is a single limit checked by Allow
+type limit struct {
+	reason    string
+	limit     int
+	remaining float64           // Before the request is counted
+	reset     time.Duration     // When refused
+	resetNext time.Duration     // Once the request is counted
+	retry     time.Duration     // Until a refused request may retry
+	take      func(n int) error // Counts n units of the request
+}
+
+// Allow counts a request made with the passed Key, nil if anonymous, from
+// the passed client IP against every limit, returning the Result of the
+// most restrictive. Refused requests aren't counted
+func (l *Limiter) Allow(key *keys.Key, ip string) (*Result, error) {
+	return l.allow(key, ip, 1, true)
+}
+
+// Charge counts n more units of an allowed request, such as the emails of
+// a batch beyond its first, against the daily and monthly quotas of the
+// passed Key, or client IP, returning the Result of the most restrictive.
+// Rate limits apply per request so aren't charged. Nothing is counted if
+// any quota can't cover all n units
+func (l *Limiter) Charge(key *keys.Key, ip string, n int) (*Result, error) {
+	if n < 1 {
+		return &Result{Allowed: true}, nil
+	}
+	return l.allow(key, ip, n, false)
+}
+
+// allow counts n units of a request against the quotas, and the rate
+// limits if rates, returning the Result of the most restrictive limit
+func (l *Limiter) allow(key *keys.Key, ip string, n int, rates bool) (*Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.prune(now)
+
+	// Gather every limit that applies to the request
+	var limits []*limit
+	subject := "ip:" + ip
+	daily, monthly := l.config.Daily, l.config.Monthly
+	if key != nil {
+		subject = "key:" + key.ID
+		if key.Quota.Daily > 0 {
+			daily = key.Quota.Daily
+		}
+		if key.Quota.Monthly > 0 {
+			monthly = key.Quota.Monthly
+		}
+		if rates && l.config.KeyRate > 0 {
+			limits = append(limits, l.bucket("key:"+key.ID, l.config.KeyRate,
+				l.config.KeyBurst, now))
+		}
+	}
+	if rates && l.config.IPRate > 0 {
+		limits = append(limits, l.bucket("ip:"+ip, l.config.IPRate, l.config.IPBurst, now))
+	}
+	if daily > 0 {
+		start := now.UTC().Truncate(24 * time.Hour)
+		q, err := l.quota("Daily quota", subject+":"+start.Format("2006-01-02"), daily,
+			start.AddDate(0, 0, 1), now)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, q)
+	}
+	if monthly > 0 {
+		y, m, _ := now.UTC().Date()
+		start := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+		q, err := l.quota("Monthly quota", subject+":"+start.Format("2006-01"), monthly,
+			start.AddDate(0, 1, 0), now)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, q)
+	}
+	if len(limits) == 0 {
+		return &Result{Allowed: true}, nil
+	}
+
+	// Refuse the request if any limit is exhausted, reporting the one
+	// freeing up last
+	var refused *limit
+	for _, lim := range limits {
+		if lim.remaining < float64(n) && (refused == nil || lim.retry > refused.retry) {
+			refused = lim
+		}
+	}
+	if refused != nil {
+		return &Result{Reason: refused.reason, Limit: refused.limit,
+			Remaining: max(int(refused.remaining), 0), Reset: refused.reset,
+			RetryAfter: refused.retry}, nil
+	}
+
+	// Count the request against every limit, reporting the one with the
+	// fewest requests remaining
+	res := &Result{Allowed: true, Remaining: math.MaxInt}
+	for _, lim := range limits {
+		if err := lim.take(n); err != nil {
+			return nil, err
+		}
+		if remaining := int(lim.remaining - float64(n)); remaining < res.Remaining {
+			res.Limit, res.Remaining, res.Reset = lim.limit, remaining, lim.resetNext
+		}
+	}
+	return res, nil
+}
+
+// quota returns the limit of a quota counted by the named counter, which
+// is reset at the passed time
+func (l *Limiter) quota(reason, name string, max int, reset, now time.Time) (*limit, error) {
+	used, err := l.counter.Get(name, now)
+	if err != nil {
+		return nil, err
+	}
+	return &limit{
+		reason:    reason,
+		limit:     max,
+		remaining: float64(max - used),
+		reset:     reset.Sub(now),
+		resetNext: reset.Sub(now),
+		retry:     reset.Sub(now),
+		take:      func(n int) error { return l.counter.Add(name, n, reset, now) },
+	}, nil
+}
+
+// bucket is a token bucket refilled at a constant rate
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// bucket returns the limit of the named token bucket, creating it full if
+// needed. A burst below one allows a single request at once
+func (l *Limiter) bucket(name string, rate float64, burst int, now time.Time) *limit {
+	if burst < 1 {
+		burst = 1
+	}
+	b, ok := l.buckets[name]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		l.buckets[name] = b
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	refill := func(tokens float64) time.Duration {
+		return time.Duration(tokens / rate * float64(time.Second))
+	}
+	return &limit{
+		reason:    "Rate limit",
+		limit:     burst,
+		remaining: b.tokens,
+		reset:     refill(float64(burst) - b.tokens),
+		resetNext: refill(float64(burst) - b.tokens + 1),
+		retry:     refill(1 - b.tokens),
+		take:      func(n int) error { b.tokens -= float64(n); return nil },
+	}
+}
+
+// prune drops the buckets that have refilled, at most once a minute
+func (l *Limiter) prune(now time.Time) {
+	if now.Before(l.nextPrune) {
+		return
+	}
+	for name, b := range l.buckets {
+		rate, burst := l.config.IPRate, l.config.IPBurst
+		if strings.HasPrefix(name, "key:") {
+			rate, burst = l.config.KeyRate, l.config.KeyBurst
+		}
+		if b.tokens+now.Sub(b.last).Seconds()*rate >= float64(burst) {
+			delete(l.buckets, name)
+		}
+	}
+	l.nextPrune = now.Add(time.Minute)
+}
+
+// seconds rounds a duration up to whole seconds
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
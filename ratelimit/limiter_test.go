@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/stretchr/testify/assert"
+)
+
+// testLimiter generates a Limiter whose clock is advanced by the returned
+// function
+func testLimiter(config Config, counter Counter) (*Limiter, func(time.Duration)) {
+	now := time.Date(2024, 1, 31, 23, 59, 0, 0, time.UTC)
+	l := New(config, counter)
+	l.now = func() time.Time { return now }
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestLimiterBuckets(t *testing.T) {
+	l, advance := testLimiter(Config{KeyRate: 1, KeyBurst: 2, IPRate: 10, IPBurst: 10}, NewMemory())
+	key := &keys.Key{ID: "a"}
+
+	// The burst is allowed at once
+	for remaining := 1; remaining >= 0; remaining-- {
+		res, err := l.Allow(key, "1.2.3.4")
+		assert.Nil(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 2, res.Limit)
+		assert.Equal(t, remaining, res.Remaining)
+	}
+
+	// Then the key is refused until a token is refilled
+	res, err := l.Allow(key, "1.2.3.4")
+	assert.Nil(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, "Rate limit", res.Reason)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 2*time.Second, res.Reset)
+
+	// Other keys only share the bucket of the IP
+	res, err = l.Allow(&keys.Key{ID: "b"}, "1.2.3.4")
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+
+	advance(time.Second)
+	res, err = l.Allow(key, "1.2.3.4")
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+}
+
+func TestLimiterQuotas(t *testing.T) {
+	l, advance := testLimiter(Config{Daily: 5, Monthly: 3}, NewMemory())
+
+	// Keys may raise the defaults
+	key := &keys.Key{ID: "a", Quota: keys.Quota{Daily: 2}}
+	for i := 0; i < 2; i++ {
+		res, err := l.Allow(key, "1.2.3.4")
+		assert.Nil(t, err)
+		assert.True(t, res.Allowed)
+	}
+	res, err := l.Allow(key, "1.2.3.4")
+	assert.Nil(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, "Daily quota", res.Reason)
+	assert.Equal(t, time.Minute, res.RetryAfter)
+
+	// The daily quota resets at midnight, the monthly one is exhausted first
+	advance(time.Minute)
+	res, err = l.Allow(key, "1.2.3.4")
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Limit)
+	assert.Equal(t, 1, res.Remaining)
+
+	// Anonymous requests are counted per IP
+	for i := 0; i < 3; i++ {
+		res, err = l.Allow(nil, "5.6.7.8")
+		assert.Nil(t, err)
+		assert.True(t, res.Allowed)
+	}
+	res, err = l.Allow(nil, "5.6.7.8")
+	assert.Nil(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, "Monthly quota", res.Reason)
+}
+
+func TestLimiterCharge(t *testing.T) {
+	l, _ := testLimiter(Config{KeyRate: 1, KeyBurst: 1, Daily: 10}, NewMemory())
+	key := &keys.Key{ID: "a"}
+	res, err := l.Allow(key, "1.2.3.4")
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+
+	// Charges only count against the quotas
+	res, err = l.Charge(key, "1.2.3.4", 6)
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 10, res.Limit)
+	assert.Equal(t, 3, res.Remaining)
+
+	// Charges the quotas can't cover aren't counted
+	res, err = l.Charge(key, "1.2.3.4", 4)
+	assert.Nil(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, "Daily quota", res.Reason)
+	assert.Equal(t, 3, res.Remaining)
+	res, err = l.Charge(key, "1.2.3.4", 3)
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	l, _ := testLimiter(Config{}, NewMemory())
+	res, err := l.Allow(nil, "1.2.3.4")
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+	assert.Zero(t, res.Limit)
+}
+
+func TestLimiterSetConfig(t *testing.T) {
+	l, _ := testLimiter(Config{}, NewMemory())
+	res, err := l.Allow(nil, "1.2.3.4")
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+
+	// Quotas apply from the next request
+	l.SetConfig(Config{Daily: 2})
+	res, err = l.Allow(nil, "1.2.3.4")
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Limit)
+	assert.Equal(t, 1, res.Remaining)
+}
+
+func TestResultHeaders(t *testing.T) {
+	h := make(http.Header)
+	res := &Result{Reason: "Rate limit", Limit: 10, Reset: 1500 * time.Millisecond,
+		RetryAfter: 200 * time.Millisecond}
+	res.SetHeaders(h)
+	assert.Equal(t, "10", h.Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", h.Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "2", h.Get("X-RateLimit-Reset"))
+	assert.Equal(t, "1", h.Get("Retry-After"))
+	assert.Equal(t, "Rate limit exceeded, retry in 1s", res.Message())
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/keys"
+)
+
+// ChargeKey is the echo context key holding the func(n int) error set by
+// Middleware, which counts n more emails verified by a request against its
+// quotas and returns the error to respond with once one is exhausted
+const ChargeKey = "trumail.charge"
+
+// Middleware returns a middleware counting each request against the
+// limits of the passed Limiter, responding 429 Too Many Requests once one
+// is exhausted. Requests are attributed to the Key carried by their
+// context, so it must follow keys.Middleware, and to their client IP,
+// taken from the X-Forwarded-For or X-Real-IP headers if trustProxy.
+// Handlers verifying more than one email charge the rest against the
+// quotas through the func held under ChargeKey
+func Middleware(l *Limiter, trustProxy bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key, ip := keys.FromContext(c.Request().Context()), clientIP(c, trustProxy)
+			res, err := l.Allow(key, ip)
+			if err = respond(c, res, err); err != nil {
+				return err
+			}
+			c.Set(ChargeKey, func(n int) error {
+				res, err := l.Charge(key, ip, n)
+				return respond(c, res, err)
+			})
+			return next(c)
+		}
+	}
+}
+
+// respond sets the headers describing the Result of counting a request,
+// returning 429 Too Many Requests if it was refused
+func respond(c echo.Context, res *Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if res.Limit > 0 {
+		res.SetHeaders(c.Response().Header())
+	}
+	if !res.Allowed {
+		return echo.NewHTTPError(http.StatusTooManyRequests, res.Message())
+	}
+	return nil
+}
+
+// clientIP returns the IP address of the client making a request
+func clientIP(c echo.Context, trustProxy bool) string {
+	if trustProxy {
+		return c.RealIP()
+	}
+	ip, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+	if err != nil {
+		return c.Request().RemoteAddr
+	}
+	return ip
+}
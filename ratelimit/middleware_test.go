@@ -0,0 +1,99 @@
+package ratelimit_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/sdwolfe32/trumail/api"
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/ratelimit"
+	"github.com/sdwolfe32/trumail/verifier"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = api.ErrorHandler
+	l := ratelimit.New(ratelimit.Config{IPRate: 1, IPBurst: 1}, ratelimit.NewMemory())
+	e.GET("/v1/:format/:email", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, keys.Middleware(keys.Chain(keys.Static("")), keys.ScopeLookup, false), ratelimit.Middleware(l, true))
+
+	request := func(format, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/"+format+"/a@b.c", nil)
+		req.Header.Set(echo.HeaderXForwardedFor, ip)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("json", "1.1.1.1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+
+	// Refused requests are encoded in the requested format
+	for format, contentType := range map[string]string{
+		"json": echo.MIMEApplicationJSONCharsetUTF8,
+		"xml":  echo.MIMEApplicationXMLCharsetUTF8,
+		"csv":  api.MIMETextCSV + "; charset=UTF-8",
+		"yaml": api.MIMEApplicationYAML,
+	} {
+		rec = request(format, "1.1.1.1")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code, format)
+		assert.Equal(t, contentType, rec.Header().Get(echo.HeaderContentType), format)
+		assert.Contains(t, rec.Body.String(), "Rate limit exceeded", format)
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"), format)
+	}
+
+	// Each client IP has its own bucket
+	assert.Equal(t, http.StatusOK, request("json", "2.2.2.2").Code)
+}
+
+func TestMiddlewareCharge(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = api.ErrorHandler
+	l := ratelimit.New(ratelimit.Config{Daily: 15}, ratelimit.NewMemory())
+	limit := ratelimit.Middleware(l, false)
+	auth := keys.Middleware(keys.Chain(keys.Static("")), keys.ScopeLookup, false)
+	e.POST("/v1/batch/:format", api.BatchHandler(verifier.NewVerifier("localhost",
+		"admin@localhost"), 10, 2), auth, limit)
+	e.GET("/v1/:format/:email", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, auth, limit)
+
+	emails := make([]string, 10)
+	for i := range emails {
+		emails[i] = fmt.Sprintf(`"invalid%d"`, i)
+	}
+	batch := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/batch/json",
+			strings.NewReader(`{"emails":[`+strings.Join(emails, ",")+`]}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// A batch of 10 emails uses 10 units of the quota
+	rec := batch()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "15", rec.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "5", rec.Header().Get("X-RateLimit-Remaining"))
+
+	// Batches the quota can't cover are refused, using only the request
+	rec = batch()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Daily quota exceeded")
+	assert.Equal(t, "4", rec.Header().Get("X-RateLimit-Remaining"))
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/json/a@b.c", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "3", rec.Header().Get("X-RateLimit-Remaining"))
+}
@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"github.com/labstack/echo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware returns a middleware that starts a server span for every
+// request, continuing any trace propagated on the request headers
+func Middleware() echo.MiddlewareFunc {
+	tracer := otel.Tracer("github.com/sdwolfe32/trumail/tracing")
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// Extract the parent trace and start the server span
+			req := c.Request()
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(),
+				propagation.HeaderCarrier(req.Header))
+			ctx, span := tracer.Start(ctx, req.Method+" "+c.Path(),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.method", req.Method),
+					attribute.String("http.route", c.Path()),
+				))
+			defer span.End()
+			c.SetRequest(req.WithContext(ctx))
+
+			// Handle the request and record the resulting status
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			status := c.Response().Status
+			span.SetAttributes(attribute.Int("http.status_code", status))
+			if status >= 500 {
+				span.SetStatus(codes.Error, "")
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	// ExporterNone disables the exporting of spans
+	ExporterNone = "none"
+	// ExporterStdout writes spans to stdout, useful for local testing
+	ExporterStdout = "stdout"
+	// ExporterOTLP sends spans to an OTLP/HTTP collector configured via
+	// the standard OTEL_EXPORTER_OTLP_* environment variables
+	ExporterOTLP = "otlp"
+)
+
+// ErrUnsupportedExporter is thrown when an unknown exporter is requested
+var ErrUnsupportedExporter = errors.New("Unsupported trace exporter specified")
+
+// Setup installs the W3C trace context and baggage propagators along with
+// a global TracerProvider sending spans to the named exporter. The returned
+// func flushes any buffered spans and shuts the provider down
+func Setup(exporter, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	// Generate the requested exporter
+	var exp sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(exporter) {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		exp, err = otlptracehttp.New(context.Background())
+	default:
+		return nil, ErrUnsupportedExporter
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Install the TracerProvider globally
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
@@ -0,0 +1,65 @@
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidRange is thrown when exporting an invalid range of dates
+var ErrInvalidRange = errors.New("Invalid date range, from and to must be " +
+	DateFormat + " dates with from no later than to")
+
+// ParseRange parses the first and last dates of an export, defaulting to
+// the first day of the current UTC month and today respectively
+func ParseRange(from, to string, now time.Time) (time.Time, time.Time, error) {
+	now = now.UTC()
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	last := now.Truncate(24 * time.Hour)
+	var err error
+	if from != "" {
+		if first, err = time.Parse(DateFormat, from); err != nil {
+			return first, last, ErrInvalidRange
+		}
+	}
+	if to != "" {
+		if last, err = time.Parse(DateFormat, to); err != nil {
+			return first, last, ErrInvalidRange
+		}
+	}
+	if last.Before(first) {
+		return first, last, ErrInvalidRange
+	}
+	return first, last, nil
+}
+
+// csvHeader is the header row of a CSV export, naming each column as its
+// JSON field
+var csvHeader = []string{"date", "keyId", "keyName", "lookups", "deliverable",
+	"undeliverable", "unknown", "live", "cached", "batchRows"}
+
+// WriteCSV writes the passed Records as a CSV table with a header row
+func WriteCSV(w io.Writer, records []*Record) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, r := range records {
+		row := []string{r.Date, r.KeyID, r.KeyName}
+		for _, n := range []int{r.Lookups, r.Deliverable, r.Undeliverable, r.Unknown,
+			r.Live, r.Cached, r.BatchRows} {
+			row = append(row, strconv.Itoa(n))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the passed Records as an indented JSON array
+func WriteJSON(w io.Writer, records []*Record) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
@@ -0,0 +1,126 @@
+package usage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/verifier"
+)
+
+// Recorder is a verifier.Observer counting the lookups of each API key
+// per day. Counts are buffered in memory and added to the Store
+// periodically, and once more when the Recorder is closed
+type Recorder struct {
+	store *Store
+	now   func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]*Record // The counts yet to be stored by ID
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRecorder generates a new Recorder adding its counts to the passed
+// Store at the passed interval
+func NewRecorder(s *Store, interval time.Duration) *Recorder {
+	r := &Recorder{store: s, now: time.Now, pending: make(map[string]*Record),
+		stop: make(chan struct{}), done: make(chan struct{})}
+	go r.run(interval)
+	return r
+}
+
+// ObserveLookup counts a lookup towards the usage of the API key carried
+// by its context
+func (r *Recorder) ObserveLookup(ctx context.Context, l *verifier.Lookup, err error, _ time.Duration) {
+	count := &Record{Date: r.now().UTC().Format(DateFormat), Lookups: 1}
+	if key := keys.FromContext(ctx); key != nil {
+		count.KeyID, count.KeyName = key.ID, key.Name
+	}
+	switch status, _ := verifier.Outcome(l, err); status {
+	case verifier.StatusDeliverable:
+		count.Deliverable = 1
+	case verifier.StatusUndeliverable:
+		count.Undeliverable = 1
+	default:
+		count.Unknown = 1
+	}
+	if _, dialed := l.Timings[verifier.PhaseDial]; dialed {
+		count.Live = 1
+	} else {
+		count.Cached = 1
+	}
+	if verifier.InBatch(ctx) {
+		count.BatchRows = 1
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := string(count.id())
+	if pending, ok := r.pending[id]; ok {
+		pending.add(count)
+	} else {
+		r.pending[id] = count
+	}
+}
+
+// ObservePhase is a no-op, usage is only counted per lookup
+func (r *Recorder) ObservePhase(context.Context, verifier.PhaseEvent) {}
+
+// ObserveSession is a no-op, usage is only counted per lookup
+func (r *Recorder) ObserveSession(int) {}
+
+// Flush adds the pending counts to the Store. Counts that can't be stored
+// are kept pending and retried on the next Flush
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	records := make([]*Record, 0, len(r.pending))
+	for _, record := range r.pending {
+		records = append(records, record)
+	}
+	r.pending = make(map[string]*Record)
+	r.mu.Unlock()
+	if len(records) == 0 {
+		return nil
+	}
+
+	err := r.store.Add(records)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		for _, record := range records {
+			id := string(record.id())
+			if pending, ok := r.pending[id]; ok {
+				record.add(pending)
+			}
+			r.pending[id] = record
+		}
+	}
+	return err
+}
+
+// Close stops the periodic flushes and adds any pending counts to the
+// Store
+func (r *Recorder) Close() error {
+	close(r.stop)
+	<-r.done
+	return r.Flush()
+}
+
+// run flushes the pending counts at the passed interval until the
+// Recorder is closed
+func (r *Recorder) run(interval time.Duration) {
+	defer close(r.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.Flush()
+		case <-r.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package usage records the lookups performed by each API key per day so
+// verification volume can be exported for billing
+package usage
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DateFormat is the format of the UTC day a Record counts
+const DateFormat = "2006-01-02"
+
+// usageBucket holds every Record keyed by date and key ID
+var usageBucket = []byte("usage")
+
+// Record is the usage of a single API key on a single UTC day. Anonymous
+// lookups are recorded under an empty key
+type Record struct {
+	Date    string `json:"date" xml:"date"`
+	KeyID   string `json:"keyId" xml:"keyId"`
+	KeyName string `json:"keyName" xml:"keyName"`
+
+	// Lookups is the number of lookups performed, each of which is also
+	// counted by its status
+	Lookups       int `json:"lookups" xml:"lookups"`
+	Deliverable   int `json:"deliverable" xml:"deliverable"`
+	Undeliverable int `json:"undeliverable" xml:"undeliverable"`
+	Unknown       int `json:"unknown" xml:"unknown"`
+	// Live is the number of lookups that performed a live SMTP check, while
+	// Cached is the number answered without contacting a mail server
+	Live   int `json:"live" xml:"live"`
+	Cached int `json:"cached" xml:"cached"`
+	// BatchRows is the number of lookups that were rows of a batch or job
+	BatchRows int `json:"batchRows" xml:"batchRows"`
+}
+
+// add adds the counts of another Record to the Record
+func (r *Record) add(o *Record) {
+	if o.KeyName != "" {
+		r.KeyName = o.KeyName
+	}
+	r.Lookups += o.Lookups
+	r.Deliverable += o.Deliverable
+	r.Undeliverable += o.Undeliverable
+	r.Unknown += o.Unknown
+	r.Live += o.Live
+	r.Cached += o.Cached
+	r.BatchRows += o.BatchRows
+}
+
+// id returns the key a Record is stored under, ordering Records by date
+func (r *Record) id() []byte {
+	return []byte(r.Date + "/" + r.KeyID)
+}
+
+// Store persists Records in a local bolt database. The database is only
+// held open while being read or written so usage can be exported by
+// another process while the server runs
+type Store struct{ path string }
+
+// Open opens, creating if needed, the Store at the passed path
+func Open(path string) (*Store, error) {
+	s := &Store{path}
+	if err := s.update(func(*bolt.Bucket) error { return nil }); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add adds the counts of the passed Records to those stored for the same
+// date and key
+func (s *Store) Add(records []*Record) error {
+	return s.update(func(b *bolt.Bucket) error {
+		for _, r := range records {
+			stored := &Record{Date: r.Date, KeyID: r.KeyID}
+			if v := b.Get(r.id()); v != nil {
+				if err := json.Unmarshal(v, stored); err != nil {
+					return err
+				}
+			}
+			stored.add(r)
+			v, err := json.Marshal(stored)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(r.id(), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Usage returns the Records of every day from from to to inclusive, in
+// order of date then key ID. Only the Records of the passed key are
+// returned unless keyID is empty
+func (s *Store) Usage(from, to time.Time, keyID string) ([]*Record, error) {
+	first, last := from.UTC().Format(DateFormat), to.UTC().Format(DateFormat)
+	records := []*Record{}
+	err := s.view(func(b *bolt.Bucket) error {
+		c := b.Cursor()
+		for k, v := c.Seek([]byte(first)); k != nil && string(k[:len(DateFormat)]) <= last; k, v = c.Next() {
+			var r Record
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			if keyID == "" || r.KeyID == keyID {
+				records = append(records, &r)
+			}
+		}
+		return nil
+	})
+	return records, err
+}
+
+// Ping checks the database can be opened and read
+func (s *Store) Ping() error {
+	return s.view(func(*bolt.Bucket) error { return nil })
+}
+
+// view opens the database read only for the duration of fn
+func (s *Store) view(fn func(*bolt.Bucket) error) error {
+	db, err := bolt.Open(s.path, 0600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error { return fn(tx.Bucket(usageBucket)) })
+}
+
+// update opens the database for the duration of fn, creating the usage
+// bucket if needed
+func (s *Store) update(fn func(*bolt.Bucket) error) error {
+	db, err := bolt.Open(s.path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(usageBucket)
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+}
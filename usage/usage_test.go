@@ -0,0 +1,112 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sdwolfe32/trumail/keys"
+	"github.com/sdwolfe32/trumail/verifier"
+	"github.com/stretchr/testify/assert"
+)
+
+// testStore opens a Store in a temporary directory
+func testStore(t *testing.T) *Store {
+	s, err := Open(filepath.Join(t.TempDir(), "usage.db"))
+	assert.Nil(t, err)
+	return s
+}
+
+func TestStoreUsage(t *testing.T) {
+	s := testStore(t)
+	assert.Nil(t, s.Ping())
+	assert.Nil(t, s.Add([]*Record{
+		{Date: "2024-01-01", KeyID: "a", KeyName: "reporting", Lookups: 2, Deliverable: 2, Live: 2},
+		{Date: "2024-01-02", KeyID: "b", Lookups: 1, Unknown: 1, Cached: 1},
+		{Date: "2024-01-03", KeyID: "a", Lookups: 1, Undeliverable: 1, Live: 1},
+	}))
+	assert.Nil(t, s.Add([]*Record{{Date: "2024-01-01", KeyID: "a", Lookups: 1, Deliverable: 1,
+		Live: 1, BatchRows: 1}}))
+
+	// Counts are added to those of the same day and key
+	records, err := s.Usage(date("2024-01-01"), date("2024-01-02"), "")
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, &Record{Date: "2024-01-01", KeyID: "a", KeyName: "reporting", Lookups: 3,
+		Deliverable: 3, Live: 3, BatchRows: 1}, records[0])
+	assert.Equal(t, "b", records[1].KeyID)
+
+	// Records may be filtered by key
+	records, err = s.Usage(date("2024-01-01"), date("2024-01-31"), "a")
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "2024-01-03", records[1].Date)
+
+	records, err = s.Usage(date("2024-02-01"), date("2024-02-28"), "")
+	assert.Nil(t, err)
+	assert.Empty(t, records)
+}
+
+func TestRecorder(t *testing.T) {
+	s := testStore(t)
+	r := NewRecorder(s, time.Hour)
+	r.now = func() time.Time { return date("2024-01-01").Add(time.Hour) }
+
+	ctx := keys.WithKey(context.Background(), &keys.Key{ID: "a", Name: "reporting"})
+	v := verifier.NewVerifier("localhost", "admin@localhost")
+	v.SetObserver(r)
+	v.VerifyContext(ctx, "invalid")
+	for range v.VerifyBatch(ctx, []string{"one", "two"}, verifier.DefaultOptions, 1) {
+	}
+	v.Verify("anonymous")
+	r.ObserveLookup(ctx, &verifier.Lookup{ValidFormat: true, HostExists: true, Deliverable: true,
+		Timings: map[string]time.Duration{verifier.PhaseDial: time.Millisecond}}, nil, 0)
+
+	// Nothing is stored until flushed
+	records, err := s.Usage(date("2024-01-01"), date("2024-01-01"), "")
+	assert.Nil(t, err)
+	assert.Empty(t, records)
+
+	assert.Nil(t, r.Close())
+	records, err = s.Usage(date("2024-01-01"), date("2024-01-01"), "")
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, &Record{Date: "2024-01-01", Lookups: 1, Undeliverable: 1, Cached: 1},
+		records[0])
+	assert.Equal(t, &Record{Date: "2024-01-01", KeyID: "a", KeyName: "reporting", Lookups: 4,
+		Deliverable: 1, Undeliverable: 3, Live: 1, Cached: 3, BatchRows: 2}, records[1])
+}
+
+func TestParseRange(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	from, to, err := ParseRange("", "", now)
+	assert.Nil(t, err)
+	assert.Equal(t, date("2024-03-01"), from)
+	assert.Equal(t, date("2024-03-15"), to)
+
+	from, to, err = ParseRange("2024-01-01", "2024-01-31", now)
+	assert.Nil(t, err)
+	assert.Equal(t, date("2024-01-01"), from)
+	assert.Equal(t, date("2024-01-31"), to)
+
+	for _, r := range [][2]string{{"yesterday", ""}, {"", "2024-13-01"}, {"2024-02-01", "2024-01-01"}} {
+		_, _, err = ParseRange(r[0], r[1], now)
+		assert.Equal(t, ErrInvalidRange, err, r)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, WriteCSV(&buf, []*Record{{Date: "2024-01-01", KeyID: "a", KeyName: "reporting",
+		Lookups: 2, Deliverable: 1, Unknown: 1, Live: 2, BatchRows: 2}}))
+	assert.Equal(t, "date,keyId,keyName,lookups,deliverable,undeliverable,unknown,live,cached,batchRows\n"+
+		"2024-01-01,a,reporting,2,1,0,1,2,0,2\n", buf.String())
+}
+
+// date parses a date in the DateFormat
+func date(s string) time.Time {
+	t, _ := time.Parse(DateFormat, s)
+	return t
+}
@@ -20,8 +20,13 @@ type Address struct{ Address, Username, Domain, MD5Hash string }
 // ParseAddress attempts to parse an email address and return it in the form
 // of an Address struct pointer - domain case insensitive
 func ParseAddress(email string) (*Address, error) {
+	return parseAddress(unescape(email))
+}
+
+// parseAddress parses an email address that has already been unescaped
+func parseAddress(email string) (*Address, error) {
 	// Parses the address with the internal go mail address parser
-	a, err := mail.ParseAddress(unescape(email))
+	a, err := mail.ParseAddress(email)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,199 @@
+package verifier
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Result is the outcome of verifying a single address of a batch
+type Result struct {
+	Index  int // The position of the address in the batch
+	Lookup *Lookup
+	Err    error
+}
+
+// batchItem is a parsed address awaiting verification in a batch
+type batchItem struct {
+	index   int
+	lookup  *Lookup
+	timings *timingObserver
+}
+
+// domainGroup is the addresses of a batch sharing a domain that are
+// verified over a single SMTP session
+type domainGroup struct {
+	domain string
+	items  []*batchItem
+}
+
+// batchKey is the context key marking the lookups of a batch
+type batchKey struct{}
+
+// InBatch reports whether the context of a lookup, as passed to an
+// Observer, is that of a VerifyBatch
+func InBatch(ctx context.Context) bool {
+	inBatch, _ := ctx.Value(batchKey{}).(bool)
+	return inBatch
+}
+
+// VerifyBatch verifies every passed address using at most workers
+// concurrent SMTP sessions. Addresses sharing a domain are verified over a
+// single session, or one per SetSessionRCPTs addresses, so the MX lookup
+// and catch-all check are performed once per session. A Result is sent
+// for every address as it completes and the returned channel is closed
+// once all have been sent. Addresses that haven't been verified when the
+// context is cancelled fail with its error, and those Shutdown aborts fail
+// with ErrShuttingDown
+func (v *Verifier) VerifyBatch(ctx context.Context, emails []string, opts Options,
+	workers int) <-chan Result {
+	ctx, span := tracer.Start(ctx, "verifier.VerifyBatch", trace.WithAttributes(
+		attribute.Int("trumail.batch_size", len(emails))))
+	ctx = context.WithValue(ctx, batchKey{}, true)
+	results := make(chan Result, len(emails))
+	start := time.Now()
+	v.begin()
+
+	// Parse every address, grouping those with a valid format by domain
+	var groups []*domainGroup
+	byDomain := make(map[string]*domainGroup)
+	for i, email := range emails {
+		timings := newTimingObserver()
+		it := &batchItem{i, parse(ctx, email, opts, MultiObserver(v.observer, timings)), timings}
+		if !it.lookup.ValidFormat {
+			v.complete(ctx, it, nil, start, nil, results)
+			continue
+		}
+		g, ok := byDomain[it.lookup.Domain]
+		if !ok {
+			g = &domainGroup{domain: it.lookup.Domain}
+			byDomain[g.domain] = g
+			groups = append(groups, g)
+		}
+		g.items = append(g.items, it)
+	}
+
+	// Verify each session on a bounded pool of workers
+	groups = splitGroups(groups, v.sessionRCPTs)
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(groups) {
+		workers = len(groups)
+	}
+	queue := make(chan *domainGroup, len(groups))
+	for _, g := range groups {
+		queue <- g
+	}
+	close(queue)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for g := range queue {
+				v.verifyDomain(ctx, g, opts, start, results)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		span.End()
+		close(results)
+		v.end()
+	}()
+	return results
+}
+
+// splitGroups splits the domain groups holding more than n addresses into
+// groups of at most n, in order
+func splitGroups(groups []*domainGroup, n int) []*domainGroup {
+	var split []*domainGroup
+	for _, g := range groups {
+		for items := g.items; len(items) > 0; {
+			size := min(n, len(items))
+			split = append(split, &domainGroup{domain: g.domain, items: items[:size]})
+			items = items[size:]
+		}
+	}
+	return split
+}
+
+// verifyDomain verifies every address in the group over a single SMTP
+// session, sending a Result for each as it completes
+func (v *Verifier) verifyDomain(ctx context.Context, g *domainGroup, opts Options,
+	start time.Time, results chan<- Result) {
+	ctx, span := tracer.Start(ctx, "verifier.verifyDomain", trace.WithAttributes(
+		attribute.String("trumail.domain", g.domain),
+		attribute.Int("trumail.batch_size", len(g.items))))
+	defer span.End()
+
+	// Fail every address if the batch was cancelled or aborted before it
+	// was reached
+	if err := v.cancelled(ctx); err != nil {
+		for _, it := range g.items {
+			v.complete(ctx, it, nil, start, err, results)
+		}
+		return
+	}
+
+	// Attempt to form an SMTP Connection, timing it for every address
+	shared := newTimingObserver()
+	obs := MultiObserver(v.observer, shared)
+	del, err := newDeliverabler(ctx, g.domain, v.hostname, v.sourceAddr,
+		opts.Timeout, obs, v.abort)
+	if err != nil {
+		err = v.lookupError(err)
+		for _, it := range g.items {
+			v.complete(ctx, it, shared, start, err, results)
+		}
+		return
+	}
+	defer del.Close()
+
+	// Check the catch-all once then the deliverability of each address
+	catchAll := !opts.SkipCatchAll && del.HasCatchAll(opts.Retries)
+	for _, it := range g.items {
+		if err := v.cancelled(ctx); err != nil {
+			v.complete(ctx, it, shared, start, err, results)
+			continue
+		}
+		del.observer = MultiObserver(v.observer, it.timings)
+		err := deliver(del, it.lookup, catchAll, opts.Retries)
+		if v.aborted() {
+			err = errShuttingDown()
+		}
+		v.complete(ctx, it, shared, start, err, results)
+	}
+	del.observer = obs
+}
+
+// cancelled returns the error addresses of a batch fail with before being
+// verified, if its context was cancelled or the lookups in flight aborted
+func (v *Verifier) cancelled(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if v.aborted() {
+		return errShuttingDown()
+	}
+	return nil
+}
+
+// complete records the timings of a batch item, reports it to the Observer
+// and sends its Result
+func (v *Verifier) complete(ctx context.Context, it *batchItem, shared *timingObserver,
+	start time.Time, err error, results chan<- Result) {
+	l := it.lookup
+	l.Timings, l.Took = it.timings.snapshot(), time.Since(start)
+	if shared != nil {
+		for phase, took := range shared.snapshot() {
+			l.Timings[phase] += took
+		}
+	}
+	v.observer.ObserveLookup(ctx, l, err, l.Took)
+	results <- Result{it.index, l, err}
+}
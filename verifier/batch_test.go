@@ -0,0 +1,88 @@
+package verifier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// collect gathers the Results of a batch in the order of its addresses
+func collect(results <-chan Result, n int) []Result {
+	ordered := make([]Result, n)
+	for r := range results {
+		ordered[r.Index] = r
+	}
+	return ordered
+}
+
+func TestVerifyBatchInvalid(t *testing.T) {
+	v := NewVerifier("localhost", "admin@localhost")
+	emails := []string{"one", "two", "three"}
+	results := collect(v.VerifyBatch(context.Background(), emails, DefaultOptions, 2), 3)
+	for i, r := range results {
+		assert.Equal(t, i, r.Index)
+		assert.Equal(t, emails[i], r.Lookup.Address.Address)
+		assert.False(t, r.Lookup.ValidFormat)
+		assert.Nil(t, r.Err)
+	}
+}
+
+func TestVerifyBatchCancelled(t *testing.T) {
+	v := NewVerifier("localhost", "admin@localhost")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	emails := []string{"a@example.com", "invalid", "b@example.com", "c@example.org"}
+	results := collect(v.VerifyBatch(ctx, emails, DefaultOptions, 4), 4)
+	assert.Equal(t, context.Canceled, results[0].Err)
+	assert.Nil(t, results[1].Err)
+	assert.False(t, results[1].Lookup.ValidFormat)
+	assert.Equal(t, context.Canceled, results[2].Err)
+	assert.Equal(t, "example.com", results[2].Lookup.Domain)
+	assert.Equal(t, context.Canceled, results[3].Err)
+}
+
+func TestSplitGroups(t *testing.T) {
+	items := func(n int) []*batchItem {
+		its := make([]*batchItem, n)
+		for i := range its {
+			its[i] = &batchItem{index: i}
+		}
+		return its
+	}
+	groups := splitGroups([]*domainGroup{
+		{domain: "example.com", items: items(5)},
+		{domain: "example.org", items: items(1)},
+	}, 2)
+
+	// Each domain is split into sessions of at most two addresses in order
+	var sizes []int
+	for _, g := range groups {
+		sizes = append(sizes, len(g.items))
+	}
+	assert.Equal(t, []int{2, 2, 1, 1}, sizes)
+	assert.Equal(t, "example.com", groups[2].domain)
+	assert.Equal(t, 4, groups[2].items[0].index)
+	assert.Equal(t, "example.org", groups[3].domain)
+}
+
+// batchObserver records whether each lookup reported was part of a batch
+type batchObserver struct {
+	nopObserver
+	inBatch []bool
+}
+
+func (o *batchObserver) ObserveLookup(ctx context.Context, _ *Lookup, _ error, _ time.Duration) {
+	o.inBatch = append(o.inBatch, InBatch(ctx))
+}
+
+func TestInBatch(t *testing.T) {
+	v := NewVerifier("localhost", "admin@localhost")
+	obs := &batchObserver{}
+	v.SetObserver(obs)
+	collect(v.VerifyBatch(context.Background(), []string{"one"}, DefaultOptions, 1), 1)
+	v.Verify("two")
+	assert.Equal(t, []bool{true, false}, obs.inBatch)
+}
@@ -1,48 +1,134 @@
 package verifier
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/rand"
 	"net"
 	"net/smtp"
+	"strings"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/idna"
 )
 
+// quitTimeout is how long an aborted session is allowed to send QUIT
+const quitTimeout = 5 * time.Second
+
+// errAborted is returned when dialing a mail server is aborted
+var errAborted = errors.New("Aborted connecting to mail-exchanger")
+
 // Deliverabler contains the context and smtp.Client needed to check
 // email address deliverability
 type Deliverabler struct {
+	ctx                          context.Context // The context of the lookup, used for tracing
 	client                       *smtp.Client
 	domain, hostname, sourceAddr string
+	timeout                      time.Duration
+	host                         string // The MX host connected to
+	observer                     Observer
+	abort                        <-chan struct{} // Closed to interrupt the session
+	watcher                      *watcher
+	closed                       bool
 }
 
 // NewDeliverabler generates a new Deliverabler reference
 func NewDeliverabler(domain, hostname, sourceAddr string) (*Deliverabler, error) {
+	return newDeliverabler(context.Background(), domain, hostname, sourceAddr,
+		DefaultOptions.Timeout, nopObserver{}, nil)
+}
+
+// newDeliverabler generates a new Deliverabler reference that traces each
+// phase of the SMTP session and reports it to the passed Observer. The
+// session is interrupted once abort is closed
+func newDeliverabler(ctx context.Context, domain, hostname, sourceAddr string,
+	timeout time.Duration, obs Observer, abort <-chan struct{}) (*Deliverabler, error) {
 	// Dial any SMTP server that will accept a connection
-	client, err := mailDialTimeout(domain, time.Minute)
+	client, conn, host, err := mailDialTimeout(ctx, domain, timeout, obs, abort)
 	if err != nil {
 		return nil, err
 	}
+	obs.ObserveSession(1)
+	d := &Deliverabler{ctx: ctx, client: client, domain: domain, hostname: hostname,
+		sourceAddr: sourceAddr, timeout: timeout, host: host, observer: obs, abort: abort,
+		watcher: watch(conn, abort)}
 
 	// Sets the HELO/EHLO hostname
-	if err := client.Hello(hostname); err != nil {
+	finish := startPhase(ctx, obs, PhaseHello, "", host)
+	err = client.Hello(hostname)
+	finish(err)
+	if err != nil {
+		d.Close()
 		return nil, err
 	}
 
 	// Sets a source address
-	if err := client.Mail(sourceAddr); err != nil {
+	finish = startPhase(ctx, obs, PhaseMail, "", host)
+	err = client.Mail(sourceAddr)
+	finish(err)
+	if err != nil {
+		d.Close()
 		return nil, err
 	}
 
 	// Return the deliverabler if successful
-	return &Deliverabler{client, domain, hostname, sourceAddr}, nil
+	return d, nil
+}
+
+// watcher interrupts the blocked reads and writes of a connection once
+// abort is closed by expiring its deadline
+type watcher struct {
+	conn  net.Conn
+	abort <-chan struct{}
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// watch starts a watcher interrupting the connection once abort is closed
+func watch(conn net.Conn, abort <-chan struct{}) *watcher {
+	w := &watcher{conn: conn, abort: abort, stop: make(chan struct{})}
+	if abort == nil {
+		return w // Never interrupted
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		select {
+		case <-abort:
+			conn.SetDeadline(time.Now())
+		case <-w.stop:
+		}
+	}()
+	return w
+}
+
+// close stops the watcher, allowing an interrupted connection quitTimeout
+// to send QUIT
+func (w *watcher) close() {
+	close(w.stop)
+	w.wg.Wait()
+	if closed(w.abort) {
+		w.conn.SetDeadline(time.Now().Add(quitTimeout))
+	}
+}
+
+// dialResult is a successfully dialed smtp.Client along with its
+// connection and the MX host it's connected to
+type dialResult struct {
+	client *smtp.Client
+	conn   net.Conn
+	host   string
 }
 
 // dialSMTP receives a domain and attempts to dial the mail server having
 // retrieved one or more MX records
-func mailDialTimeout(domain string, timeout time.Duration) (*smtp.Client, error) {
+func mailDialTimeout(ctx context.Context, domain string, timeout time.Duration, obs Observer,
+	abort <-chan struct{}) (*smtp.Client, net.Conn, string, error) {
 	// Convert any internationalized domain names to ascii
 	asciiDomain, err := idna.ToASCII(domain)
 	if err != nil {
@@ -50,14 +136,16 @@ func mailDialTimeout(domain string, timeout time.Duration) (*smtp.Client, error)
 	}
 
 	// Retrieve all MX records
+	finish := startPhase(ctx, obs, PhaseMX, "", "")
 	records, err := net.LookupMX(asciiDomain)
+	finish(err)
 	if err != nil {
-		return nil, err
+		return nil, nil, "", err
 	}
 
 	// Verify that at least 1 MX record is found
 	if len(records) == 0 {
-		return nil, errors.New("No MX records found")
+		return nil, nil, "", errors.New("No MX records found")
 	}
 
 	// Create a channel for receiving responses from
@@ -68,9 +156,11 @@ func mailDialTimeout(domain string, timeout time.Duration) (*smtp.Client, error)
 
 	// Attempt to connect to all SMTP servers concurrently
 	for _, record := range records {
-		addr := record.Host + ":25"
+		host := strings.TrimSuffix(record.Host, ".")
 		go func() {
-			c, err := smtpDialTimeout(addr, timeout)
+			finish := startPhase(ctx, obs, PhaseDial, "", host)
+			c, conn, err := smtpDialTimeout(host+":25", timeout, abort)
+			finish(err)
 			if err != nil {
 				if !done {
 					ch <- err
@@ -82,7 +172,7 @@ func mailDialTimeout(domain string, timeout time.Duration) (*smtp.Client, error)
 			switch {
 			case !done:
 				done = true
-				ch <- c
+				ch <- &dialResult{c, conn, host}
 			default:
 				c.Close()
 			}
@@ -94,81 +184,138 @@ func mailDialTimeout(domain string, timeout time.Duration) (*smtp.Client, error)
 	for {
 		res := <-ch
 		switch r := res.(type) {
-		case *smtp.Client:
-			return r, nil
+		case *dialResult:
+			return r.client, r.conn, r.host, nil
 		case error:
 			errSlice = append(errSlice, r)
 			if len(errSlice) == len(records) {
-				return nil, errSlice[0]
+				return nil, nil, "", errSlice[0]
 			}
 		default:
-			return nil, errors.New("Unexpected response dialing SMTP server")
+			return nil, nil, "", errors.New("Unexpected response dialing SMTP server")
 		}
 	}
 }
 
 // smtpDialTimeout is a timeout wrapper for smtp.Dial. It attempts to dial an
 // SMTP server and fails with a timeout if the passed timeout is reached while
-// attempting to establish a new connection
-func smtpDialTimeout(addr string, timeout time.Duration) (*smtp.Client, error) {
+// attempting to establish a new connection, or errAborted if abort is closed
+func smtpDialTimeout(addr string, timeout time.Duration,
+	abort <-chan struct{}) (*smtp.Client, net.Conn, error) {
 	// Channel holding the new smtp.Client or error
 	ch := make(chan interface{}, 1)
 
-	// Dial the new smtp connection
+	// Dial the new smtp connection, keeping the connection so the session
+	// can be interrupted
 	go func() {
-		client, err := smtp.Dial(addr)
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			ch <- err
+			return
+		}
+		host, _, _ := net.SplitHostPort(addr)
+		client, err := smtp.NewClient(conn, host)
 		if err != nil {
+			conn.Close()
 			ch <- err
 			return
 		}
-		ch <- client
+		ch <- &dialResult{client: client, conn: conn}
 	}()
 
 	// Retrieve the smtp client from our client channel or timeout
+	var err error
 	select {
 	case res := <-ch:
 		switch r := res.(type) {
-		case *smtp.Client:
-			return r, nil
+		case *dialResult:
+			return r.client, r.conn, nil
 		case error:
-			return nil, r
+			return nil, nil, r
 		default:
-			return nil, errors.New("Unexpected response dialing SMTP server")
+			return nil, nil, errors.New("Unexpected response dialing SMTP server")
 		}
 	case <-time.After(timeout):
-		return nil, errors.New("Timeout connecting to mail-exchanger")
+		err = errors.New("Timeout connecting to mail-exchanger")
+	case <-abort:
+		err = errAborted
 	}
+
+	// End any session established after giving up on it
+	go func() {
+		if r, ok := (<-ch).(*dialResult); ok {
+			r.conn.SetDeadline(time.Now().Add(quitTimeout))
+			r.client.Quit()
+			r.client.Close()
+		}
+	}()
+	return nil, nil, err
 }
 
 // IsDeliverable takes an email address and performs the operation of adding
 // the email to the envelope. It also receives a number of retries to reconnect
 // to the MX server before erring out. If a 250 is received the email is valid
 func (d *Deliverabler) IsDeliverable(email string, retry int) error {
-	if err := d.client.Rcpt(email); err != nil {
-		// If we determine a retry should take place
-		if shouldRetry(err) && retry > 0 {
-			d.Close()                                                    // Close the previous Deliverabler
-			d, err = NewDeliverabler(d.domain, d.hostname, d.sourceAddr) // Generate a new Deliverabler
-			if err != nil {
-				return err
-			}
-			return d.IsDeliverable(email, retry-1) // Retry deliverability check
+	return d.rcpt(d.ctx, PhaseRcpt, email, retry)
+}
+
+// HasCatchAll checks the deliverability of a randomly generated address in
+// order to verify the existence of a catch-all
+func (d *Deliverabler) HasCatchAll(retry int) bool {
+	return d.rcpt(d.ctx, PhaseCatchAll, randomEmail(d.domain), retry) == nil
+}
+
+// rcpt performs the RCPT command for IsDeliverable and HasCatchAll, reporting
+// it to the Observer as the passed phase
+func (d *Deliverabler) rcpt(ctx context.Context, phase, email string, retry int) error {
+	finish := startPhase(ctx, d.observer, phase, email, d.host)
+	err := d.client.Rcpt(email)
+	finish(err)
+	if err != nil {
+		// If we determine a retry should take place, unless the session was
+		// interrupted
+		if shouldRetry(err) && retry > 0 && !closed(d.abort) {
+			return d.retry(ctx, phase, email, retry-1)
 		}
 		return err
 	}
 	return nil
 }
 
-// HasCatchAll checks the deliverability of a randomly generated address in
-// order to verify the existence of a catch-all
-func (d *Deliverabler) HasCatchAll(retry int) bool {
-	return d.IsDeliverable(randomEmail(d.domain), retry) == nil
+// retry replaces the Deliverablers connection with a new one and performs
+// the RCPT command again, all within a span covering the retry
+func (d *Deliverabler) retry(ctx context.Context, phase, email string, retry int) error {
+	ctx, span := tracer.Start(ctx, "verifier.retry", trace.WithAttributes(
+		attribute.Int("trumail.retries_remaining", retry)))
+	defer span.End()
+
+	// Close the previous connection and generate a new one
+	d.Close()
+	nd, err := newDeliverabler(ctx, d.domain, d.hostname, d.sourceAddr,
+		d.timeout, d.observer, d.abort)
+	if err != nil {
+		span.SetStatus(codes.Error, spanStatus(err))
+		return err
+	}
+	nd.ctx = d.ctx // Keep later phases under the lookups span
+	*d = *nd
+
+	// Retry deliverability check
+	return d.rcpt(ctx, phase, email, retry)
 }
 
-// Close closes the Deliverablers SMTP client connection
+// Close closes the Deliverablers SMTP client connection, sending QUIT
+// even if the session was interrupted
 func (d *Deliverabler) Close() {
-	d.client.Quit()
+	if d.closed {
+		return
+	}
+	d.closed = true
+	d.watcher.close()
+	finish := startPhase(d.ctx, d.observer, PhaseQuit, "", d.host)
+	finish(d.client.Quit())
 	d.client.Close()
+	d.observer.ObserveSession(-1)
 }
 
 // shouldRetry determines whether or not we should retry connecting to the
@@ -14,6 +14,7 @@ const (
 	ErrNoSuchHost        = "Mail server does not exist"
 	ErrServerUnavailable = "Mail server is unavailable"
 	ErrBlocked           = "Blocked by mail server"
+	ErrShuttingDown      = "The server is shutting down"
 
 	// RCPT Errors
 	ErrTryAgainLater           = "Try again later"
@@ -27,6 +28,50 @@ const (
 	ErrRCPTHasMoved            = "Recipient has moved"
 )
 
+const (
+	CodeUnexpectedResponse = "unexpected_response"
+
+	// Standard Error Codes
+	CodeTimeout           = "timeout"
+	CodeNoSuchHost        = "no_such_host"
+	CodeServerUnavailable = "server_unavailable"
+	CodeBlocked           = "blocked"
+	CodeShuttingDown      = "shutting_down"
+
+	// RCPT Error Codes
+	CodeTryAgainLater           = "try_again_later"
+	CodeFullInbox               = "full_inbox"
+	CodeTooManyRCPT             = "too_many_rcpt"
+	CodeNoRelay                 = "no_relay"
+	CodeMailboxBusy             = "mailbox_busy"
+	CodeExceededMessagingLimits = "exceeded_messaging_limits"
+	CodeNotAllowed              = "not_allowed"
+	CodeNeedMAILBeforeRCPT      = "need_mail_before_rcpt"
+	CodeRCPTHasMoved            = "rcpt_has_moved"
+
+	// CodeUnknown is used for any error without a standard message
+	CodeUnknown = "unknown"
+)
+
+// errorCodes maps each standard error message to its code
+var errorCodes = map[string]string{
+	ErrUnexpectedResponse:      CodeUnexpectedResponse,
+	ErrTimeout:                 CodeTimeout,
+	ErrNoSuchHost:              CodeNoSuchHost,
+	ErrServerUnavailable:       CodeServerUnavailable,
+	ErrBlocked:                 CodeBlocked,
+	ErrShuttingDown:            CodeShuttingDown,
+	ErrTryAgainLater:           CodeTryAgainLater,
+	ErrFullInbox:               CodeFullInbox,
+	ErrTooManyRCPT:             CodeTooManyRCPT,
+	ErrNoRelay:                 CodeNoRelay,
+	ErrMailboxBusy:             CodeMailboxBusy,
+	ErrExceededMessagingLimits: CodeExceededMessagingLimits,
+	ErrNotAllowed:              CodeNotAllowed,
+	ErrNeedMAILBeforeRCPT:      CodeNeedMAILBeforeRCPT,
+	ErrRCPTHasMoved:            CodeRCPTHasMoved,
+}
+
 // LookupError is an error
 type LookupError struct {
 	Message string `json:"message" xml:"message"`
@@ -44,6 +89,15 @@ func (e *LookupError) Error() string {
 	return fmt.Sprintf("%s : %s", e.Message, e.Details)
 }
 
+// Code returns a short, stable identifier for the error. Errors that
+// don't carry one of the standard messages return CodeUnknown
+func (e *LookupError) Code() string {
+	if code, ok := errorCodes[e.Message]; ok {
+		return code
+	}
+	return CodeUnknown
+}
+
 // ParseSMTPError receives an MX Servers response message
 // and generates the cooresponding MX error
 func ParseSMTPError(err error) *LookupError {
@@ -19,3 +19,14 @@ func TestParse550BlockedRCPTError(t *testing.T) {
 	assert.Equal(t, ErrBlocked, le.Message)
 	assert.Equal(t, err.Error(), le.Details)
 }
+
+func TestLookupErrorCode(t *testing.T) {
+	le := ParseSMTPError(errors.New("421 service not available, try again later"))
+	assert.Equal(t, CodeTryAgainLater, le.Code())
+
+	le = ParseSMTPError(errors.New("dial tcp: lookup example.invalid: no such host"))
+	assert.Equal(t, CodeNoSuchHost, le.Code())
+
+	le = ParseSMTPError(errors.New("something unexpected"))
+	assert.Equal(t, CodeUnknown, le.Code())
+}
@@ -0,0 +1,81 @@
+package verifier
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// PhaseParse is the parsing of the email address
+	PhaseParse = "parse"
+	// PhaseMX is the retrieval of the domains MX records
+	PhaseMX = "mx"
+	// PhaseDial is a single connection attempt to a mail server
+	PhaseDial = "dial"
+	// PhaseHello is the HELO/EHLO command
+	PhaseHello = "hello"
+	// PhaseMail is the MAIL FROM command
+	PhaseMail = "mail"
+	// PhaseCatchAll is the RCPT TO command for a random address
+	PhaseCatchAll = "catchall"
+	// PhaseRcpt is the RCPT TO command for the requested address
+	PhaseRcpt = "rcpt"
+	// PhaseQuit is the QUIT command ending the session
+	PhaseQuit = "quit"
+)
+
+// PhaseEvent describes a single completed phase of a lookup
+type PhaseEvent struct {
+	Phase   string        // One of the Phase constants
+	Address string        // The address being verified, if known
+	Host    string        // The mail server involved, if any
+	Took    time.Duration // How long the phase took
+	Err     error         // The error the phase failed with, if any
+}
+
+// Observer receives events from a Verifier as it performs lookups. The
+// context passed is that of the lookup. Implementations must be safe for
+// concurrent use as dial attempts are reported from multiple goroutines
+type Observer interface {
+	// ObserveLookup is called once at the end of every Verify
+	ObserveLookup(ctx context.Context, l *Lookup, err error, took time.Duration)
+	// ObservePhase is called at the end of every lookup phase
+	ObservePhase(ctx context.Context, e PhaseEvent)
+	// ObserveSession is called with 1 when an SMTP session is opened
+	// and -1 when it is closed
+	ObserveSession(delta int)
+}
+
+// nopObserver is the Observer used when none has been set
+type nopObserver struct{}
+
+func (nopObserver) ObserveLookup(context.Context, *Lookup, error, time.Duration) {}
+func (nopObserver) ObservePhase(context.Context, PhaseEvent)                     {}
+func (nopObserver) ObserveSession(int)                                           {}
+
+// multiObserver notifies each of its Observers in order
+type multiObserver []Observer
+
+// MultiObserver returns an Observer that notifies each of the passed
+// Observers in order
+func MultiObserver(obs ...Observer) Observer {
+	return multiObserver(obs)
+}
+
+func (m multiObserver) ObserveLookup(ctx context.Context, l *Lookup, err error, took time.Duration) {
+	for _, o := range m {
+		o.ObserveLookup(ctx, l, err, took)
+	}
+}
+
+func (m multiObserver) ObservePhase(ctx context.Context, e PhaseEvent) {
+	for _, o := range m {
+		o.ObservePhase(ctx, e)
+	}
+}
+
+func (m multiObserver) ObserveSession(delta int) {
+	for _, o := range m {
+		o.ObserveSession(delta)
+	}
+}
@@ -0,0 +1,20 @@
+package verifier
+
+import "time"
+
+// Options control how a single lookup is performed
+type Options struct {
+	// Timeout is the time allowed for connecting to a mail server
+	Timeout time.Duration
+	// Retries is the number of times to reconnect to a mail server
+	// after a transient error
+	Retries int
+	// SkipCatchAll skips checking the domain for a catch-all address
+	SkipCatchAll bool
+	// Literal uses the address as passed rather than URL unescaping it,
+	// for addresses that didn't arrive in a URL
+	Literal bool
+}
+
+// DefaultOptions are the Options used by Verify and VerifyContext
+var DefaultOptions = Options{Timeout: time.Minute, Retries: 3}
@@ -0,0 +1,102 @@
+package verifier
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listenMX listens on a local port as a mail server that never answers
+// RCPT, signalling each session ended with QUIT, returning its address
+func listenMX(t *testing.T, quits chan<- struct{}) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte("220 mx.test ESMTP ready\r\n"))
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					switch {
+					case err != nil:
+						return
+					case strings.HasPrefix(line, "RCPT"):
+						continue // Hang until interrupted
+					case line == "QUIT\r\n":
+						conn.Write([]byte("221 bye\r\n"))
+						quits <- struct{}{}
+						return
+					}
+					conn.Write([]byte("250 mx.test\r\n"))
+				}
+			}()
+		}
+	}()
+	return lis.Addr().String()
+}
+
+func TestShutdownDrains(t *testing.T) {
+	v := NewVerifier("localhost", "admin@localhost")
+	assert.Nil(t, v.Shutdown(context.Background()))
+
+	// Lookups in flight are waited for until they complete
+	v.begin()
+	time.AfterFunc(20*time.Millisecond, v.end)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, v.Shutdown(ctx))
+	assert.False(t, v.aborted())
+}
+
+func TestShutdownAborts(t *testing.T) {
+	v := NewVerifier("localhost", "admin@localhost")
+	v.begin()
+	go func() {
+		<-v.abort
+		v.end()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, v.Shutdown(ctx))
+	assert.True(t, v.aborted())
+
+	// Lookups fail once aborted
+	results := collect(v.VerifyBatch(context.Background(),
+		[]string{"a@example.com", "invalid"}, DefaultOptions, 2), 2)
+	assert.Equal(t, CodeShuttingDown, results[0].Err.(*LookupError).Code())
+	assert.Nil(t, results[1].Err)
+}
+
+func TestDeliverablerAbort(t *testing.T) {
+	quits := make(chan struct{}, 1)
+	abort := make(chan struct{})
+	client, conn, err := smtpDialTimeout(listenMX(t, quits), time.Second, abort)
+	assert.Nil(t, err)
+	d := &Deliverabler{ctx: context.Background(), client: client, domain: "example.com",
+		observer: nopObserver{}, abort: abort, watcher: watch(conn, abort)}
+	assert.Nil(t, client.Hello("localhost"))
+
+	// Aborting interrupts a blocked RCPT without retrying it
+	time.AfterFunc(20*time.Millisecond, func() { close(abort) })
+	assert.Error(t, d.IsDeliverable("a@example.com", 2))
+
+	// The interrupted session is still ended with QUIT
+	d.Close()
+	select {
+	case <-quits:
+	case <-time.After(time.Second):
+		t.Fatal("session wasn't ended with QUIT")
+	}
+}
@@ -0,0 +1,73 @@
+package verifier
+
+const (
+	// StatusDeliverable indicates mail to the address will be accepted
+	StatusDeliverable = "deliverable"
+	// StatusUndeliverable indicates mail to the address will be rejected
+	StatusUndeliverable = "undeliverable"
+	// StatusUnknown indicates deliverability could not be determined
+	StatusUnknown = "unknown"
+)
+
+const (
+	// ReasonAcceptedEmail means the mail server accepted the recipient
+	ReasonAcceptedEmail = "accepted_email"
+	// ReasonCatchAll means the mail server accepts any recipient
+	ReasonCatchAll = "catch_all"
+	// ReasonRejectedEmail means the mail server rejected the recipient
+	ReasonRejectedEmail = "rejected_email"
+	// ReasonFullInbox means the recipients inbox is full
+	ReasonFullInbox = "full_inbox"
+	// ReasonInvalidFormat means the address could not be parsed
+	ReasonInvalidFormat = "invalid_format"
+	// ReasonHostNotFound means the domain has no reachable mail server
+	ReasonHostNotFound = "host_not_found"
+	// ReasonLookupError means the lookup failed before completing
+	ReasonLookupError = "lookup_error"
+)
+
+// Outcome summarizes the result of a Verify as a Status and Reason pair.
+// Both values are drawn from a fixed set making them safe to use as
+// metric labels
+func Outcome(l *Lookup, err error) (status, reason string) {
+	if le, ok := err.(*LookupError); ok && le != nil {
+		if le.Code() == CodeNoSuchHost {
+			return StatusUndeliverable, ReasonHostNotFound
+		}
+		return StatusUnknown, ReasonLookupError
+	}
+	switch {
+	case err != nil || l == nil:
+		return StatusUnknown, ReasonLookupError
+	case !l.ValidFormat:
+		return StatusUndeliverable, ReasonInvalidFormat
+	case !l.HostExists:
+		return StatusUndeliverable, ReasonHostNotFound
+	case l.FullInbox:
+		return StatusUndeliverable, ReasonFullInbox
+	case l.CatchAll:
+		return StatusDeliverable, ReasonCatchAll
+	case l.Deliverable:
+		return StatusDeliverable, ReasonAcceptedEmail
+	default:
+		return StatusUndeliverable, ReasonRejectedEmail
+	}
+}
+
+// scores maps each Reason to the Score given to lookups with that Reason
+var scores = map[string]int{
+	ReasonAcceptedEmail: 100,
+	ReasonCatchAll:      60,
+	ReasonLookupError:   50,
+	ReasonFullInbox:     20,
+	ReasonRejectedEmail: 0,
+	ReasonInvalidFormat: 0,
+	ReasonHostNotFound:  0,
+}
+
+// Score rates the likelihood of mail to the address being delivered from
+// 0 (certain to be rejected) to 100 (certain to be accepted)
+func Score(l *Lookup, err error) int {
+	_, reason := Outcome(l, err)
+	return scores[reason]
+}
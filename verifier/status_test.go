@@ -0,0 +1,33 @@
+package verifier
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutcome(t *testing.T) {
+	status, reason := Outcome(&Lookup{}, nil)
+	assert.Equal(t, StatusUndeliverable, status)
+	assert.Equal(t, ReasonInvalidFormat, reason)
+
+	status, reason = Outcome(&Lookup{ValidFormat: true, HostExists: true,
+		Deliverable: true, CatchAll: true}, nil)
+	assert.Equal(t, StatusDeliverable, status)
+	assert.Equal(t, ReasonCatchAll, reason)
+
+	status, reason = Outcome(&Lookup{ValidFormat: true, HostExists: true}, nil)
+	assert.Equal(t, StatusUndeliverable, status)
+	assert.Equal(t, ReasonRejectedEmail, reason)
+
+	status, reason = Outcome(&Lookup{ValidFormat: true},
+		ParseSMTPError(errors.New("lookup example.invalid: no such host")))
+	assert.Equal(t, StatusUndeliverable, status)
+	assert.Equal(t, ReasonHostNotFound, reason)
+
+	status, reason = Outcome(&Lookup{ValidFormat: true},
+		ParseSMTPError(errors.New("i/o timeout")))
+	assert.Equal(t, StatusUnknown, status)
+	assert.Equal(t, ReasonLookupError, reason)
+}
@@ -0,0 +1,52 @@
+package verifier
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// timingObserver is an Observer that collects the time spent in each
+// phase of a single lookup
+type timingObserver struct {
+	mu      sync.Mutex
+	timings map[string]time.Duration
+	dialed  bool // Whether the winning dial since the last MX lookup was seen
+}
+
+// newTimingObserver generates a new timingObserver reference
+func newTimingObserver() *timingObserver {
+	return &timingObserver{timings: make(map[string]time.Duration)}
+}
+
+func (t *timingObserver) ObserveLookup(context.Context, *Lookup, error, time.Duration) {}
+func (t *timingObserver) ObserveSession(int)                                           {}
+
+// ObservePhase adds the time taken by the phase to its total. Mail servers
+// are dialed concurrently so only the first successful dial following each
+// MX lookup is counted
+func (t *timingObserver) ObservePhase(_ context.Context, e PhaseEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch e.Phase {
+	case PhaseMX:
+		t.dialed = false
+	case PhaseDial:
+		if e.Err != nil || t.dialed {
+			return
+		}
+		t.dialed = true
+	}
+	t.timings[e.Phase] += e.Took
+}
+
+// snapshot returns a copy of the timings collected so far
+func (t *timingObserver) snapshot() map[string]time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timings := make(map[string]time.Duration, len(t.timings))
+	for phase, took := range t.timings {
+		timings[phase] = took
+	}
+	return timings
+}
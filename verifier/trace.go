@@ -0,0 +1,59 @@
+package verifier
+
+import (
+	"context"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans covering a lookup and each of its phases
+var tracer = otel.Tracer("github.com/sdwolfe32/trumail/verifier")
+
+// spanNames maps each lookup phase to the name of the span covering it
+var spanNames = map[string]string{
+	PhaseParse:    "verifier.parse",
+	PhaseMX:       "verifier.mx",
+	PhaseDial:     "smtp.dial",
+	PhaseHello:    "smtp.HELO",
+	PhaseMail:     "smtp.MAIL",
+	PhaseCatchAll: "smtp.RCPT",
+	PhaseRcpt:     "smtp.RCPT",
+	PhaseQuit:     "smtp.QUIT",
+}
+
+// startPhase starts a span covering a phase of a lookup. It returns a func
+// that ends the span and reports the completed phase to the passed Observer.
+// Addresses are never recorded on spans
+func startPhase(ctx context.Context, obs Observer, phase, address, host string) func(error) {
+	start := time.Now()
+	_, span := tracer.Start(ctx, spanNames[phase], trace.WithAttributes(
+		attribute.String("trumail.phase", phase)))
+	if host != "" {
+		span.SetAttributes(attribute.String("net.peer.name", host))
+	}
+	return func(err error) {
+		if err != nil {
+			span.SetStatus(codes.Error, spanStatus(err))
+		}
+		span.End()
+		obs.ObservePhase(ctx, PhaseEvent{phase, address, host, time.Since(start), err})
+	}
+}
+
+// spanStatus describes an error without the mail servers reply text,
+// which frequently echoes the address being verified
+func spanStatus(err error) string {
+	if tpErr, ok := err.(*textproto.Error); ok {
+		return strconv.Itoa(tpErr.Code)
+	}
+	if le, ok := err.(*LookupError); ok {
+		return le.Code()
+	}
+	return parseBasicErr(err).Code()
+}
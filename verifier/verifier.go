@@ -1,62 +1,259 @@
 package verifier
 
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
 // Verifier contains all dependencies needed to perform educated email
 // verification lookups
-type Verifier struct{ hostname, sourceAddr string }
+type Verifier struct {
+	hostname, sourceAddr string
+	observer             Observer
+	sessionRCPTs         int           // The most addresses of a batch per SMTP session
+	abort                chan struct{} // Closed to abort the lookups in flight
+
+	mu       sync.Mutex
+	inFlight int           // The lookups and batches being verified
+	drained  chan struct{} // Closed once none are in flight during Shutdown
+}
 
 // Lookup contains all output data for an email verification Lookup
 type Lookup struct {
 	Address
 	ValidFormat, Deliverable, FullInbox, HostExists, CatchAll bool
+
+	// Timings holds the time taken by each phase of the lookup
+	Timings map[string]time.Duration
+	// Took is the time taken by the whole lookup
+	Took time.Duration
 }
 
+// DefaultSessionRCPTs is the most addresses of a batch verified over a
+// single SMTP session by default, the fewest recipients RFC 5321 requires
+// mail servers to accept per message
+const DefaultSessionRCPTs = 100
+
 // NewVerifier generates a new Verifier using the passed hostname and
 // source email address
 func NewVerifier(hostname, sourceAddr string) *Verifier {
-	return &Verifier{hostname, sourceAddr}
+	return &Verifier{hostname: hostname, sourceAddr: sourceAddr, observer: nopObserver{},
+		sessionRCPTs: DefaultSessionRCPTs, abort: make(chan struct{})}
+}
+
+// SetSessionRCPTs sets the most addresses of a batch sharing a domain that
+// are verified over a single SMTP session, opening another for the rest.
+// Values below one are ignored
+func (v *Verifier) SetSessionRCPTs(n int) {
+	if n > 0 {
+		v.sessionRCPTs = n
+	}
+}
+
+// SetObserver sets the Observer notified of every lookup performed by
+// the Verifier
+func (v *Verifier) SetObserver(o Observer) {
+	if o == nil {
+		o = nopObserver{}
+	}
+	v.observer = o
+}
+
+// Shutdown waits for the lookups in flight to complete until the context
+// is done, then aborts the remaining ones and waits for them to fail with
+// ErrShuttingDown. Aborted lookups interrupt their SMTP sessions, which are
+// still ended with QUIT. Once aborted the Verifier fails every lookup
+func (v *Verifier) Shutdown(ctx context.Context) error {
+	v.mu.Lock()
+	if v.inFlight == 0 {
+		v.mu.Unlock()
+		return nil
+	}
+	if v.drained == nil {
+		v.drained = make(chan struct{})
+	}
+	drained := v.drained
+	v.mu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+	}
+	v.mu.Lock()
+	if !v.aborted() {
+		close(v.abort)
+	}
+	v.mu.Unlock()
+	<-drained
+	return ctx.Err()
+}
+
+// begin registers a lookup or batch as in flight
+func (v *Verifier) begin() {
+	v.mu.Lock()
+	v.inFlight++
+	v.mu.Unlock()
+}
+
+// end unregisters a lookup or batch registered by begin
+func (v *Verifier) end() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.inFlight--; v.inFlight == 0 && v.drained != nil {
+		close(v.drained)
+		v.drained = nil
+	}
+}
+
+// aborted returns whether Shutdown has aborted the lookups in flight
+func (v *Verifier) aborted() bool {
+	return closed(v.abort)
+}
+
+// closed returns whether the passed channel is closed without blocking
+func closed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
 }
 
 // Verify performs an email verification on the passed email address
 func (v *Verifier) Verify(email string) (*Lookup, error) {
-	// Allocate memory for the Lookup
-	var l Lookup
-	l.Address.Address = email
+	return v.VerifyContext(context.Background(), email)
+}
+
+// VerifyContext performs an email verification on the passed email
+// address, tracing the lookup as a child of any span held by the context
+func (v *Verifier) VerifyContext(ctx context.Context, email string) (*Lookup, error) {
+	return v.VerifyOptions(ctx, email, DefaultOptions)
+}
 
+// VerifyOptions performs an email verification on the passed email address
+// using the passed Options
+func (v *Verifier) VerifyOptions(ctx context.Context, email string, opts Options) (*Lookup, error) {
+	ctx, span := tracer.Start(ctx, "verifier.Verify")
+	defer span.End()
+
+	start := time.Now()
+	timings := newTimingObserver()
+	l, err := v.verify(ctx, email, opts, MultiObserver(v.observer, timings))
+	l.Timings, l.Took = timings.snapshot(), time.Since(start)
+	v.observer.ObserveLookup(ctx, l, err, l.Took)
+
+	// Record the outcome of the lookup on the span
+	status, reason := Outcome(l, err)
+	span.SetAttributes(
+		attribute.String("trumail.domain", l.Domain),
+		attribute.String("trumail.status", status),
+		attribute.String("trumail.reason", reason),
+	)
+	return l, err
+}
+
+// verify performs the verification reported on by VerifyContext. A nil
+// *LookupError is never returned as a non-nil error
+func (v *Verifier) verify(ctx context.Context, email string, opts Options, obs Observer) (*Lookup, error) {
 	// First parse the email address passed
-	address, err := ParseAddress(email)
-	if err != nil {
-		l.ValidFormat = false
-		return &l, nil
+	l := parse(ctx, email, opts, obs)
+	if !l.ValidFormat {
+		return l, nil
 	}
-	l.ValidFormat = true
-	l.Address = *address
+	v.begin()
+	defer v.end()
 
 	// Attempt to form an SMTP Connection
-	del, err := NewDeliverabler(address.Domain, v.hostname, v.sourceAddr)
+	del, err := newDeliverabler(ctx, l.Domain, v.hostname, v.sourceAddr,
+		opts.Timeout, obs, v.abort)
 	if err != nil {
-		return &l, ParseSMTPError(err)
+		return l, v.lookupError(err)
 	}
 	defer del.Close() // Defer close the SMTP connection
 
+	// Retrieve the catchall status and check deliverability
+	catchAll := !opts.SkipCatchAll && del.HasCatchAll(opts.Retries)
+	err = deliver(del, l, catchAll, opts.Retries)
+	if v.aborted() {
+		return l, errShuttingDown()
+	}
+	return l, err
+}
+
+// parse parses the passed email address into a new Lookup, leaving
+// ValidFormat false if it can't be parsed
+func parse(ctx context.Context, email string, opts Options, obs Observer) *Lookup {
+	// Allocate memory for the Lookup
+	var l Lookup
+	l.Address.Address = email
+
+	finish := startPhase(ctx, obs, PhaseParse, email, "")
+	var address *Address
+	var err error
+	if opts.Literal {
+		address, err = parseAddress(email)
+	} else {
+		address, err = ParseAddress(email)
+	}
+	finish(err)
+	if err != nil {
+		return &l
+	}
+	l.ValidFormat = true
+	l.Address = *address
+	return &l
+}
+
+// deliver completes the Lookup using an established SMTP connection to its
+// domain and the domains catch-all status
+func deliver(del *Deliverabler, l *Lookup, catchAll bool, retries int) error {
 	// Host exists if we've successfully formed a connection
 	l.HostExists = true
 
-	// Retrieve the catchall status and check deliverability
-	if del.HasCatchAll(3) {
+	if catchAll {
 		l.CatchAll = true
 		l.Deliverable = true
-	} else {
-		if err := del.IsDeliverable(address.Address, 3); err != nil {
-			if le := ParseSMTPError(err); le != nil {
-				if le.Message == ErrFullInbox {
-					l.FullInbox = true // set FullInbox and return no error
-					return &l, nil
-				}
-				return &l, le // Return if there's a true error
+		return nil
+	}
+	if err := del.IsDeliverable(l.Address.Address, retries); err != nil {
+		if le := ParseSMTPError(err); le != nil {
+			if le.Message == ErrFullInbox {
+				l.FullInbox = true // set FullInbox and return no error
+				return nil
 			}
-		} else {
-			l.Deliverable = true
+			return le // Return if there's a true error
 		}
+		return nil
+	}
+	l.Deliverable = true
+	return nil
+}
+
+// lookupError parses the passed error into a *LookupError like the
+// lookupError func, failing with ErrShuttingDown once the lookups in
+// flight have been aborted
+func (v *Verifier) lookupError(err error) error {
+	if v.aborted() {
+		return errShuttingDown()
+	}
+	return lookupError(err)
+}
+
+// errShuttingDown returns the error lookups aborted by Shutdown fail with
+func errShuttingDown() *LookupError {
+	return newLookupError(ErrShuttingDown, "The lookup was aborted before completing")
+}
+
+// lookupError parses the passed error into a *LookupError, returning an
+// untyped nil rather than a nil *LookupError
+func lookupError(err error) error {
+	if le := ParseSMTPError(err); le != nil {
+		return le
 	}
-	return &l, nil
+	return nil
 }
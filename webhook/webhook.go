@@ -0,0 +1,241 @@
+// Package webhook delivers signed HTTP callbacks, retrying failed
+// deliveries with exponential backoff
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// HeaderEvent names the event a delivery is for
+	HeaderEvent = "X-Trumail-Event"
+	// HeaderDelivery uniquely identifies a delivery, staying the same
+	// across its attempts
+	HeaderDelivery = "X-Trumail-Delivery"
+	// HeaderTimestamp is the unix time at which an attempt was signed
+	HeaderTimestamp = "X-Trumail-Timestamp"
+	// HeaderSignature is the HMAC-SHA256 signature of an attempt
+	HeaderSignature = "X-Trumail-Signature"
+)
+
+var (
+	// ErrInvalidSignature is thrown when a signature doesn't match
+	ErrInvalidSignature = errors.New("Invalid webhook signature")
+	// ErrExpiredSignature is thrown when a signature is older than allowed
+	ErrExpiredSignature = errors.New("Expired webhook signature")
+	// ErrPrivateAddress is thrown when a webhook URL resolves to a
+	// loopback, private, link-local or unspecified address
+	ErrPrivateAddress = errors.New("Webhook URLs must resolve to public addresses")
+)
+
+// Attempt records a single attempt at delivering a webhook
+type Attempt struct {
+	Attempt  int           `json:"attempt"`
+	Time     time.Time     `json:"time"`
+	Status   int           `json:"status,omitempty"` // The HTTP status received
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Client delivers webhooks
+type Client struct {
+	HTTP       *http.Client
+	Attempts   int           // The most attempts made per delivery
+	Backoff    time.Duration // The delay before the first retry
+	MaxBackoff time.Duration // The longest delay between retries
+
+	// AllowPrivate allows delivering to loopback, private, link-local and
+	// unspecified addresses, which are otherwise refused
+	AllowPrivate bool
+}
+
+// NewClient generates a new Client reference making up to 8 attempts per
+// delivery, doubling the delay between them from a second up to 5 minutes.
+// The address of every connection is checked as it's dialed, so hosts
+// can't resolve to a private address after passing CheckURL
+func NewClient() *Client {
+	c := &Client{
+		Attempts:   8,
+		Backoff:    time.Second,
+		MaxBackoff: 5 * time.Minute,
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: c.control}
+	c.HTTP = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+	return c
+}
+
+// CheckURL asserts the host of the passed URL only resolves to addresses
+// the Client delivers to
+func (c *Client) CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if c.AllowPrivate {
+		return nil
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		return checkIP(ip)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if err := checkIP(addr.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// control refuses to connect to addresses the Client doesn't deliver to
+func (c *Client) control(_, address string, _ syscall.RawConn) error {
+	if c.AllowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ErrPrivateAddress
+	}
+	return checkIP(ip)
+}
+
+// checkIP fails with ErrPrivateAddress unless the passed IP is public
+func checkIP(ip net.IP) error {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// Deliver POSTs the JSON body to the passed URL as the passed event, signed
+// with the secret. Failed attempts are retried with exponential backoff
+// until one succeeds, a permanent failure is received, the attempts are
+// exhausted or the context is cancelled. Every attempt is passed to record
+func (c *Client) Deliver(ctx context.Context, url, secret, event string, body []byte,
+	record func(Attempt)) error {
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	backoff := c.Backoff
+	for attempt := 1; ; attempt++ {
+		a, retry := c.attempt(ctx, url, secret, event, id, body)
+		a.Attempt = attempt
+		record(a)
+		if a.Error == "" {
+			return nil
+		}
+		if !retry || attempt >= c.Attempts {
+			return errors.New(a.Error)
+		}
+
+		// Wait before retrying
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > c.MaxBackoff {
+			backoff = c.MaxBackoff
+		}
+	}
+}
+
+// attempt makes a single attempt at a delivery, reporting whether a failed
+// attempt should be retried
+func (c *Client) attempt(ctx context.Context, url, secret, event, id string,
+	body []byte) (Attempt, bool) {
+	a := Attempt{Time: time.Now().UTC()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		a.Error = err.Error()
+		return a, false
+	}
+	timestamp := a.Time.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Trumail-Webhook")
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderDelivery, id)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(secret, timestamp, body))
+
+	res, err := c.HTTP.Do(req)
+	a.Duration = time.Since(a.Time)
+	if err != nil {
+		a.Error = err.Error()
+		return a, ctx.Err() == nil && !errors.Is(err, ErrPrivateAddress)
+	}
+	io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+	res.Body.Close()
+	a.Status = res.StatusCode
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return a, false
+	}
+	a.Error = fmt.Sprintf("Unexpected response status %d", res.StatusCode)
+	return a, res.StatusCode >= 500 || res.StatusCode == http.StatusRequestTimeout ||
+		res.StatusCode == http.StatusTooManyRequests
+}
+
+// Sign returns the signature of a body sent at the passed unix time, the
+// hex encoded HMAC-SHA256 of the timestamp, a dot and the body
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify verifies the signature and timestamp headers of a received
+// webhook, rejecting signatures older than the passed tolerance
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration) error {
+	timestamp, err := strconv.ParseInt(header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	expected := Sign(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(HeaderSignature))) {
+		return ErrInvalidSignature
+	}
+	if time.Since(time.Unix(timestamp, 0)) > tolerance {
+		return ErrExpiredSignature
+	}
+	return nil
+}
+
+// newID generates a random delivery ID
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testClient generates a Client retrying without delay, allowed to
+// deliver to the local test servers
+func testClient() *Client {
+	c := NewClient()
+	c.Attempts, c.Backoff, c.MaxBackoff = 3, time.Millisecond, time.Millisecond
+	c.AllowPrivate = true
+	return c
+}
+
+func TestDeliver(t *testing.T) {
+	body := []byte(`{"event":"job.completed"}`)
+	var received int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		assert.Equal(t, body, b)
+		assert.Equal(t, "job.completed", r.Header.Get(HeaderEvent))
+		assert.NotEmpty(t, r.Header.Get(HeaderDelivery))
+		assert.Nil(t, Verify("secret", r.Header, b, time.Minute))
+		assert.Equal(t, ErrInvalidSignature, Verify("other", r.Header, b, time.Minute))
+		atomic.AddInt32(&received, 1)
+	}))
+	defer srv.Close()
+
+	var attempts []Attempt
+	err := testClient().Deliver(context.Background(), srv.URL, "secret", "job.completed",
+		body, func(a Attempt) { attempts = append(attempts, a) })
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), received)
+	assert.Len(t, attempts, 1)
+	assert.Equal(t, 1, attempts[0].Attempt)
+	assert.Equal(t, http.StatusOK, attempts[0].Status)
+	assert.Empty(t, attempts[0].Error)
+}
+
+func TestDeliverRetries(t *testing.T) {
+	var received int32
+	var deliveries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveries = append(deliveries, r.Header.Get(HeaderDelivery))
+		if atomic.AddInt32(&received, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	var attempts []Attempt
+	err := testClient().Deliver(context.Background(), srv.URL, "secret", "job.completed",
+		[]byte(`{}`), func(a Attempt) { attempts = append(attempts, a) })
+	assert.Nil(t, err)
+	assert.Len(t, attempts, 3)
+	assert.Equal(t, http.StatusServiceUnavailable, attempts[0].Status)
+	assert.Equal(t, "Unexpected response status 503", attempts[0].Error)
+	assert.Equal(t, http.StatusOK, attempts[2].Status)
+	assert.Equal(t, deliveries[0], deliveries[2])
+}
+
+func TestDeliverFailures(t *testing.T) {
+	var status int32 = http.StatusInternalServerError
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer srv.Close()
+
+	// Exhausts its attempts
+	var n int
+	err := testClient().Deliver(context.Background(), srv.URL, "secret", "job.completed",
+		[]byte(`{}`), func(Attempt) { n++ })
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, n)
+
+	// Permanent failures aren't retried
+	atomic.StoreInt32(&status, http.StatusGone)
+	n = 0
+	err = testClient().Deliver(context.Background(), srv.URL, "secret", "job.completed",
+		[]byte(`{}`), func(Attempt) { n++ })
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestPrivateAddresses(t *testing.T) {
+	c := NewClient()
+	ctx := context.Background()
+	for _, u := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://10.1.2.3/hook",
+		"http://192.168.0.10/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/hook",
+		"http://[fd00:ec2::254]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		assert.Equal(t, ErrPrivateAddress, c.CheckURL(ctx, u), u)
+	}
+	assert.Nil(t, c.CheckURL(ctx, "https://93.184.216.34/hook"))
+
+	// Connections are refused as they're dialed, without retrying
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Error("delivered to a private address")
+	}))
+	defer srv.Close()
+	c.Backoff = time.Millisecond
+	var n int
+	err := c.Deliver(ctx, srv.URL, "secret", "job.completed", []byte(`{}`),
+		func(Attempt) { n++ })
+	assert.Contains(t, err.Error(), ErrPrivateAddress.Error())
+	assert.Equal(t, 1, n)
+
+	// Unless private addresses are allowed
+	c.AllowPrivate = true
+	assert.Nil(t, c.CheckURL(ctx, srv.URL))
+	assert.Nil(t, c.control("tcp", srv.Listener.Addr().String(), nil))
+}
+
+func TestVerifyExpired(t *testing.T) {
+	timestamp := time.Now().Add(-time.Hour).Unix()
+	header := http.Header{}
+	header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	header.Set(HeaderSignature, Sign("secret", timestamp, []byte("body")))
+	assert.Equal(t, ErrExpiredSignature, Verify("secret", header, []byte("body"), time.Minute))
+	assert.Nil(t, Verify("secret", header, []byte("body"), 2*time.Hour))
+}